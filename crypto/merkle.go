@@ -99,6 +99,36 @@ func CalculateLeaves(dataSize uint64) uint64 {
 	return numSegments
 }
 
+// MerkleProofLength returns the number of hashes in the hash set of a Merkle
+// proof for segment 'proofIndex' of a tree with 'numSegments' leaves. The tree
+// is made of perfect subtrees, one for each bit set in numSegments, with the
+// largest on the left. A proof contains one hash for each level of the
+// perfect subtree holding the segment, one hash for each larger subtree to
+// its left, and one hash covering all of the smaller subtrees to its right.
+func MerkleProofLength(numSegments, proofIndex uint64) int {
+	if proofIndex >= numSegments {
+		return 0
+	}
+	length := 0
+	var start uint64
+	for height := 63; height >= 0; height-- {
+		size := uint64(1) << uint64(height)
+		if numSegments&size == 0 {
+			continue
+		}
+		if proofIndex < start+size {
+			length += height
+			if numSegments&(size-1) != 0 {
+				length++
+			}
+			break
+		}
+		length++
+		start += size
+	}
+	return length
+}
+
 // MerkleRoot returns the Merkle root of the input data.
 func MerkleRoot(b []byte) Hash {
 	t := NewTree()
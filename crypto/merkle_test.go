@@ -61,6 +61,23 @@ func TestStorageProof(t *testing.T) {
 	}
 }
 
+// TestMerkleProofLength checks that MerkleProofLength matches the length of
+// the proofs produced by MerkleProof.
+func TestMerkleProofLength(t *testing.T) {
+	for numSegments := uint64(1); numSegments <= 70; numSegments++ {
+		data := fastrand.Bytes(int(numSegments * SegmentSize))
+		for i := uint64(0); i < numSegments; i++ {
+			_, hashSet := MerkleProof(data, i)
+			if MerkleProofLength(numSegments, i) != len(hashSet) {
+				t.Fatalf("wrong proof length for segment %v of %v: expected %v, got %v", i, numSegments, len(hashSet), MerkleProofLength(numSegments, i))
+			}
+		}
+	}
+	if MerkleProofLength(5, 5) != 0 {
+		t.Error("out of range segment should have a proof length of 0")
+	}
+}
+
 // TestNonMultipleNumberOfSegmentsStorageProof builds a storage proof that has
 // a last leaf of size less than SegmentSize.
 func TestNonMultipleLeafSizeStorageProof(t *testing.T) {
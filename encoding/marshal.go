@@ -19,6 +19,7 @@ const (
 
 var (
 	errBadPointer = errors.New("cannot decode into invalid pointer")
+	errExtraData  = errors.New("encoded value contains extra data")
 )
 
 type (
@@ -331,12 +332,28 @@ func NewDecoder(r io.Reader) *Decoder {
 
 // Unmarshal decodes the encoded value b and stores it in v, which must be a
 // pointer. The decoding rules are the inverse of those specified in the
-// package docstring for marshaling.
+// package docstring for marshaling. Any data beyond the encoded value is
+// ignored; consensus code relies on this, so it must not be changed.
 func Unmarshal(b []byte, v interface{}) error {
 	r := bytes.NewReader(b)
 	return NewDecoder(r).Decode(v)
 }
 
+// UnmarshalStrict is like Unmarshal, but returns an error if b contains any
+// data beyond the encoded value. It must not be used to decode objects that
+// are checked by consensus, such as public keys and signatures, as blocks
+// containing trailing data in those objects are valid.
+func UnmarshalStrict(b []byte, v interface{}) error {
+	r := bytes.NewReader(b)
+	err := NewDecoder(r).Decode(v)
+	if err != nil {
+		return err
+	} else if r.Len() != 0 {
+		return errExtraData
+	}
+	return nil
+}
+
 // UnmarshalAll decodes the encoded values in b and stores them in vs, which
 // must be pointers.
 func UnmarshalAll(b []byte, vs ...interface{}) error {
@@ -194,6 +194,27 @@ func TestMarshalUnmarshal(t *testing.T) {
 	}
 }
 
+// TestUnmarshalMalformed checks that UnmarshalStrict returns an error when the
+// input is truncated or followed by extra data, and that Unmarshal ignores
+// the extra data.
+func TestUnmarshalMalformed(t *testing.T) {
+	var emptyStructs = []interface{}{&test0{}, &test1{}, &test2{}, &test3{}, &test4{}, &test5{}, &test6{}}
+	for i := range testStructs {
+		b := Marshal(testStructs[i])
+		for j := 0; j < len(b); j++ {
+			if err := UnmarshalStrict(b[:j], emptyStructs[i]); err == nil {
+				t.Errorf("struct %v: decoded truncated input of length %v", i, j)
+			}
+		}
+		if err := UnmarshalStrict(append(b, 0), emptyStructs[i]); err != errExtraData {
+			t.Errorf("struct %v: expected %v, got %v", i, errExtraData, err)
+		}
+		if err := Unmarshal(append(b, 0), emptyStructs[i]); err != nil {
+			t.Errorf("struct %v: Unmarshal rejected extra data: %v", i, err)
+		}
+	}
+}
+
 // TestEncodeDecode tests the Encode and Decode functions, which are inverses
 // of each other.
 func TestEncodeDecode(t *testing.T) {
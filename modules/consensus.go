@@ -2,6 +2,7 @@ package modules
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
@@ -20,6 +21,16 @@ const (
 	DiffRevert DiffDirection = false
 )
 
+const (
+	// ContractEventValidProof indicates that a file contract was resolved by
+	// a valid storage proof.
+	ContractEventValidProof ContractEventType = iota
+
+	// ContractEventMissedProof indicates that a file contract's proof window
+	// closed without a storage proof.
+	ContractEventMissedProof
+)
+
 var (
 	// ConsensusChangeBeginning is a special consensus change id that tells the
 	// consensus set to provide all consensus changes starting from the very
@@ -31,6 +42,10 @@ var (
 	// starting from a specific value (which may not be known to the caller).
 	ConsensusChangeRecent = ConsensusChangeID{1}
 
+	// ErrBadMinerPayouts indicates that the miner payouts of a block do not
+	// add up to the block subsidy.
+	ErrBadMinerPayouts = errors.New("miner payout sum does not equal block subsidy")
+
 	// ErrBlockKnown is an error indicating that a block is already in the
 	// database.
 	ErrBlockKnown = errors.New("block already present in database")
@@ -39,6 +54,24 @@ var (
 	// target.
 	ErrBlockUnsolved = errors.New("block does not meet target")
 
+	// ErrDoSBlock indicates that a block has already been found to be
+	// invalid. The block was not validated again.
+	ErrDoSBlock = errors.New("block is known to be invalid")
+
+	// ErrEarlyTimestamp indicates that a block's timestamp is below the
+	// minimum timestamp allowed for a child of its parent.
+	ErrEarlyTimestamp = errors.New("block timestamp is too early")
+
+	// ErrExtremeFutureTimestamp indicates that a block's timestamp is so far
+	// in the future that the block was discarded.
+	ErrExtremeFutureTimestamp = errors.New("block timestamp too far in future, discarded")
+
+	// ErrFutureTimestamp indicates that a block's timestamp is too far in the
+	// future to be accepted now. Unlike ErrExtremeFutureTimestamp, this does
+	// not mean the block is invalid; the consensus set holds on to it and
+	// tries again once its timestamp is acceptable.
+	ErrFutureTimestamp = errors.New("block timestamp too far in future, but saved for later use")
+
 	// ErrInvalidConsensusChangeID indicates that ConsensusSetPersistSubscribe
 	// was called with a consensus change id that is not recognized. Most
 	// commonly, this means that the consensus set was deleted or replaced and
@@ -46,10 +79,40 @@ var (
 	// should be handled by the module, and not reported to the user.
 	ErrInvalidConsensusChangeID = errors.New("consensus subscription has invalid id - files are inconsistent")
 
+	// ErrLargeBlock indicates that a block exceeds types.BlockSizeLimit.
+	ErrLargeBlock = errors.New("block is too large to be accepted")
+
+	// ErrMissingSiacoinOutput indicates that a transaction spends a siacoin
+	// output that is not in the consensus set.
+	ErrMissingSiacoinOutput = errors.New("transaction spends a nonexisting siacoin output")
+
+	// ErrMissingSiafundOutput indicates that a transaction spends a siafund
+	// output that is not in the consensus set.
+	ErrMissingSiafundOutput = errors.New("transaction spends a nonexisting siafund output")
+
 	// ErrNonExtendingBlock indicates that a block is valid but does not result
 	// in a fork that is the heaviest known fork - the consensus set has not
 	// changed as a result of seeing the block.
 	ErrNonExtendingBlock = errors.New("block does not extend the longest fork")
+
+	// ErrOrphan indicates that a block's parent is not known. This does not
+	// mean that the block is invalid; the consensus set holds on to it until
+	// the parent arrives, and the sender can be asked for the parent.
+	ErrOrphan = errors.New("block has no known parent")
+
+	// ErrSiacoinInputOutputMismatch indicates that the siacoin inputs of a
+	// transaction do not equal its siacoin outputs, file contract payouts and
+	// miner fees.
+	ErrSiacoinInputOutputMismatch = errors.New("siacoin inputs do not equal siacoin outputs for transaction")
+
+	// ErrSiafundInputOutputMismatch indicates that the siafund inputs of a
+	// transaction do not equal its siafund outputs.
+	ErrSiafundInputOutputMismatch = errors.New("siafund inputs do not equal siafund outputs for transaction")
+
+	// ErrWrongUnlockConditions indicates that the unlock conditions supplied
+	// by a transaction do not hash to the unlock hash of the object being
+	// spent or revised.
+	ErrWrongUnlockConditions = errors.New("transaction contains incorrect unlock conditions")
 )
 
 type (
@@ -60,6 +123,45 @@ type (
 	// reverted. A bool is used to restrict the value to these two possibilities.
 	DiffDirection bool
 
+	// A ContractEventType indicates how a file contract was resolved.
+	ContractEventType int
+
+	// A ContractEvent reports that a block resolved a file contract. Payouts
+	// are the outputs created by the resolution, which mature
+	// types.MaturityDelay blocks after Height. If the block is reverted, the
+	// event is reported again with a Direction of DiffRevert.
+	ContractEvent struct {
+		Direction DiffDirection
+		Type      ContractEventType
+		ID        types.FileContractID
+		Height    types.BlockHeight
+		Payouts   []types.SiacoinOutput
+	}
+
+	// ConsensusMetrics is a snapshot of counters describing the work done by
+	// the consensus set since it was started.
+	ConsensusMetrics struct {
+		// BlocksAccepted is the number of blocks added to the block tree,
+		// including blocks on forks that were never the current path.
+		BlocksAccepted uint64
+
+		// Reorgs is the number of times that blocks were reverted from the
+		// current path, and DeepestReorg is the most blocks that were
+		// reverted at once.
+		Reorgs       uint64
+		DeepestReorg types.BlockHeight
+
+		// ValidationTime is the total time spent validating and applying
+		// blocks.
+		ValidationTime time.Duration
+	}
+
+	// A UTXOEntry is an unspent siacoin output along with its id.
+	UTXOEntry struct {
+		ID            types.SiacoinOutputID
+		SiacoinOutput types.SiacoinOutput
+	}
+
 	// A ConsensusSetSubscriber is an object that receives updates to the consensus
 	// set every time there is a change in consensus.
 	ConsensusSetSubscriber interface {
@@ -111,6 +213,11 @@ type (
 		// consensus set in the recent change.
 		SiafundPoolDiffs []SiafundPoolDiff
 
+		// ContractEvents lists the file contracts resolved by the reverted
+		// and applied blocks, in the same order as the diffs. Events from
+		// reverted blocks have a Direction of DiffRevert.
+		ContractEvents []ContractEvent
+
 		// ChildTarget defines the target of any block that would be the child
 		// of the block most recently appended to the consensus set.
 		ChildTarget types.Target
@@ -186,10 +293,40 @@ type (
 		// still be returned.
 		AcceptBlock(types.Block) error
 
+		// AcceptBlockBody supplies the miner payouts and transactions for a
+		// header previously accepted by AcceptHeader, and adds the resulting
+		// block to consensus.
+		AcceptBlockBody(types.BlockID, []types.SiacoinOutput, []types.Transaction) error
+
+		// AcceptBlocks adds a slice of blocks to consensus in order, stopping
+		// at the first invalid block. The number of blocks from the slice
+		// that reached the block tree is returned; orphans that are still
+		// waiting for their parent are not counted. Accepted blocks are not
+		// relayed to peers.
+		AcceptBlocks([]types.Block) (int, error)
+
+		// AcceptHeader validates a block header and adds it to the header
+		// tree until the block body is supplied through AcceptBlockBody. The
+		// header may build on a block or on a previously accepted header.
+		AcceptHeader(types.BlockHeader) error
+
+		// AddCheckpoint finalizes the block at a height. Blocks that conflict
+		// with the checkpoint are rejected, and forks that would revert the
+		// checkpointed block are refused.
+		AddCheckpoint(types.BlockHeight, types.BlockID) error
+
+		// Balance returns the spendable and locked value of the siacoin
+		// outputs controlled by an unlock hash.
+		Balance(types.UnlockHash) (spendable, locked types.Currency)
+
 		// BlockAtHeight returns the block found at the input height, with a
 		// bool to indicate whether that block exists.
 		BlockAtHeight(types.BlockHeight) (types.Block, bool)
 
+		// BlockByID returns the block with the input id and its height, with
+		// a bool to indicate whether that block exists.
+		BlockByID(types.BlockID) (types.Block, types.BlockHeight, bool)
+
 		// ChildTarget returns the target required to extend the current heaviest
 		// fork. This function is typically used by miners looking to extend the
 		// heaviest fork.
@@ -199,6 +336,15 @@ type (
 		// run any required closing routines.
 		Close() error
 
+		// CoinSupply returns the number of siacoins that exist at the
+		// current block height.
+		CoinSupply() types.Currency
+
+		// ConsensusChecksum returns a hash committing to the current path and
+		// every output, contract, and pool in the consensus set. Consensus sets
+		// at the same block produce identical checksums.
+		ConsensusChecksum() crypto.Hash
+
 		// ConsensusSetSubscribe adds a subscriber to the list of subscribers
 		// and gives them every consensus change that has occurred since the
 		// change with the provided id. There are a few special cases,
@@ -209,10 +355,38 @@ type (
 		// blockchain.
 		CurrentBlock() types.Block
 
+		// CurrentBlockID returns the id of the latest block in the heaviest
+		// known blockchain.
+		CurrentBlockID() types.BlockID
+
+		// CurrentDepth returns the depth of the heaviest known blockchain.
+		CurrentDepth() types.Target
+
+		// CurrentTarget returns the target required to extend the heaviest
+		// known blockchain.
+		CurrentTarget() types.Target
+
+		// DetectEquivocation returns the sets of known blocks that share both
+		// a height and a miner payout address.
+		DetectEquivocation() [][]types.BlockID
+
+		// FileContract returns the open file contract with the given id.
+		FileContract(types.FileContractID) (types.FileContract, bool)
+
+		// FileContractsByAddress returns the open file contracts with a
+		// valid or missed proof output paying the given unlock hash.
+		FileContractsByAddress(types.UnlockHash) map[types.FileContractID]types.FileContract
+
 		// Flush will cause the consensus set to finish all in-progress
 		// routines.
 		Flush() error
 
+		// HeaviestHeader returns the id and height of the heaviest header
+		// accepted through AcceptHeader whose block is not yet known, and
+		// whether its chain would replace the current path. The bool is false
+		// if there are no such headers.
+		HeaviestHeader() (types.BlockID, types.BlockHeight, bool)
+
 		// Height returns the current height of consensus.
 		Height() types.BlockHeight
 
@@ -223,16 +397,45 @@ type (
 		// current path, false otherwise.
 		InCurrentPath(types.BlockID) bool
 
+		// Metrics returns a snapshot of the consensus set's counters.
+		Metrics() ConsensusMetrics
+
 		// MinimumValidChildTimestamp returns the earliest timestamp that is
 		// valid on the current longest fork according to the consensus set. This is
 		// a required piece of information for the miner, who could otherwise be at
 		// risk of mining invalid blocks.
 		MinimumValidChildTimestamp(types.BlockID) (types.Timestamp, bool)
 
+		// RecommendedConfirmations returns the number of confirmations that
+		// a payment of the provided value should have before it is considered
+		// final, taking into account the reorgs observed by the consensus set.
+		RecommendedConfirmations(types.Currency) types.BlockHeight
+
+		// Stalled returns true if no block has been added to the blockchain
+		// for much longer than the target block frequency.
+		Stalled() bool
+
+		// SpendableOutputs returns the ids of the siacoin outputs controlled
+		// by an unlock hash that can be spent in a block at the given height.
+		SpendableOutputs(types.UnlockHash, types.BlockHeight) []types.SiacoinOutputID
+
 		// StorageProofSegment returns the segment to be used in the storage proof for
 		// a given file contract.
 		StorageProofSegment(types.FileContractID) (uint64, error)
 
+		// SubscribeChan returns a channel that receives all future consensus
+		// changes. The channel is closed if the receiver falls behind.
+		SubscribeChan() (<-chan ConsensusChange, error)
+
+		// TargetAtHeight returns the target that the block at the given
+		// height in the current path had to meet. The bool is false for the
+		// genesis block and for heights beyond the current height.
+		TargetAtHeight(types.BlockHeight) (types.Target, bool)
+
+		// TimeSinceLastBlock returns the amount of time that has passed
+		// since the timestamp of the current block.
+		TimeSinceLastBlock() time.Duration
+
 		// TryTransactionSet checks whether the transaction set would be valid if
 		// it were added in the next block. A consensus change is returned
 		// detailing the diffs that would result from the application of the
@@ -243,6 +446,19 @@ type (
 		// allowing for garbage collection and rescanning. If the subscriber is
 		// not found in the subscriber database, no action is taken.
 		Unsubscribe(ConsensusSetSubscriber)
+
+		// UnsubscribeChan stops the delivery of consensus changes to a channel
+		// returned by SubscribeChan, closing the channel.
+		UnsubscribeChan(<-chan ConsensusChange)
+
+		// UTXOSnapshot returns every siacoin output in the current consensus
+		// set, sorted by id, along with a hash committing to the snapshot.
+		// Consensus sets at the same block produce identical snapshots.
+		UTXOSnapshot() ([]UTXOEntry, crypto.Hash)
+
+		// ValidateTransaction checks that a transaction is valid in the
+		// current consensus set without applying it.
+		ValidateTransaction(types.Transaction) error
 	}
 )
 
@@ -3,6 +3,7 @@ package consensus
 import (
 	"bytes"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -13,13 +14,29 @@ import (
 )
 
 var (
-	errDoSBlock        = errors.New("block is known to be invalid")
 	errNoBlockMap      = errors.New("block map is not in database")
 	errInconsistentSet = errors.New("consensus set is not in a consistent state")
-	errOrphan          = errors.New("block has no known parent")
 	errNonLinearChain  = errors.New("block set is not a contiguous chain")
 )
 
+var (
+	// futureBlockCheckInterval is how often the consensus set checks whether
+	// any of the stored future blocks have become acceptable.
+	futureBlockCheckInterval = build.Select(build.Var{
+		Standard: 5 * time.Second,
+		Dev:      time.Second,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// maxFutureBlocks is the maximum number of future blocks that the
+	// consensus set will hold in memory.
+	maxFutureBlocks = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  10,
+	}).(int)
+)
+
 // managedBroadcastBlock will broadcast a block to the consensus set's peers.
 func (cs *ConsensusSet) managedBroadcastBlock(b types.Block) {
 	// broadcast the block header to all peers
@@ -34,7 +51,7 @@ func (cs *ConsensusSet) validateHeaderAndBlock(tx dbTx, b types.Block, id types.
 	// to validate.
 	_, exists := cs.dosBlocks[id]
 	if exists {
-		return nil, errDoSBlock
+		return nil, modules.ErrDoSBlock
 	}
 
 	// Check if the block is already known.
@@ -50,17 +67,34 @@ func (cs *ConsensusSet) validateHeaderAndBlock(tx dbTx, b types.Block, id types.
 	parentID := b.ParentID
 	parentBytes := blockMap.Get(parentID[:])
 	if parentBytes == nil {
-		return nil, errOrphan
+		return nil, modules.ErrOrphan
 	}
 	parent = new(processedBlock)
 	err = cs.marshaler.Unmarshal(parentBytes, parent)
 	if err != nil {
 		return nil, err
 	}
+	// Check that the block does not conflict with a checkpoint. Such a block
+	// can never become valid, so it is remembered as a DoS block, but only if
+	// it meets its target. Otherwise blocks that cost no work to create could
+	// push expensive blocks out of the DoS list.
+	err = cs.checkCheckpoint(parent.Height+1, id)
+	if err != nil {
+		if checkHeaderTarget(b.Header(), parent.ChildTarget) {
+			cs.addDoSBlock(id)
+		}
+		return nil, err
+	}
 	// Check that the timestamp is not too far in the past to be acceptable.
 	minTimestamp := cs.blockRuleHelper.minimumValidChildTimestamp(blockMap, parent)
 
 	err = cs.blockValidator.ValidateBlock(b, id, minTimestamp, parent.ChildTarget, parent.Height+1, cs.log)
+	if err == modules.ErrLargeBlock {
+		// The block id commits to the contents of the block, so a block
+		// that is too large will always be too large. Remember it so that it
+		// does not need to be decoded and measured again.
+		cs.addDoSBlock(id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -82,7 +116,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	id := h.ID()
 	_, exists := cs.dosBlocks[id]
 	if exists {
-		return errDoSBlock
+		return modules.ErrDoSBlock
 	}
 
 	// Check if the block is already known.
@@ -98,7 +132,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	parentID := h.ParentID
 	parentBytes := blockMap.Get(parentID[:])
 	if parentBytes == nil {
-		return errOrphan
+		return modules.ErrOrphan
 	}
 	var parent processedBlock
 	err := cs.marshaler.Unmarshal(parentBytes, &parent)
@@ -106,6 +140,12 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 		return err
 	}
 
+	// Check that the header does not conflict with a checkpoint.
+	err = cs.checkCheckpoint(parent.Height+1, id)
+	if err != nil {
+		return err
+	}
+
 	// Check that the target of the new block is sufficient.
 	if !checkHeaderTarget(h, parent.ChildTarget) {
 		return modules.ErrBlockUnsolved
@@ -117,7 +157,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	// Check that the timestamp is not too far in the past to be acceptable.
 	minTimestamp := cs.blockRuleHelper.minimumValidChildTimestamp(blockMap, &parent)
 	if minTimestamp > h.Timestamp {
-		return errEarlyTimestamp
+		return modules.ErrEarlyTimestamp
 	}
 
 	// Check if the block is in the extreme future. We make a distinction between
@@ -125,7 +165,7 @@ func (cs *ConsensusSet) validateHeader(tx dbTx, h types.BlockHeader) error {
 	// the extreme future arrives, this block will no longer be a part of the
 	// longest fork because it will have been ignored by all of the miners.
 	if h.Timestamp > types.CurrentTimestamp()+types.ExtremeFutureThreshold {
-		return errExtremeFutureTimestamp
+		return modules.ErrExtremeFutureTimestamp
 	}
 
 	// We do not check if the header is in the near future here, because we want
@@ -157,6 +197,14 @@ func (cs *ConsensusSet) addBlockToTree(tx *bolt.Tx, b types.Block, parent *proce
 		return changeEntry{}, modules.ErrNonExtendingBlock
 	}
 
+	// Refuse to move onto a fork that conflicts with a checkpoint. The check
+	// is made here rather than in forkBlockchain, as consistency checks use
+	// forkBlockchain to temporarily revert the current block.
+	err = cs.checkForkCheckpoints(tx, backtrackToCurrentPath(tx, newNode))
+	if err != nil {
+		return changeEntry{}, err
+	}
+
 	// Fork the blockchain and put the new heaviest block at the tip of the
 	// chain.
 	var revertedBlocks, appliedBlocks []*processedBlock
@@ -177,32 +225,71 @@ func (cs *ConsensusSet) addBlockToTree(tx *bolt.Tx, b types.Block, parent *proce
 	return ce, nil
 }
 
-// threadedSleepOnFutureBlock will sleep until the timestamp of a future block
-// has arrived.
-//
-// TODO: An attacker can broadcast a future block multiple times, resulting in a
-// goroutine spinup for each future block.  Need to prevent that.
-//
-// TODO: An attacker could produce a very large number of future blocks,
-// consuming memory. Need to prevent that.
-func (cs *ConsensusSet) threadedSleepOnFutureBlock(b types.Block) {
-	// Add this thread to the threadgroup.
+// addFutureBlock stores a block whose timestamp is too far in the future to
+// be accepted right now, so that it can be tried again once its timestamp is
+// within the future threshold. Blocks are dropped if the future block pool is
+// full. The caller must hold cs.mu.
+func (cs *ConsensusSet) addFutureBlock(b types.Block, id types.BlockID) {
+	if len(cs.futureBlocks) >= maxFutureBlocks {
+		return
+	}
+	cs.futureBlocks[id] = b
+}
+
+// ProcessFutureBlocks tries to accept every stored future block whose
+// timestamp is now within the future threshold. It is called periodically by
+// the consensus set, but is safe to call at any time.
+func (cs *ConsensusSet) ProcessFutureBlocks() {
 	err := cs.tg.Add()
 	if err != nil {
 		return
 	}
 	defer cs.tg.Done()
 
-	// Perform a soft-sleep while we wait for the block to become valid.
-	select {
-	case <-cs.tg.StopChan():
-		return
-	case <-time.After(time.Duration(b.Timestamp-(types.CurrentTimestamp()+types.FutureThreshold)) * time.Second):
-		_, err := cs.managedAcceptBlocks([]types.Block{b})
+	// Pull the blocks that have become acceptable out of the pool.
+	cs.mu.Lock()
+	now := cs.clock.Now()
+	var ready []types.Block
+	for id, b := range cs.futureBlocks {
+		if b.Timestamp <= now+types.FutureThreshold {
+			ready = append(ready, b)
+			delete(cs.futureBlocks, id)
+		}
+	}
+	cs.mu.Unlock()
+
+	// Accept the blocks in timestamp order, so that a parent is usually
+	// accepted before its children.
+	sort.Slice(ready, func(i, j int) bool {
+		return ready[i].Timestamp < ready[j].Timestamp
+	})
+	for _, b := range ready {
+		chainExtended, err := cs.managedAcceptBlocks([]types.Block{b})
 		if err != nil {
 			cs.log.Debugln("WARN: failed to accept a future block:", err)
 		}
-		cs.managedBroadcastBlock(b)
+		if chainExtended {
+			cs.managedBroadcastBlock(b)
+		}
+	}
+}
+
+// threadedProcessFutureBlocks periodically calls ProcessFutureBlocks until
+// the consensus set is stopped.
+func (cs *ConsensusSet) threadedProcessFutureBlocks() {
+	err := cs.tg.Add()
+	if err != nil {
+		return
+	}
+	defer cs.tg.Done()
+
+	for {
+		select {
+		case <-cs.tg.StopChan():
+			return
+		case <-time.After(futureBlockCheckInterval):
+		}
+		cs.ProcessFutureBlocks()
 	}
 }
 
@@ -211,17 +298,51 @@ func (cs *ConsensusSet) threadedSleepOnFutureBlock(b types.Block) {
 // returned but the blocks are still kept in memory. If the blocks extend a fork
 // such that the fork becomes the longest currently known chain, the consensus
 // set will reorganize itself to recognize the new longest fork. Accepted
-// blocks are not relayed.
+// blocks are not relayed. Orphan blocks that were waiting on any of the
+// accepted blocks are accepted as well, and are relayed if they extend the
+// longest chain.
 //
 // Typically AcceptBlock should be used so that the accepted block is relayed.
 // This method is typically only be used when there would otherwise be multiple
 // consecutive calls to AcceptBlock with each successive call accepting the
 // child block of the previous call.
 func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExtended bool, err error) {
-	// Grab a lock on the consensus set.
+	// Check the transactions for standalone validity before grabbing the
+	// lock, so that several blocks can be verified at once.
+	start := time.Now()
+	prevalidated := cs.prevalidateBlocks(blocks)
+	validationTime := time.Since(start)
+
 	cs.mu.Lock()
-	defer cs.mu.Unlock()
+	start = time.Now()
+	cs.prevalidatedBlocks = prevalidated
+	chainExtended, added, err := cs.acceptBlocks(blocks)
+	cs.prevalidatedBlocks = nil
+	cs.metrics.BlocksAccepted += uint64(len(added))
+	cs.metrics.ValidationTime += validationTime + time.Since(start)
+	cs.mu.Unlock()
+
+	// Try to attach any orphans that were waiting on the added blocks. The
+	// lock is not held here, as each orphan is accepted with its own call.
+	// The caller only knows about the blocks that it provided, so the orphans
+	// that extended the chain are relayed here.
+	extending := cs.managedAcceptOrphans(added)
+	if len(extending) > 0 {
+		chainExtended = true
+		if err == modules.ErrNonExtendingBlock {
+			err = nil
+		}
+	}
+	for _, b := range extending {
+		cs.managedBroadcastBlock(b)
+	}
+	return chainExtended, err
+}
 
+// acceptBlocks is the locked implementation of managedAcceptBlocks. In
+// addition to whether the chain was extended, it returns the ids of the blocks
+// that were added to the block tree, so that their orphans can be processed.
+func (cs *ConsensusSet) acceptBlocks(blocks []types.Block) (blockchainExtended bool, added []types.BlockID, err error) {
 	// Make sure that blocks are consecutive. Though this isn't a strict
 	// requirement, if blocks are not consecutive then it becomes a lot harder
 	// to maintain correcetness when adding multiple blocks in a single tx.
@@ -231,7 +352,7 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 	for i := 0; i < len(blocks); i++ {
 		blockIDs = append(blockIDs, blocks[i].ID())
 		if i > 0 && blocks[i].ParentID != blockIDs[i-1] {
-			return false, errNonLinearChain
+			return false, nil, errNonLinearChain
 		}
 	}
 
@@ -249,9 +370,18 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 				// Skip over known blocks.
 				continue
 			}
-			if err == errFutureTimestamp {
+			if err == modules.ErrFutureTimestamp {
 				// Queue the block to be tried again if it is a future block.
-				go cs.threadedSleepOnFutureBlock(blocks[i])
+				cs.addFutureBlock(blocks[i], blockIDs[i])
+			}
+			if err == modules.ErrOrphan {
+				// Hold on to the block until its parent arrives.
+				cs.addOrphan(tx, blocks[i], blockIDs[i])
+			}
+			if err != nil && err != modules.ErrFutureTimestamp && err != modules.ErrOrphan {
+				// The block is invalid, so its header and the headers
+				// building on it can never be completed.
+				cs.removeHeaders([]types.BlockID{blockIDs[i]})
 			}
 			if err != nil {
 				return err
@@ -267,6 +397,7 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 				err = nil
 			}
 			if err != nil {
+				cs.removeHeaders([]types.BlockID{blockIDs[i]})
 				return err
 			}
 			// Sanity check - If reverted blocks is zero, applied blocks should also
@@ -277,14 +408,19 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 			// Append to the set of changes, and append the valid block.
 			validBlocks = append(validBlocks, blocks[i])
 			parents = append(parents, parent)
+			// The block's header no longer needs to wait for a body.
+			delete(cs.headers, blockIDs[i])
 		}
 		return nil
 	})
+	for _, b := range validBlocks {
+		added = append(added, b.ID())
+	}
 	if setErr != nil {
 		// Check if any blocks were valid.
 		if len(validBlocks) < 1 {
 			// Nothing more to do, the first block was invalid.
-			return false, setErr
+			return false, nil, setErr
 		}
 
 		// At least some of the blocks were valid. Add the valid blocks before
@@ -312,13 +448,13 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 		// reached. If it is, return early because both attempts to add blocks
 		// have failed.
 		if err != nil {
-			return false, err
+			return false, nil, err
 		}
 	}
 
 	// Stop here if the blocks did not extend the longest blockchain.
 	if !chainExtended {
-		return false, modules.ErrNonExtendingBlock
+		return false, added, modules.ErrNonExtendingBlock
 	}
 
 	// Sanity check - if we get here, len(changes) should be non-zero.
@@ -329,6 +465,9 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 	// Update the subscribers with all of the consensus changes. First combine
 	// the changes into a single set.
 	for _, change := range changes {
+		if len(change.RevertedBlocks) > 0 {
+			cs.recordReorg(types.BlockHeight(len(change.RevertedBlocks)))
+		}
 		cs.updateSubscribers(change)
 	}
 
@@ -336,9 +475,9 @@ func (cs *ConsensusSet) managedAcceptBlocks(blocks []types.Block) (blockchainExt
 	// provided, then the setErr is not going to be nil. Return the set error to
 	// the caller.
 	if setErr != nil {
-		return chainExtended, setErr
+		return chainExtended, added, setErr
 	}
-	return chainExtended, nil
+	return chainExtended, added, nil
 }
 
 // AcceptBlock will try to add a block to the consensus set. If the block does
@@ -364,3 +503,71 @@ func (cs *ConsensusSet) AcceptBlock(b types.Block) error {
 	}
 	return nil
 }
+
+// AcceptBlocks adds the provided blocks to the consensus set in order,
+// stopping at the first block that is rejected. The number of blocks from the
+// slice that are in the block tree when AcceptBlocks returns is reported,
+// which does not include blocks that are still held as orphans or any blocks
+// after the rejected block. Blocks that are already known or that are on a
+// fork lighter than the current chain are not treated as failures, so that a
+// heavier fork later in the slice can still cause a reorg. Blocks whose parent
+// has not been seen yet are held as orphans, and are attached if their parent
+// appears later in the slice.
+//
+// Each run of consecutive blocks in the slice is accepted in a single
+// database transaction while holding the lock once, which is much faster
+// than accepting the blocks one at a time. Unlike AcceptBlock, the accepted
+// blocks are not relayed to peers, except for blocks that were held as
+// orphans and extend the longest chain once their parent arrives.
+func (cs *ConsensusSet) AcceptBlocks(blocks []types.Block) (accepted int, err error) {
+	if err := cs.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer cs.tg.Done()
+
+	var processed []types.BlockID
+	for len(blocks) > 0 {
+		// Split off the run of consecutive blocks at the front of the slice.
+		ids := []types.BlockID{blocks[0].ID()}
+		for len(ids) < len(blocks) && blocks[len(ids)].ParentID == ids[len(ids)-1] {
+			ids = append(ids, blocks[len(ids)].ID())
+		}
+		run := blocks[:len(ids)]
+		blocks = blocks[len(ids):]
+		processed = append(processed, ids...)
+
+		_, err = cs.managedAcceptBlocks(run)
+		if err == modules.ErrOrphan {
+			// Only the first block of the run was held as an orphan. The rest
+			// of the run descends from it, so hold those as orphans too.
+			for _, b := range run[1:] {
+				_, _ = cs.managedAcceptBlocks([]types.Block{b})
+			}
+			err = nil
+		}
+		if err == modules.ErrNonExtendingBlock {
+			err = nil
+		}
+		if err != nil {
+			break
+		}
+	}
+	return cs.managedCountKnownBlocks(processed), err
+}
+
+// managedCountKnownBlocks returns how many of the provided blocks are in the
+// block tree.
+func (cs *ConsensusSet) managedCountKnownBlocks(ids []types.BlockID) (known int) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		blockMap := tx.Bucket(BlockMap)
+		for _, id := range ids {
+			if blockMap.Get(id[:]) != nil {
+				known++
+			}
+		}
+		return nil
+	})
+	return known
+}
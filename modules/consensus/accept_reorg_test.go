@@ -323,8 +323,99 @@ func TestBuriedBadFork(t *testing.T) {
 		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(pb.Height + 1)}},
 	}
 	block, _ = cst.miner.SolveBlock(block, parent.ChildTarget) // okay because the target will not change
+	checksum := cst.cs.dbConsensusChecksum()
 	err = cst.cs.AcceptBlock(block)
 	if err == nil {
 		t.Fatal("a bad block failed to cause an error")
 	}
+
+	// The failed fork should leave the consensus set exactly as it was.
+	if cst.cs.dbCurrentProcessedBlock().Block.ID() != pb.Block.ID() {
+		t.Fatal("failed fork changed the current block")
+	}
+	if cst.cs.dbConsensusChecksum() != checksum {
+		t.Fatal("failed fork changed the consensus set")
+	}
+}
+
+// TestOrphanedSubsidyRemoved mines a block on one consensus set, lets its
+// miner payout mature, and then moves the consensus set onto a heavier fork
+// that does not contain the block. The orphaned payout should be removed, and
+// the consensus set should end up identical to the one that mined the fork.
+func TestOrphanedSubsidyRemoved(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := createConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Put both consensus sets on the same chain.
+	for cst1.cs.Height() <= cst2.cs.Height() {
+		_, err := cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := types.BlockHeight(1); i <= cst1.cs.Height(); i++ {
+		b, _ := cst1.cs.BlockAtHeight(i)
+		_ = cst2.cs.AcceptBlock(b)
+	}
+	if cst1.cs.CurrentBlockID() != cst2.cs.CurrentBlockID() {
+		t.Fatal("consensus sets are not on the same block")
+	}
+
+	// Mine a block on cst1 and wait for its payout to mature.
+	orphan, err := cst1.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := types.BlockHeight(0); i < types.MaturityDelay; i++ {
+		_, err = cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	payoutID := orphan.MinerPayoutID(0)
+	_, err = cst1.cs.dbGetSiacoinOutput(payoutID)
+	if err != nil {
+		t.Fatal("miner payout did not mature:", err)
+	}
+
+	// Build a heavier fork on cst2 and move cst1 onto it.
+	for cst2.cs.Height() <= cst1.cs.Height() {
+		_, err := cst2.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	forkStart := cst1.cs.Height() - types.MaturityDelay
+	for i := forkStart; i <= cst2.cs.Height(); i++ {
+		b, _ := cst2.cs.BlockAtHeight(i)
+		_ = cst1.cs.AcceptBlock(b)
+	}
+	if cst1.cs.CurrentBlockID() != cst2.cs.CurrentBlockID() {
+		t.Fatal("cst1 did not move onto the heavier fork")
+	}
+
+	_, err = cst1.cs.dbGetSiacoinOutput(payoutID)
+	if err == nil {
+		t.Fatal("orphaned miner payout is still in the consensus set")
+	}
+	if cst1.cs.dbConsensusChecksum() != cst2.cs.dbConsensusChecksum() {
+		t.Fatal("consensus sets differ after the reorg")
+	}
+	_, hash1 := cst1.cs.UTXOSnapshot()
+	_, hash2 := cst2.cs.UTXOSnapshot()
+	if hash1 != hash2 {
+		t.Fatal("siacoin output sets differ after the reorg")
+	}
 }
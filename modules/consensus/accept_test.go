@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
@@ -229,7 +230,7 @@ func TestUnitValidateHeaderAndBlock(t *testing.T) {
 			},
 			earliestValidTimestamp: mockValidBlock.Timestamp,
 			marshaler:              parentBlockUnmarshaler,
-			errWant:                errDoSBlock,
+			errWant:                modules.ErrDoSBlock,
 			msg:                    "validateHeaderAndBlock should reject known bad blocks",
 		},
 		{
@@ -237,7 +238,7 @@ func TestUnitValidateHeaderAndBlock(t *testing.T) {
 			dosBlocks:              make(map[types.BlockID]struct{}),
 			earliestValidTimestamp: mockValidBlock.Timestamp,
 			marshaler:              parentBlockUnmarshaler,
-			errWant:                errOrphan,
+			errWant:                modules.ErrOrphan,
 			msg:                    "validateHeaderAndBlock should reject a block if its parent block does not appear in the block database",
 		},
 		{
@@ -257,8 +258,8 @@ func TestUnitValidateHeaderAndBlock(t *testing.T) {
 			},
 			earliestValidTimestamp: mockInvalidBlock.Timestamp,
 			marshaler:              parentBlockUnmarshaler,
-			validateBlockErr:       errBadMinerPayouts,
-			errWant:                errBadMinerPayouts,
+			validateBlockErr:       modules.ErrBadMinerPayouts,
+			errWant:                modules.ErrBadMinerPayouts,
 			msg:                    "validateHeaderAndBlock should reject a block if ValidateBlock returns an error for the block",
 		},
 		{
@@ -363,7 +364,7 @@ func TestUnitValidateHeader(t *testing.T) {
 			blockMapPairs:          serializedParentBlockMap,
 			earliestValidTimestamp: mockValidBlock.Timestamp,
 			marshaler:              parentBlockUnmarshaler,
-			errWant:                errDoSBlock,
+			errWant:                modules.ErrDoSBlock,
 			msg:                    "validateHeader should reject known bad blocks",
 		},
 		// Test that blocks are rejected if a block map doesn't exist.
@@ -393,7 +394,7 @@ func TestUnitValidateHeader(t *testing.T) {
 			dosBlocks:              make(map[types.BlockID]struct{}),
 			earliestValidTimestamp: mockValidBlock.Timestamp,
 			marshaler:              parentBlockUnmarshaler,
-			errWant:                errOrphan,
+			errWant:                modules.ErrOrphan,
 			msg:                    "validateHeader should reject a block if its parent block does not appear in the block database",
 		},
 		// Test that blocks whose parents don't unmarshal are rejected.
@@ -413,7 +414,7 @@ func TestUnitValidateHeader(t *testing.T) {
 			blockMapPairs:          serializedParentBlockMap,
 			earliestValidTimestamp: mockValidBlock.Timestamp + 1,
 			marshaler:              parentBlockHighTargetUnmarshaler,
-			errWant:                errEarlyTimestamp,
+			errWant:                modules.ErrEarlyTimestamp,
 			msg:                    "validateHeader should fail when the header's timestamp is too early",
 		},
 		// Test that headers in the extreme future are rejected.
@@ -425,7 +426,7 @@ func TestUnitValidateHeader(t *testing.T) {
 			dosBlocks:     make(map[types.BlockID]struct{}),
 			blockMapPairs: serializedParentBlockMap,
 			marshaler:     parentBlockHighTargetUnmarshaler,
-			errWant:       errExtremeFutureTimestamp,
+			errWant:       modules.ErrExtremeFutureTimestamp,
 			msg:           "validateHeader should fail when the header's timestamp is in the extreme future",
 		},
 		// Test that headers in the near future are not rejected.
@@ -523,15 +524,68 @@ func TestIntegrationDoSBlockHandling(t *testing.T) {
 	block.Transactions = append(block.Transactions, txnSet...)
 	dosBlock, _ := cst.miner.SolveBlock(block, target)
 	err = cst.cs.AcceptBlock(dosBlock)
-	if err != errSiacoinInputOutputMismatch {
-		t.Fatalf("expected %v, got %v", errSiacoinInputOutputMismatch, err)
+	if err != modules.ErrSiacoinInputOutputMismatch {
+		t.Fatalf("expected %v, got %v", modules.ErrSiacoinInputOutputMismatch, err)
 	}
 
 	// Submit the same block a second time. The complaint should be that the
 	// block is already known to be invalid.
 	err = cst.cs.AcceptBlock(dosBlock)
-	if err != errDoSBlock {
-		t.Fatalf("expected %v, got %v", errDoSBlock, err)
+	if err != modules.ErrDoSBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrDoSBlock, err)
+	}
+}
+
+// TestDuplicateSpendBlock submits a block in which two transactions spend the
+// same output, checking that the block is rejected before it is applied.
+func TestDuplicateSpendBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	txnBuilder := cst.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(types.NewCurrency64(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txnBuilder.AddSiacoinOutput(types.SiacoinOutput{Value: types.NewCurrency64(50)})
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a second transaction that spends the same inputs as the last
+	// transaction in the set.
+	doubleSpend := txnSet[len(txnSet)-1]
+	doubleSpend.ArbitraryData = [][]byte{[]byte("double spend")}
+	block, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Transactions = append(block.Transactions, txnSet...)
+	block.Transactions = append(block.Transactions, doubleSpend)
+	block, _ = cst.miner.SolveBlock(block, target)
+
+	parentID := cst.cs.CurrentBlockID()
+	err = cst.cs.AcceptBlock(block)
+	if err != errDuplicateSpend {
+		t.Fatalf("expected %v, got %v", errDuplicateSpend, err)
+	}
+	if cst.cs.CurrentBlockID() != parentID {
+		t.Fatal("block with a duplicate spend extended the chain")
+	}
+
+	// Nothing from the block should have been applied, so the original
+	// transactions should still be valid on their own.
+	err = cst.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
@@ -616,12 +670,12 @@ func TestOrphanHandling(t *testing.T) {
 	// consensus set performs.
 	orphan := types.Block{}
 	err = cst.cs.AcceptBlock(orphan)
-	if err != errOrphan {
-		t.Fatalf("expected %v, got %v", errOrphan, err)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
 	}
 	err = cst.cs.AcceptBlock(orphan)
-	if err != errOrphan {
-		t.Fatalf("expected %v, got %v", errOrphan, err)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
 	}
 }
 
@@ -677,8 +731,127 @@ func TestMinerPayoutHandling(t *testing.T) {
 	block.MinerPayouts = append(block.MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(1)})
 	solvedBlock, _ := cst.miner.SolveBlock(block, target)
 	err = cst.cs.AcceptBlock(solvedBlock)
-	if err != errBadMinerPayouts {
-		t.Fatalf("expected %v, got %v", errBadMinerPayouts, err)
+	if err != modules.ErrBadMinerPayouts {
+		t.Fatalf("expected %v, got %v", modules.ErrBadMinerPayouts, err)
+	}
+}
+
+// TestMinerPayoutMaturity checks that miner payouts wait in the delayed
+// siacoin output set, keyed by maturity height, until exactly MaturityDelay
+// blocks have passed, and that maturing is undone when the maturing block is
+// reverted.
+func TestMinerPayoutMaturity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Mine a block and find its payout in the delayed outputs.
+	block, err := cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payoutID := block.MinerPayoutID(0)
+	maturityHeight := cst.cs.Height() + types.MaturityDelay
+	if _, err := cst.cs.dbGetDSCO(maturityHeight, payoutID); err != nil {
+		t.Fatal("miner payout is not in the delayed output set:", err)
+	}
+
+	// The payout should not be spendable until the maturity height.
+	for cst.cs.Height() < maturityHeight-1 {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cst.cs.dbGetSiacoinOutput(payoutID); err != errNilItem {
+			t.Fatal("miner payout matured early at height", cst.cs.Height())
+		}
+	}
+	parent := cst.cs.dbCurrentProcessedBlock()
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.Height() != maturityHeight {
+		t.Fatal("wrong height:", cst.cs.Height())
+	}
+	if _, err := cst.cs.dbGetSiacoinOutput(payoutID); err != nil {
+		t.Fatal("miner payout did not mature at the maturity height:", err)
+	}
+	if _, err := cst.cs.dbGetDSCO(maturityHeight, payoutID); err != errNilItem {
+		t.Fatal("matured payout is still in the delayed output set")
+	}
+
+	// Revert the maturing block. The payout should go back to the delayed
+	// output set.
+	cst.cs.dbRevertToNode(parent)
+	if _, err := cst.cs.dbGetSiacoinOutput(payoutID); err != errNilItem {
+		t.Fatal("miner payout is still spendable after reverting the maturing block")
+	}
+	if _, err := cst.cs.dbGetDSCO(maturityHeight, payoutID); err != nil {
+		t.Fatal("miner payout was not returned to the delayed output set:", err)
+	}
+}
+
+// TestSpendMinerPayoutMaturity checks that a transaction spending a miner
+// payout is rejected until the payout has matured.
+func TestSpendMinerPayoutMaturity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Mine a block that pays out to an address that needs no signatures.
+	uc := types.UnlockConditions{}
+	block, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.MinerPayouts[0].UnlockHash = uc.UnlockHash()
+	solvedBlock, _ := cst.miner.SolveBlock(block, target)
+	err = cst.cs.AcceptBlock(solvedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	maturityHeight := cst.cs.Height() + types.MaturityDelay
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         solvedBlock.MinerPayoutID(0),
+			UnlockConditions: uc,
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      solvedBlock.MinerPayouts[0].Value,
+			UnlockHash: randAddress(),
+		}},
+	}
+
+	// The payout cannot be spent by any block up to and including the block
+	// that matures it.
+	for cst.cs.Height() < maturityHeight {
+		_, err = cst.cs.TryTransactionSet([]types.Transaction{txn})
+		if err != modules.ErrMissingSiacoinOutput {
+			t.Fatalf("expected %v at height %v, got %v", modules.ErrMissingSiacoinOutput, cst.cs.Height(), err)
+		}
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Once matured, the payout can be spent.
+	_, err = cst.cs.TryTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal("matured miner payout could not be spent:", err)
 	}
 }
 
@@ -707,8 +880,8 @@ func TestEarlyTimestampHandling(t *testing.T) {
 	block.Timestamp = minTimestamp - 1
 	solvedBlock, _ := cst.miner.SolveBlock(block, target)
 	err = cst.cs.AcceptBlock(solvedBlock)
-	if err != errEarlyTimestamp {
-		t.Fatalf("expected %v, got %v", errEarlyTimestamp, err)
+	if err != modules.ErrEarlyTimestamp {
+		t.Fatalf("expected %v, got %v", modules.ErrEarlyTimestamp, err)
 	}
 }
 
@@ -734,8 +907,8 @@ func TestFutureTimestampHandling(t *testing.T) {
 	block.Timestamp = types.CurrentTimestamp() + 2 + types.FutureThreshold
 	solvedBlock, _ := cst.miner.SolveBlock(block, target)
 	err = cst.cs.AcceptBlock(solvedBlock)
-	if err != errFutureTimestamp {
-		t.Fatalf("expected %v, got %v", errFutureTimestamp, err)
+	if err != modules.ErrFutureTimestamp {
+		t.Fatalf("expected %v, got %v", modules.ErrFutureTimestamp, err)
 	}
 
 	// Poll the consensus set until the future block appears.
@@ -752,6 +925,75 @@ func TestFutureTimestampHandling(t *testing.T) {
 	}
 }
 
+// TestProcessFutureBlocks checks that a future block is deferred instead of
+// being discarded, and that ProcessFutureBlocks accepts it once the clock has
+// advanced far enough.
+func TestProcessFutureBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// setClock sets the time seen by the consensus set.
+	setClock := func(now types.Timestamp) {
+		cst.cs.mu.Lock()
+		cst.cs.clock = mockClock{now: now}
+		cst.cs.blockValidator = stdBlockValidator{
+			clock:     mockClock{now: now},
+			marshaler: stdMarshaler{},
+		}
+		cst.cs.mu.Unlock()
+	}
+
+	// Create a block that is slightly too far in the future for the mock
+	// clock, but not in the extreme future.
+	block, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	solvedBlock, _ := cst.miner.SolveBlock(block, target)
+	setClock(solvedBlock.Timestamp - types.FutureThreshold - 2)
+	err = cst.cs.AcceptBlock(solvedBlock)
+	if err != modules.ErrFutureTimestamp {
+		t.Fatalf("expected %v, got %v", modules.ErrFutureTimestamp, err)
+	}
+	cst.cs.mu.Lock()
+	_, deferred := cst.cs.futureBlocks[solvedBlock.ID()]
+	_, dos := cst.cs.dosBlocks[solvedBlock.ID()]
+	cst.cs.mu.Unlock()
+	if !deferred {
+		t.Fatal("future block was not deferred")
+	}
+	if dos {
+		t.Fatal("future block was marked as invalid")
+	}
+
+	// Processing the future blocks before the clock advances should not
+	// accept the block.
+	cst.cs.ProcessFutureBlocks()
+	if _, err := cst.cs.dbGetBlockMap(solvedBlock.ID()); err == nil {
+		t.Fatal("future block was accepted too early")
+	}
+
+	// Advance the clock and process the future blocks again.
+	setClock(solvedBlock.Timestamp - types.FutureThreshold)
+	cst.cs.ProcessFutureBlocks()
+	if cst.cs.CurrentBlock().ID() != solvedBlock.ID() {
+		t.Fatal("future block was not accepted after the clock advanced")
+	}
+	cst.cs.mu.Lock()
+	numFuture := len(cst.cs.futureBlocks)
+	cst.cs.mu.Unlock()
+	if numFuture != 0 {
+		t.Fatal("future block was not removed from the pool")
+	}
+}
+
 // TestExtremeFutureTimestampHandling checks that blocks in the extreme future
 // are rejected.
 func TestExtremeFutureTimestampHandling(t *testing.T) {
@@ -773,8 +1015,8 @@ func TestExtremeFutureTimestampHandling(t *testing.T) {
 	block.Timestamp = types.CurrentTimestamp() + 2 + types.ExtremeFutureThreshold
 	solvedBlock, _ := cst.miner.SolveBlock(block, target)
 	err = cst.cs.AcceptBlock(solvedBlock)
-	if err != errExtremeFutureTimestamp {
-		t.Fatalf("expected %v, got %v", errFutureTimestamp, err)
+	if err != modules.ErrExtremeFutureTimestamp {
+		t.Fatalf("expected %v, got %v", modules.ErrFutureTimestamp, err)
 	}
 }
 
@@ -1167,3 +1409,214 @@ func TestChainedAcceptBlock(t *testing.T) {
 		}
 	}
 }
+
+// TestBlockSizeLimitBoundary checks that a block exactly at the size limit is
+// accepted, and that a block one byte over the limit is rejected and
+// remembered as a DoS block.
+func TestBlockSizeLimitBoundary(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// paddedBlock returns a solved block on top of the current block whose
+	// encoded size is exactly 'size'. The padding is split over two
+	// transactions to stay within the transaction size limit.
+	paddedBlock := func(size uint64) types.Block {
+		block, target, err := cst.miner.BlockForWork()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Transactions = []types.Transaction{
+			{ArbitraryData: [][]byte{{}}},
+			{ArbitraryData: [][]byte{{}}},
+		}
+		padding := size - uint64(len(encoding.Marshal(block)))
+		block.Transactions[0].ArbitraryData[0] = make([]byte, padding/2)
+		block.Transactions[1].ArbitraryData[0] = make([]byte, padding-padding/2)
+		if uint64(len(encoding.Marshal(block))) != size {
+			t.Fatal("padded block has the wrong size")
+		}
+		solvedBlock, _ := cst.miner.SolveBlock(block, target)
+		return solvedBlock
+	}
+
+	// A block one byte over the limit should be rejected and remembered.
+	tooLarge := paddedBlock(types.BlockSizeLimit + 1)
+	err = cst.cs.AcceptBlock(tooLarge)
+	if err != modules.ErrLargeBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrLargeBlock, err)
+	}
+	err = cst.cs.AcceptBlock(tooLarge)
+	if err != modules.ErrDoSBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrDoSBlock, err)
+	}
+
+	// A block exactly at the limit should be accepted.
+	atLimit := paddedBlock(types.BlockSizeLimit)
+	err = cst.cs.AcceptBlock(atLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.CurrentBlock().ID() != atLimit.ID() {
+		t.Fatal("block at the size limit did not extend the chain")
+	}
+}
+
+// TestAcceptBlocks replays a chain into fresh consensus sets, checking that the
+// resulting tip matches, that lighter forks and out-of-order blocks are
+// handled, and that replay stops at the first invalid block.
+func TestAcceptBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	for i := 0; i < 50; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var blocks []types.Block
+	for height := types.BlockHeight(1); height <= cst.cs.Height(); height++ {
+		b, exists := cst.cs.BlockAtHeight(height)
+		if !exists {
+			t.Fatal("missing block at height", height)
+		}
+		blocks = append(blocks, b)
+	}
+
+	// Create a lighter fork that the replayed chain will have to reorg away
+	// from.
+	cstFork, err := blankConsensusSetTester(t.Name() + "-fork")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstFork.Close()
+	var fork []types.Block
+	for i := 0; i < 5; i++ {
+		b, err := cstFork.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork = append(fork, b)
+	}
+
+	// Replay the fork followed by the chain, with one pair of blocks out of
+	// order.
+	replay := append(fork, blocks...)
+	replay[10], replay[11] = replay[11], replay[10]
+	cst2, err := blankConsensusSetTester(t.Name() + "-replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	accepted, err := cst2.cs.AcceptBlocks(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted != len(replay) {
+		t.Fatalf("expected %v blocks to be accepted, got %v", len(replay), accepted)
+	}
+	if cst2.cs.CurrentBlockID() != cst.cs.CurrentBlockID() {
+		t.Fatal("replayed chain has a different tip")
+	}
+	if cst2.cs.dbConsensusChecksum() != cst.cs.dbConsensusChecksum() {
+		t.Fatal("replayed chain has a different consensus checksum")
+	}
+
+	// Replay stops at the first invalid block.
+	invalid := make([]types.Block, len(blocks))
+	copy(invalid, blocks)
+	target, _ := cst.cs.ChildTarget(blocks[19].ID())
+	invalid[20].MinerPayouts = append(invalid[20].MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(1)})
+	invalid[20], _ = cst.miner.SolveBlock(invalid[20], target)
+	cst3, err := blankConsensusSetTester(t.Name() + "-invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst3.Close()
+	accepted, err = cst3.cs.AcceptBlocks(invalid)
+	if err != modules.ErrBadMinerPayouts {
+		t.Fatalf("expected %v, got %v", modules.ErrBadMinerPayouts, err)
+	}
+	if accepted != 20 {
+		t.Fatal("expected 20 blocks to be accepted, got", accepted)
+	}
+	if cst3.cs.CurrentBlockID() != blocks[19].ID() {
+		t.Fatal("tip is not the last block before the invalid block")
+	}
+}
+
+// TestAcceptBlocksCount checks the number of blocks reported by AcceptBlocks
+// for a slice mixing a valid run, a run of orphans, and a run that is
+// rejected part way through.
+func TestAcceptBlocksCount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	start := cst.cs.Height()
+	for i := 0; i < 20; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var blocks []types.Block
+	for height := types.BlockHeight(1); height <= cst.cs.Height(); height++ {
+		b, exists := cst.cs.BlockAtHeight(height)
+		if !exists {
+			t.Fatal("missing block at height", height)
+		}
+		blocks = append(blocks, b)
+	}
+	n := int(start)
+
+	// Make the block after the valid prefix invalid.
+	bad := blocks[n+12]
+	target, _ := cst.cs.ChildTarget(blocks[n+11].ID())
+	bad.MinerPayouts = append(bad.MinerPayouts, types.SiacoinOutput{Value: types.NewCurrency64(1)})
+	bad, _ = cst.miner.SolveBlock(bad, target)
+
+	// The slice holds a valid run up to height n+10, a run of orphans whose
+	// parent is never provided, and a run that is rejected at its third
+	// block.
+	var mixed []types.Block
+	mixed = append(mixed, blocks[:n+10]...)
+	mixed = append(mixed, blocks[n+15:n+18]...)
+	mixed = append(mixed, blocks[n+10], blocks[n+11], bad, blocks[n+13])
+	cst2, err := blankConsensusSetTester(t.Name() + "-mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	accepted, err := cst2.cs.AcceptBlocks(mixed)
+	if err != modules.ErrBadMinerPayouts {
+		t.Fatalf("expected %v, got %v", modules.ErrBadMinerPayouts, err)
+	}
+	if accepted != n+12 {
+		t.Fatalf("expected %v blocks to be accepted, got %v", n+12, accepted)
+	}
+	if cst2.cs.CurrentBlockID() != blocks[n+11].ID() {
+		t.Fatal("tip is not the last block before the invalid block")
+	}
+	if cst2.cs.numOrphanBlocks != 3 {
+		t.Fatal("expected the orphan run to be held, got", cst2.cs.numOrphanBlocks)
+	}
+}
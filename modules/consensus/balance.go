@@ -0,0 +1,108 @@
+package consensus
+
+import (
+	"bytes"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// forEachAddressOutput calls fn on every siacoin output in the consensus set
+// that is controlled by the provided unlock hash, including delayed siacoin
+// outputs. Outputs that are already spendable are reported with a maturity
+// height of 0.
+func forEachAddressOutput(tx *bolt.Tx, uh types.UnlockHash, fn func(types.SiacoinOutputID, types.SiacoinOutput, types.BlockHeight)) error {
+	err := tx.Bucket(SiacoinOutputs).ForEach(func(idBytes, scoBytes []byte) error {
+		var sco types.SiacoinOutput
+		err := encoding.Unmarshal(scoBytes, &sco)
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+		if sco.UnlockHash == uh {
+			var id types.SiacoinOutputID
+			copy(id[:], idBytes)
+			fn(id, sco, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Delayed siacoin outputs are stored in a separate bucket for each
+	// maturity height.
+	return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if !bytes.HasPrefix(name, prefixDSCO) {
+			return nil
+		}
+		var height types.BlockHeight
+		err := encoding.Unmarshal(name[len(prefixDSCO):], &height)
+		if build.DEBUG && err != nil {
+			panic(err)
+		}
+		return b.ForEach(func(idBytes, scoBytes []byte) error {
+			var sco types.SiacoinOutput
+			err := encoding.Unmarshal(scoBytes, &sco)
+			if build.DEBUG && err != nil {
+				panic(err)
+			}
+			if sco.UnlockHash == uh {
+				var id types.SiacoinOutputID
+				copy(id[:], idBytes)
+				fn(id, sco, height)
+			}
+			return nil
+		})
+	})
+}
+
+// Balance returns the value of the siacoin outputs controlled by the provided
+// unlock hash. 'spendable' is the value that can be spent in the next block,
+// and 'locked' is the value of delayed outputs, such as miner payouts, that
+// have not yet reached maturity. Unconfirmed transactions are not considered.
+func (cs *ConsensusSet) Balance(uh types.UnlockHash) (spendable, locked types.Currency) {
+	if err := cs.tg.Add(); err != nil {
+		return types.ZeroCurrency, types.ZeroCurrency
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	spendable, locked = types.ZeroCurrency, types.ZeroCurrency
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		return forEachAddressOutput(tx, uh, func(_ types.SiacoinOutputID, sco types.SiacoinOutput, maturityHeight types.BlockHeight) {
+			if maturityHeight == 0 {
+				spendable = spendable.Add(sco.Value)
+			} else {
+				locked = locked.Add(sco.Value)
+			}
+		})
+	})
+	return spendable, locked
+}
+
+// SpendableOutputs returns the ids of the siacoin outputs controlled by the
+// provided unlock hash that can be spent by a transaction in a block at the
+// provided height, assuming that the current path is extended to that height.
+// Delayed outputs are included once a block at their maturity height would
+// precede the block at 'height'.
+func (cs *ConsensusSet) SpendableOutputs(uh types.UnlockHash, height types.BlockHeight) (ids []types.SiacoinOutputID) {
+	if err := cs.tg.Add(); err != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		return forEachAddressOutput(tx, uh, func(id types.SiacoinOutputID, _ types.SiacoinOutput, maturityHeight types.BlockHeight) {
+			if maturityHeight < height {
+				ids = append(ids, id)
+			}
+		})
+	})
+	return ids
+}
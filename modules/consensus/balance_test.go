@@ -0,0 +1,126 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestBalance funds an address across several blocks, spends part of it, and
+// checks the balance and spendable outputs reported for the address.
+func TestBalance(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Fund an address that can be spent without signatures.
+	uc := types.UnlockConditions{}
+	uh := uc.UnlockHash()
+	expected := types.ZeroCurrency
+	for i := uint64(1); i <= 3; i++ {
+		value := types.SiacoinPrecision.Mul64(i)
+		_, err = cst.wallet.SendSiacoins(value, uh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = expected.Add(value)
+	}
+	spendable, locked := cst.cs.Balance(uh)
+	if !spendable.Equals(expected) || !locked.IsZero() {
+		t.Fatalf("expected %v spendable and 0 locked, got %v and %v", expected, spendable, locked)
+	}
+	ids := cst.cs.SpendableOutputs(uh, cst.cs.Height()+1)
+	if len(ids) != 3 {
+		t.Fatal("wrong number of spendable outputs:", len(ids))
+	}
+
+	// Spend one of the outputs to a different address.
+	sco, err := cst.cs.dbGetSiacoinOutput(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := randAddress()
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         ids[0],
+			UnlockConditions: uc,
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      sco.Value,
+			UnlockHash: dest,
+		}},
+	}
+	err = cst.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = expected.Sub(sco.Value)
+	spendable, _ = cst.cs.Balance(uh)
+	if !spendable.Equals(expected) {
+		t.Fatalf("expected %v spendable after spending, got %v", expected, spendable)
+	}
+	if len(cst.cs.SpendableOutputs(uh, cst.cs.Height()+1)) != 2 {
+		t.Fatal("spent output is still reported as spendable")
+	}
+	if spendable, _ = cst.cs.Balance(dest); !spendable.Equals(sco.Value) {
+		t.Fatalf("expected destination to have %v, got %v", sco.Value, spendable)
+	}
+}
+
+// TestBalanceLocked checks that immature miner payouts are reported as locked
+// until they reach maturity.
+func TestBalanceLocked(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	b, err := cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payout := b.MinerPayouts[0]
+	mpid := b.MinerPayoutID(0)
+	_, locked := cst.cs.Balance(payout.UnlockHash)
+	if locked.Cmp(payout.Value) < 0 {
+		t.Fatalf("expected at least %v locked, got %v", payout.Value, locked)
+	}
+
+	contains := func(ids []types.SiacoinOutputID) bool {
+		for _, id := range ids {
+			if id == mpid {
+				return true
+			}
+		}
+		return false
+	}
+	maturityHeight := cst.cs.Height() + types.MaturityDelay
+	if contains(cst.cs.SpendableOutputs(payout.UnlockHash, cst.cs.Height()+1)) {
+		t.Fatal("immature payout reported as spendable in the next block")
+	}
+	if contains(cst.cs.SpendableOutputs(payout.UnlockHash, maturityHeight)) {
+		t.Fatal("payout reported as spendable in the block that matures it")
+	}
+	if !contains(cst.cs.SpendableOutputs(payout.UnlockHash, maturityHeight+1)) {
+		t.Fatal("payout not reported as spendable after maturity")
+	}
+}
@@ -0,0 +1,79 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestMinimumValidChildTimestampEarlyBlocks checks that the median timestamp
+// rule behaves correctly for chains that are shorter than the median
+// timestamp window, where the window is padded with the genesis timestamp.
+func TestMinimumValidChildTimestampEarlyBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// solvedBlock returns a block on top of the current block with the
+	// provided timestamp.
+	solvedBlock := func(timestamp types.Timestamp) types.Block {
+		block, target, err := cst.miner.BlockForWork()
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.Timestamp = timestamp
+		solved, ok := cst.miner.SolveBlock(block, target)
+		if !ok {
+			t.Fatal("unable to solve block")
+		}
+		return solved
+	}
+
+	// Build a chain from height 1 through 12, checking the rule at every
+	// height.
+	for height := types.BlockHeight(1); height <= 12; height++ {
+		parentID := cst.cs.CurrentBlock().ID()
+		minTimestamp, exists := cst.cs.MinimumValidChildTimestamp(parentID)
+		if !exists {
+			t.Fatal("parent block not found at height", height)
+		}
+		if minTimestamp < types.GenesisTimestamp {
+			t.Fatalf("height %v: minimum timestamp %v is before the genesis timestamp", height, minTimestamp)
+		}
+		if height <= types.BlockHeight(types.MedianTimestampWindow/2) && minTimestamp != types.GenesisTimestamp {
+			t.Fatalf("height %v: minimum timestamp should still be the genesis timestamp", height)
+		}
+
+		// A block that is too early should be rejected.
+		early := solvedBlock(minTimestamp - 1)
+		err = cst.cs.AcceptBlock(early)
+		if err != modules.ErrEarlyTimestamp {
+			t.Fatalf("height %v: expected %v, got %v", height, modules.ErrEarlyTimestamp, err)
+		}
+
+		// Alternate between blocks at exactly the minimum timestamp and
+		// blocks that move the median forward.
+		timestamp := minTimestamp
+		if height%2 == 1 {
+			timestamp = minTimestamp + 1000*types.Timestamp(height)
+		}
+		block := solvedBlock(timestamp)
+		err = cst.cs.AcceptBlock(block)
+		if err != nil {
+			t.Fatalf("height %v: %v", height, err)
+		}
+		if _, exists := cst.cs.dosBlocks[block.ID()]; exists {
+			t.Fatalf("height %v: valid block was marked as invalid", height)
+		}
+		if cst.cs.Height() != height {
+			t.Fatalf("height %v: block was not added to the current path", height)
+		}
+	}
+}
@@ -2,21 +2,12 @@ package consensus
 
 import (
 	"bytes"
-	"errors"
 
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/persist"
 	"github.com/NebulousLabs/Sia/types"
 )
 
-var (
-	errBadMinerPayouts        = errors.New("miner payout sum does not equal block subsidy")
-	errEarlyTimestamp         = errors.New("block timestamp is too early")
-	errExtremeFutureTimestamp = errors.New("block timestamp too far in future, discarded")
-	errFutureTimestamp        = errors.New("block timestamp too far in future, but saved for later use")
-	errLargeBlock             = errors.New("block is too large to be accepted")
-)
-
 // blockValidator validates a Block against a set of block validity rules.
 type blockValidator interface {
 	// ValidateBlock validates a block against a minimum timestamp, a block
@@ -66,7 +57,7 @@ func checkTarget(b types.Block, id types.BlockID, target types.Target) bool {
 func (bv stdBlockValidator) ValidateBlock(b types.Block, id types.BlockID, minTimestamp types.Timestamp, target types.Target, height types.BlockHeight, log *persist.Logger) error {
 	// Check that the timestamp is not too far in the past to be acceptable.
 	if minTimestamp > b.Timestamp {
-		return errEarlyTimestamp
+		return modules.ErrEarlyTimestamp
 	}
 
 	// Check that the target of the new block is sufficient.
@@ -77,7 +68,7 @@ func (bv stdBlockValidator) ValidateBlock(b types.Block, id types.BlockID, minTi
 	// Check that the block is below the size limit.
 	blockSize := len(bv.marshaler.Marshal(b))
 	if uint64(blockSize) > types.BlockSizeLimit {
-		return errLargeBlock
+		return modules.ErrLargeBlock
 	}
 
 	// Check if the block is in the extreme future. We make a distinction between
@@ -85,19 +76,19 @@ func (bv stdBlockValidator) ValidateBlock(b types.Block, id types.BlockID, minTi
 	// the extreme future arrives, this block will no longer be a part of the
 	// longest fork because it will have been ignored by all of the miners.
 	if b.Timestamp > bv.clock.Now()+types.ExtremeFutureThreshold {
-		return errExtremeFutureTimestamp
+		return modules.ErrExtremeFutureTimestamp
 	}
 
 	// Verify that the miner payouts are valid.
 	if !checkMinerPayouts(b, height) {
-		return errBadMinerPayouts
+		return modules.ErrBadMinerPayouts
 	}
 
 	// Check if the block is in the near future, but too far to be acceptable.
 	// This is the last check because it's an expensive check, and not worth
 	// performing if the payouts are incorrect.
 	if b.Timestamp > bv.clock.Now()+types.FutureThreshold {
-		return errFutureTimestamp
+		return modules.ErrFutureTimestamp
 	}
 
 	if log != nil {
@@ -3,6 +3,7 @@ package consensus
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -45,18 +46,18 @@ var validateBlockTests = []struct {
 	{
 		minTimestamp:   types.Timestamp(5),
 		blockTimestamp: types.Timestamp(4),
-		errWant:        errEarlyTimestamp,
+		errWant:        modules.ErrEarlyTimestamp,
 		msg:            "ValidateBlock should reject blocks with timestamps that are too early",
 	},
 	{
 		blockSize: types.BlockSizeLimit + 1,
-		errWant:   errLargeBlock,
+		errWant:   modules.ErrLargeBlock,
 		msg:       "ValidateBlock should reject excessively large blocks",
 	},
 	{
 		now:            types.Timestamp(50),
 		blockTimestamp: types.Timestamp(50) + types.ExtremeFutureThreshold + 1,
-		errWant:        errExtremeFutureTimestamp,
+		errWant:        modules.ErrExtremeFutureTimestamp,
 		msg:            "ValidateBlock should reject blocks timestamped in the extreme future",
 	},
 }
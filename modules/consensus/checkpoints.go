@@ -0,0 +1,95 @@
+package consensus
+
+import (
+	"errors"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	errCheckpointConflict = errors.New("checkpoint conflicts with the current path or an existing checkpoint")
+	errCheckpointMismatch = errors.New("block does not match the checkpoint at its height")
+	errCheckpointRevert   = errors.New("fork would revert a checkpointed block")
+)
+
+var (
+	// knownCheckpoints are the checkpoints compiled into each build. They
+	// are used by DefaultGenesisParams. An entry should only be added for a
+	// block that is buried far deeper than MaxReorgDepth on the network.
+	knownCheckpoints = build.Select(build.Var{
+		Standard: map[types.BlockHeight]types.BlockID{},
+		Dev:      map[types.BlockHeight]types.BlockID{},
+		Testing:  map[types.BlockHeight]types.BlockID{},
+	}).(map[types.BlockHeight]types.BlockID)
+)
+
+// AddCheckpoint finalizes the block at height 'h' to be the block with id
+// 'id'. Blocks at that height with a different id are rejected, and the
+// consensus set will not move onto a fork that reverts the checkpointed block.
+// An error is returned if the current path already has a different block at
+// that height, or if a different checkpoint was already added for the height.
+// Checkpoints are held in memory and need to be added again after a restart.
+func (cs *ConsensusSet) AddCheckpoint(h types.BlockHeight, id types.BlockID) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if existing, exists := cs.checkpoints[h]; exists && existing != id {
+		return errCheckpointConflict
+	}
+	err = cs.db.View(func(tx *bolt.Tx) error {
+		if h > blockHeight(tx) {
+			return nil
+		}
+		pathID, err := getPath(tx, h)
+		if err != nil {
+			return err
+		}
+		if pathID != id {
+			return errCheckpointConflict
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cs.checkpoints[h] = id
+	return nil
+}
+
+// checkCheckpoint returns errCheckpointMismatch if a checkpoint has been added
+// at 'height' for a block other than 'id'.
+func (cs *ConsensusSet) checkCheckpoint(height types.BlockHeight, id types.BlockID) error {
+	if checkpointID, exists := cs.checkpoints[height]; exists && checkpointID != id {
+		return errCheckpointMismatch
+	}
+	return nil
+}
+
+// checkForkCheckpoints returns an error if moving the consensus set onto
+// 'newPath' would revert a checkpointed block, or apply a block that does not
+// match a checkpoint. 'newPath' starts at the common parent of the fork and
+// the current path, as returned by backtrackToCurrentPath.
+func (cs *ConsensusSet) checkForkCheckpoints(tx *bolt.Tx, newPath []*processedBlock) error {
+	commonParent := newPath[0]
+	newHeight := newPath[len(newPath)-1].Height
+	for height, id := range cs.checkpoints {
+		if height <= commonParent.Height {
+			continue
+		}
+		if height <= blockHeight(tx) {
+			return errCheckpointRevert
+		}
+		if height <= newHeight && newPath[height-commonParent.Height].Block.ID() != id {
+			return errCheckpointMismatch
+		}
+	}
+	return nil
+}
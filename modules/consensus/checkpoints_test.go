@@ -0,0 +1,256 @@
+package consensus
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestCheckpoints checks that forks conflicting with a checkpoint are
+// rejected, while forks that agree with the checkpoint are accepted.
+func TestCheckpoints(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstBad, err := blankConsensusSetTester(t.Name() + "Bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstBad.Close()
+	cstGood, err := blankConsensusSetTester(t.Name() + "Good")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstGood.Close()
+
+	// Mine a short chain and checkpoint its second block. The good tester
+	// shares the first two blocks.
+	var mainChain []types.Block
+	for i := 0; i < 3; i++ {
+		b, err := cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mainChain = append(mainChain, b)
+	}
+	for _, b := range mainChain[:2] {
+		err = cstGood.cs.AcceptBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = cst.cs.AddCheckpoint(2, mainChain[1].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.AddCheckpoint(2, mainChain[1].ID())
+	if err != nil {
+		t.Fatal("re-adding a checkpoint failed:", err)
+	}
+	err = cst.cs.AddCheckpoint(2, mainChain[0].ID())
+	if err != errCheckpointConflict {
+		t.Fatalf("expected %v, got %v", errCheckpointConflict, err)
+	}
+	err = cst.cs.AddCheckpoint(1, types.BlockID{1})
+	if err != errCheckpointConflict {
+		t.Fatalf("expected %v, got %v", errCheckpointConflict, err)
+	}
+
+	// A heavier fork that replaces the checkpointed block is rejected at the
+	// conflicting block, which is remembered as invalid.
+	var badFork []types.Block
+	for i := 0; i < 5; i++ {
+		b, err := cstBad.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		badFork = append(badFork, b)
+	}
+	err = cst.cs.AcceptBlock(badFork[0])
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+	}
+	// A block at the checkpointed height that does not meet its target is
+	// rejected, but is not remembered as a DoS block.
+	var parent *processedBlock
+	_ = cst.cs.db.View(func(tx *bolt.Tx) error {
+		parent, err = getBlockMap(tx, badFork[0].ID())
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsolved := badFork[1]
+	for checkHeaderTarget(unsolved.Header(), parent.ChildTarget) {
+		unsolved.Nonce[0]++
+	}
+	err = cst.cs.AcceptBlock(unsolved)
+	if err != errCheckpointMismatch {
+		t.Fatalf("expected %v, got %v", errCheckpointMismatch, err)
+	}
+	cst.cs.mu.RLock()
+	_, dos := cst.cs.dosBlocks[unsolved.ID()]
+	cst.cs.mu.RUnlock()
+	if dos {
+		t.Fatal("unsolved block was remembered as a DoS block")
+	}
+
+	err = cst.cs.AcceptBlock(badFork[1])
+	if err != errCheckpointMismatch {
+		t.Fatalf("expected %v, got %v", errCheckpointMismatch, err)
+	}
+	err = cst.cs.AcceptBlock(badFork[1])
+	if err != modules.ErrDoSBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrDoSBlock, err)
+	}
+	err = cst.cs.AcceptHeader(badFork[1].Header())
+	if err != modules.ErrDoSBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrDoSBlock, err)
+	}
+	for _, b := range badFork[2:] {
+		err = cst.cs.AcceptBlock(b)
+		if err != modules.ErrOrphan {
+			t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+		}
+	}
+	if cst.cs.CurrentBlockID() != mainChain[2].ID() {
+		t.Fatal("consensus set moved onto a fork that conflicts with a checkpoint")
+	}
+
+	// A heavier fork that keeps the checkpointed block is accepted.
+	var goodFork []types.Block
+	for i := 0; i < 3; i++ {
+		b, err := cstGood.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		goodFork = append(goodFork, b)
+	}
+	for _, b := range goodFork {
+		err = cst.cs.AcceptBlock(b)
+		if err != nil && err != modules.ErrNonExtendingBlock {
+			t.Fatal(err)
+		}
+	}
+	if cst.cs.CurrentBlockID() != goodFork[len(goodFork)-1].ID() {
+		t.Fatal("consensus set did not move onto a fork that agrees with the checkpoint")
+	}
+}
+
+// TestCheckpointRevert checks that the consensus set refuses to revert a
+// checkpointed block, even if the competing fork was added to the block tree
+// before the checkpoint.
+func TestCheckpointRevert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name() + "Alt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	for i := 0; i < 3; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var fork []types.Block
+	for i := 0; i < 4; i++ {
+		b, err := cstAlt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork = append(fork, b)
+	}
+
+	// Give the consensus set all but the last block of the fork, then
+	// checkpoint the current path.
+	for _, b := range fork[:3] {
+		err = cst.cs.AcceptBlock(b)
+		if err != modules.ErrNonExtendingBlock {
+			t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+		}
+	}
+	tip := cst.cs.CurrentBlockID()
+	cp, _ := cst.cs.BlockAtHeight(1)
+	err = cst.cs.AddCheckpoint(1, cp.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := cst.cs.dbConsensusChecksum()
+
+	// The last block makes the fork heavier, but moving onto it would revert
+	// the checkpointed block.
+	err = cst.cs.AcceptBlock(fork[3])
+	if err != errCheckpointRevert {
+		t.Fatalf("expected %v, got %v", errCheckpointRevert, err)
+	}
+	if cst.cs.CurrentBlockID() != tip || cst.cs.dbConsensusChecksum() != checksum {
+		t.Fatal("refused fork changed the consensus set")
+	}
+}
+
+// TestGenesisCheckpoints checks that checkpoints supplied in the genesis
+// parameters are enforced from the moment the consensus set is created.
+func TestGenesisCheckpoints(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+
+	// newCS creates a consensus set with its own gateway and a checkpoint for
+	// height 1.
+	newCS := func(name string, checkpoint types.BlockID) *ConsensusSet {
+		g, err := gateway.New("localhost:0", false, filepath.Join(testdir, name, modules.GatewayDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := DefaultGenesisParams()
+		params.Checkpoints = map[types.BlockHeight]types.BlockID{1: checkpoint}
+		cs, err := NewWithParams(g, false, filepath.Join(testdir, name, modules.ConsensusDir), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cs
+	}
+
+	// A block conflicting with the checkpoint is rejected.
+	csMismatch := newCS("mismatch", types.BlockID{1})
+	defer csMismatch.gateway.Close()
+	defer csMismatch.Close()
+	b := solveChild(csMismatch)
+	err := csMismatch.AcceptBlock(b)
+	if err != errCheckpointMismatch {
+		t.Fatalf("expected %v, got %v", errCheckpointMismatch, err)
+	}
+
+	// The same block is accepted by a consensus set whose checkpoint matches.
+	csMatch := newCS("match", b.ID())
+	defer csMatch.gateway.Close()
+	defer csMatch.Close()
+	err = csMatch.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,69 @@
+package consensus
+
+import (
+	"math/big"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// minConfirmations is the number of confirmations recommended for any
+	// payment, regardless of its value or the reorg history of the node.
+	minConfirmations = build.Select(build.Var{
+		Standard: types.BlockHeight(6),
+		Dev:      types.BlockHeight(3),
+		Testing:  types.BlockHeight(2),
+	}).(types.BlockHeight)
+
+	// confirmationValueStep is the payment value above which additional
+	// confirmations are recommended. One extra confirmation is recommended
+	// each time the value doubles beyond this step.
+	confirmationValueStep = types.SiacoinPrecision.Mul64(10e3)
+
+	// maxRecentReorgs is the number of reorg depths that the consensus set
+	// keeps in memory for computing confirmation recommendations.
+	maxRecentReorgs = 50
+)
+
+// recordReorg notes that a reorg reverting 'depth' blocks has occurred. Only
+// the most recent maxRecentReorgs reorgs are kept.
+func (cs *ConsensusSet) recordReorg(depth types.BlockHeight) {
+	cs.metrics.Reorgs++
+	if depth > cs.metrics.DeepestReorg {
+		cs.metrics.DeepestReorg = depth
+	}
+	cs.recentReorgDepths = append(cs.recentReorgDepths, depth)
+	if len(cs.recentReorgDepths) > maxRecentReorgs {
+		cs.recentReorgDepths = cs.recentReorgDepths[len(cs.recentReorgDepths)-maxRecentReorgs:]
+	}
+}
+
+// RecommendedConfirmations returns the number of confirmations that a
+// payment of the provided value should have before it is considered final.
+// The recommendation starts at a fixed minimum, grows logarithmically with
+// the value of the payment, and grows by the depth of the deepest reorg that
+// the consensus set has recently observed.
+func (cs *ConsensusSet) RecommendedConfirmations(value types.Currency) types.BlockHeight {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	confirmations := minConfirmations
+
+	// Add one confirmation for each doubling of the value beyond the value
+	// step.
+	if value.Cmp(confirmationValueStep) > 0 {
+		ratio := new(big.Int).Div(value.Big(), confirmationValueStep.Big())
+		confirmations += types.BlockHeight(ratio.BitLen())
+	}
+
+	// A payment should be buried deeper than any reorg that has recently been
+	// observed.
+	var deepestReorg types.BlockHeight
+	for _, depth := range cs.recentReorgDepths {
+		if depth > deepestReorg {
+			deepestReorg = depth
+		}
+	}
+	return confirmations + deepestReorg
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestRecommendedConfirmations checks that the recommended number of
+// confirmations grows with the value of the payment and with the depth of
+// observed reorgs.
+func TestRecommendedConfirmations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	// Small payments should get the minimum, and larger payments should get
+	// more confirmations.
+	small := cst.cs.RecommendedConfirmations(types.SiacoinPrecision)
+	if small != minConfirmations {
+		t.Fatal("small payment should receive the minimum recommendation:", small)
+	}
+	medium := cst.cs.RecommendedConfirmations(confirmationValueStep.Mul64(4))
+	large := cst.cs.RecommendedConfirmations(confirmationValueStep.Mul64(1e6))
+	if medium <= small || large <= medium {
+		t.Fatal("recommendation does not grow with value:", small, medium, large)
+	}
+
+	// Build a chain of 3 blocks on the main tester and a chain of 4 blocks on
+	// the alternate tester, then give the alternate chain to the main tester
+	// to trigger a reorg of depth 3.
+	for i := 0; i < 3; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var altBlocks []types.Block
+	for i := 0; i < 4; i++ {
+		b, err := cstAlt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		altBlocks = append(altBlocks, b)
+	}
+	if cst.cs.RecommendedConfirmations(types.SiacoinPrecision) != small {
+		t.Fatal("recommendation changed without a reorg")
+	}
+	_, err = cst.cs.managedAcceptBlocks(altBlocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.CurrentBlock().ID() != altBlocks[len(altBlocks)-1].ID() {
+		t.Fatal("reorg did not happen")
+	}
+
+	// The reorg should increase the recommendation by its depth.
+	if rec := cst.cs.RecommendedConfirmations(types.SiacoinPrecision); rec != small+3 {
+		t.Fatal("reorg did not increase the recommendation correctly:", rec)
+	}
+	if rec := cst.cs.RecommendedConfirmations(confirmationValueStep.Mul64(1e6)); rec != large+3 {
+		t.Fatal("reorg did not increase the recommendation correctly:", rec)
+	}
+}
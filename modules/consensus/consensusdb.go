@@ -120,11 +120,11 @@ func (cs *ConsensusSet) createConsensusDB(tx *bolt.Tx) error {
 	}
 
 	// Add the miner payout from the genesis block to the delayed siacoin
-	// outputs - unspendable, as the unlock hash is blank.
+	// outputs - unspendable on mainnet, as the unlock hash is blank.
 	createDSCOBucket(tx, types.MaturityDelay)
 	addDSCO(tx, types.MaturityDelay, cs.blockRoot.Block.MinerPayoutID(0), types.SiacoinOutput{
 		Value:      types.CalculateCoinbase(0),
-		UnlockHash: types.UnlockHash{},
+		UnlockHash: cs.genesisPayoutAddress,
 	})
 
 	// Add the genesis block to the block structures - checksum must be taken
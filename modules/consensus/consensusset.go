@@ -8,6 +8,7 @@ package consensus
 
 import (
 	"errors"
+	"time"
 
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
@@ -19,6 +20,12 @@ import (
 	"github.com/NebulousLabs/demotemutex"
 )
 
+const (
+	// stallMultiplier is the number of expected block intervals that can
+	// pass without a new block before the blockchain is considered stalled.
+	stallMultiplier = 10
+)
+
 var (
 	errNilGateway = errors.New("cannot have a nil gateway as input")
 )
@@ -43,8 +50,10 @@ type ConsensusSet struct {
 	// synchronized to the rest of the network.
 	gateway modules.Gateway
 
-	// The block root contains the genesis block.
-	blockRoot processedBlock
+	// The block root contains the genesis block. genesisPayoutAddress
+	// receives the subsidy of the genesis block.
+	blockRoot            processedBlock
+	genesisPayoutAddress types.UnlockHash
 
 	// Subscribers to the consensus set will receive a changelog every time
 	// there is an update to the consensus set. At initialization, they receive
@@ -61,13 +70,59 @@ type ConsensusSet struct {
 	// recorded to eliminate a DoS vector where an expensive-to-validate block
 	// is submitted to the consensus set repeatedly.
 	//
-	// TODO: dosBlocks needs to be moved into the database, and if there's some
-	// reason it can't be in THE database, it should be in a separate database.
-	// dosBlocks is an unbounded map that an attacker can manipulate, though
-	// iirc manipulations are expensive, to the tune of creating a blockchain
-	// PoW per DoS block (though the attacker could conceivably build off of
-	// the genesis block, meaning the PoW is not very expensive.
-	dosBlocks map[types.BlockID]struct{}
+	// An attacker can add to dosBlocks cheaply by building off of the genesis
+	// block, so the map is capped at maxDoSBlocks entries. dosBlockOrder
+	// records the order in which the blocks were added so that the oldest
+	// can be evicted first.
+	dosBlocks     map[types.BlockID]struct{}
+	dosBlockOrder []types.BlockID
+
+	// futureBlocks holds blocks whose timestamps were too far in the future
+	// when they were received. They are resubmitted by ProcessFutureBlocks
+	// once their timestamps are within types.FutureThreshold of the current
+	// time. The number of future blocks is capped at maxFutureBlocks.
+	futureBlocks map[types.BlockID]types.Block
+
+	// orphanBlocks holds blocks whose parents are not yet known, keyed by the
+	// id of the missing parent. When the parent is added to the block tree,
+	// the orphans are accepted as well. Only orphans meeting the minimum
+	// orphan target are held. The number of orphans is capped at
+	// maxOrphanBlocks, with the oldest evicted first, and orphans are evicted
+	// after orphanExpiration.
+	orphanBlocks    map[types.BlockID][]orphanBlock
+	numOrphanBlocks int
+
+	// headers is the header tree, holding headers accepted through
+	// AcceptHeader whose blocks have not yet been added to the block tree.
+	// Headers may build on other headers, see headers.go. The number of
+	// headers is capped at maxPendingHeaders.
+	headers map[types.BlockID]*headerNode
+
+	// checkpoints maps block heights to the ids of the blocks that have been
+	// finalized at those heights, either by the genesis parameters or through
+	// AddCheckpoint. Blocks that conflict with a checkpoint are rejected, and
+	// forks that would revert a checkpointed block are refused. Checkpoints
+	// added through AddCheckpoint are not persisted.
+	checkpoints map[types.BlockHeight]types.BlockID
+
+	// maxReorgDepth is the maximum number of blocks that will be reverted to
+	// move onto a heavier fork. It is set by the genesis parameters.
+	maxReorgDepth types.BlockHeight
+
+	// prevalidatedBlocks maps the ids of blocks whose transactions were found
+	// to be standalone valid without holding the lock to the height that
+	// they were checked at. It is only set during managedAcceptBlocks, and
+	// allows generateAndApplyDiff to skip the standalone checks.
+	prevalidatedBlocks map[types.BlockID]types.BlockHeight
+
+	// recentReorgDepths holds the number of blocks reverted by each of the
+	// most recent reorgs, and is used to recommend confirmation depths. It is
+	// not persisted.
+	recentReorgDepths []types.BlockHeight
+
+	// metrics holds counters describing the work done by the consensus set.
+	// It is not persisted.
+	metrics modules.ConsensusMetrics
 
 	// checkingConsistency is a bool indicating whether or not a consistency
 	// check is in progress. The consistency check logic call itself, resulting
@@ -85,6 +140,7 @@ type ConsensusSet struct {
 	marshaler       marshaler
 	blockRuleHelper blockRuleHelper
 	blockValidator  blockValidator
+	clock           types.Clock
 
 	// Utilities
 	db         *persist.BoltDatabase
@@ -98,6 +154,13 @@ type ConsensusSet struct {
 // there is an existing block database present in the persist directory, it
 // will be loaded.
 func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*ConsensusSet, error) {
+	return NewWithParams(gateway, bootstrap, persistDir, DefaultGenesisParams())
+}
+
+// NewWithParams returns a new ConsensusSet built on the genesis block
+// described by 'params'. An existing block database in the persist directory
+// will only be loaded if it has the same genesis block.
+func NewWithParams(gateway modules.Gateway, bootstrap bool, persistDir string, params GenesisParams) (*ConsensusSet, error) {
 	// Check for nil dependencies.
 	if gateway == nil {
 		return nil, errNilGateway
@@ -108,25 +171,39 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		gateway: gateway,
 
 		blockRoot: processedBlock{
-			Block:       types.GenesisBlock,
-			ChildTarget: types.RootTarget,
+			Block:       params.GenesisBlock(),
+			ChildTarget: params.RootTarget,
 			Depth:       types.RootDepth,
 
 			DiffsGenerated: true,
 		},
+		genesisPayoutAddress: params.MinerPayoutAddress,
 
-		dosBlocks: make(map[types.BlockID]struct{}),
+		dosBlocks:    make(map[types.BlockID]struct{}),
+		futureBlocks: make(map[types.BlockID]types.Block),
+		orphanBlocks: make(map[types.BlockID][]orphanBlock),
+		headers:      make(map[types.BlockID]*headerNode),
+		checkpoints:  make(map[types.BlockHeight]types.BlockID),
 
 		marshaler:       stdMarshaler{},
 		blockRuleHelper: stdBlockRuleHelper{},
 		blockValidator:  NewBlockValidator(),
+		clock:           types.StdClock{},
 
 		persistDir: persistDir,
 	}
+	for height, id := range params.Checkpoints {
+		cs.checkpoints[height] = id
+	}
+	cs.maxReorgDepth = params.MaxReorgDepth
+	if cs.maxReorgDepth == 0 {
+		cs.maxReorgDepth = MaxReorgDepth
+	}
 
 	// Create the diffs for the genesis siafund outputs.
-	for i, siafundOutput := range types.GenesisBlock.Transactions[0].SiafundOutputs {
-		sfid := types.GenesisBlock.Transactions[0].SiafundOutputID(uint64(i))
+	genesisTxn := cs.blockRoot.Block.Transactions[0]
+	for i, siafundOutput := range genesisTxn.SiafundOutputs {
+		sfid := genesisTxn.SiafundOutputID(uint64(i))
 		sfod := modules.SiafundOutputDiff{
 			Direction:     modules.DiffApply,
 			ID:            sfid,
@@ -141,6 +218,9 @@ func New(gateway modules.Gateway, bootstrap bool, persistDir string) (*Consensus
 		return nil, err
 	}
 
+	// Periodically retry blocks that arrived with future timestamps.
+	go cs.threadedProcessFutureBlocks()
+
 	go func() {
 		// Sync with the network. Don't sync if we are testing because
 		// typically we don't have any mock peers to synchronize with in
@@ -202,6 +282,24 @@ func (cs *ConsensusSet) BlockAtHeight(height types.BlockHeight) (block types.Blo
 	return block, exists
 }
 
+// BlockByID returns the block with the given id along with its height. The
+// block may be on any fork that the consensus set knows about; InCurrentPath
+// reports whether it is on the current path. Like BlockAtHeight, it does not
+// take cs.mu, so it is safe to call while processing a consensus change.
+func (cs *ConsensusSet) BlockByID(id types.BlockID) (block types.Block, height types.BlockHeight, exists bool) {
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		block = pb.Block
+		height = pb.Height
+		exists = true
+		return nil
+	})
+	return block, height, exists
+}
+
 // ChildTarget returns the target for the child of a block.
 func (cs *ConsensusSet) ChildTarget(id types.BlockID) (target types.Target, exists bool) {
 	// A call to a closed database can cause undefined behavior.
@@ -264,12 +362,78 @@ func (cs *ConsensusSet) CurrentBlock() (block types.Block) {
 	return block
 }
 
+// CurrentBlockID returns the id of the latest block in the heaviest known
+// blockchain.
+func (cs *ConsensusSet) CurrentBlockID() (id types.BlockID) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return types.BlockID{}
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		id = currentBlockID(tx)
+		return nil
+	})
+	return id
+}
+
+// CurrentDepth returns the cumulative depth of the heaviest known blockchain.
+// Depth is expressed as a target, so a smaller depth is a heavier chain.
+func (cs *ConsensusSet) CurrentDepth() (depth types.Target) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return types.Target{}
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		depth = currentProcessedBlock(tx).Depth
+		return nil
+	})
+	return depth
+}
+
+// CurrentTarget returns the target required for a block extending the
+// heaviest known blockchain.
+func (cs *ConsensusSet) CurrentTarget() (target types.Target) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return types.Target{}
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		target = currentProcessedBlock(tx).ChildTarget
+		return nil
+	})
+	return target
+}
+
 // Flush will block until the consensus set has finished all in-progress
 // routines.
 func (cs *ConsensusSet) Flush() error {
 	return cs.tg.Flush()
 }
 
+// CoinSupply returns the number of siacoins that exist at the current block
+// height. Every block mints exactly its coinbase, as miner fees and file
+// contract payouts only move existing coins, so the supply is determined by
+// the height alone. In testing builds, auditSiacoinSupply checks the
+// consensus set against this value after every block is applied or reverted.
+func (cs *ConsensusSet) CoinSupply() types.Currency {
+	return types.CalculateNumSiacoins(cs.Height())
+}
+
 // Height returns the height of the consensus set.
 func (cs *ConsensusSet) Height() (height types.BlockHeight) {
 	// A call to a closed database can cause undefined behavior.
@@ -319,6 +483,13 @@ func (cs *ConsensusSet) InCurrentPath(id types.BlockID) (inPath bool) {
 	return inPath
 }
 
+// Metrics returns a snapshot of the consensus set's counters.
+func (cs *ConsensusSet) Metrics() modules.ConsensusMetrics {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.metrics
+}
+
 // MinimumValidChildTimestamp returns the earliest timestamp that the next block
 // can have in order for it to be considered valid.
 func (cs *ConsensusSet) MinimumValidChildTimestamp(id types.BlockID) (timestamp types.Timestamp, exists bool) {
@@ -342,8 +513,18 @@ func (cs *ConsensusSet) MinimumValidChildTimestamp(id types.BlockID) (timestamp
 	return timestamp, exists
 }
 
+// Stalled returns true if no block has been added to the blockchain for
+// much longer than the target block frequency, which can indicate a network
+// partition or a difficulty that is too high.
+func (cs *ConsensusSet) Stalled() bool {
+	stallTime := time.Duration(types.BlockFrequency) * time.Second * stallMultiplier
+	return cs.TimeSinceLastBlock() > stallTime
+}
+
 // StorageProofSegment returns the segment to be used in the storage proof for
-// a given file contract.
+// a given file contract. The segment is derived from the id of the block at
+// height WindowStart-1, so it is not known until that block has been found.
+// An error is returned if the contract's proof window has not yet opened.
 func (cs *ConsensusSet) StorageProofSegment(fcid types.FileContractID) (index uint64, err error) {
 	// A call to a closed database can cause undefined behavior.
 	err = cs.tg.Add()
@@ -358,3 +539,56 @@ func (cs *ConsensusSet) StorageProofSegment(fcid types.FileContractID) (index ui
 	})
 	return index, err
 }
+
+// TargetAtHeight returns the target that the block at the given height in the
+// current path had to meet, which is the child target of its parent. The
+// genesis block has no parent, so no target is returned for height 0. Like
+// BlockAtHeight, TargetAtHeight does not wait for subscribers to be updated.
+func (cs *ConsensusSet) TargetAtHeight(height types.BlockHeight) (target types.Target, exists bool) {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return types.Target{}, false
+	}
+	defer cs.tg.Done()
+
+	if height == 0 {
+		return types.Target{}, false
+	}
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		if height > blockHeight(tx) {
+			return nil
+		}
+		id, err := getPath(tx, height-1)
+		if err != nil {
+			return err
+		}
+		pb, err := getBlockMap(tx, id)
+		if err != nil {
+			return err
+		}
+		target = pb.ChildTarget
+		exists = true
+		return nil
+	})
+	return target, exists
+}
+
+// TimeSinceLastBlock returns the amount of time that has passed since the
+// timestamp of the current block. If the current block has a timestamp in the
+// future, zero is returned.
+func (cs *ConsensusSet) TimeSinceLastBlock() time.Duration {
+	// A call to a closed database can cause undefined behavior.
+	err := cs.tg.Add()
+	if err != nil {
+		return 0
+	}
+	defer cs.tg.Done()
+
+	block := cs.managedCurrentBlock()
+	now := cs.clock.Now()
+	if block.Timestamp > now {
+		return 0
+	}
+	return time.Duration(now-block.Timestamp) * time.Second
+}
@@ -2,7 +2,9 @@ package consensus
 
 import (
 	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -199,3 +201,264 @@ func TestDatabaseClosing(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestStalled checks that a consensus set with an old current block reports
+// that it has stalled, and that a fresh block makes it healthy again.
+func TestStalled(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// The genesis block of the testing build is far in the past.
+	stallTime := time.Duration(types.BlockFrequency) * time.Second * stallMultiplier
+	if cst.cs.TimeSinceLastBlock() <= stallTime {
+		t.Fatal("genesis block should be older than the stall time:", cst.cs.TimeSinceLastBlock())
+	}
+	if !cst.cs.Stalled() {
+		t.Fatal("consensus set with an old current block should be stalled")
+	}
+
+	// Mine a fresh block.
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.TimeSinceLastBlock() > stallTime {
+		t.Fatal("fresh block should not be older than the stall time:", cst.cs.TimeSinceLastBlock())
+	}
+	if cst.cs.Stalled() {
+		t.Fatal("consensus set with a fresh current block should not be stalled")
+	}
+
+	// The time since the last block should follow the consensus set's clock.
+	current := cst.cs.CurrentBlock()
+	cst.cs.clock = mockClock{now: current.Timestamp + types.Timestamp(stallTime/time.Second) + 1}
+	if !cst.cs.Stalled() {
+		t.Fatal("consensus set should be stalled once its clock passes the stall time")
+	}
+	cst.cs.clock = mockClock{now: current.Timestamp - 1}
+	if cst.cs.TimeSinceLastBlock() != 0 {
+		t.Fatal("a block in the future should report no time since the last block")
+	}
+}
+
+// TestCurrentTipConcurrent reads the current tip from several goroutines while
+// blocks are being accepted, checking that the accessors never observe the
+// chain moving backwards. The test is most useful when run with -race.
+func TestCurrentTipConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var prevHeight types.BlockHeight
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				height := cst.cs.Height()
+				if height < prevHeight {
+					t.Error("height decreased from", prevHeight, "to", height)
+					return
+				}
+				prevHeight = height
+				if cst.cs.CurrentBlockID() == (types.BlockID{}) {
+					t.Error("empty current block id")
+					return
+				}
+				if cst.cs.CurrentTarget() == (types.Target{}) {
+					t.Error("empty current target")
+					return
+				}
+				if cst.cs.CurrentDepth() == (types.Target{}) {
+					t.Error("empty current depth")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		b, _ := cst.miner.FindBlock()
+		err = cst.cs.AcceptBlock(b)
+		if err != nil {
+			t.Error(err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// Once blocks stop arriving, the accessors should agree with each other.
+	pb := cst.cs.dbCurrentProcessedBlock()
+	if cst.cs.CurrentBlockID() != pb.Block.ID() || cst.cs.CurrentBlockID() != cst.cs.CurrentBlock().ID() {
+		t.Error("current block id does not match the current block")
+	}
+	if cst.cs.CurrentTarget() != pb.ChildTarget {
+		t.Error("current target does not match the child target of the current block")
+	}
+	if cst.cs.CurrentDepth() != pb.Depth {
+		t.Error("current depth does not match the depth of the current block")
+	}
+	if target, _ := cst.cs.ChildTarget(pb.Block.ID()); target != cst.cs.CurrentTarget() {
+		t.Error("current target does not match ChildTarget")
+	}
+}
+
+// TestBlockByID checks that blocks can be looked up by id, including blocks
+// that are not on the current path.
+func TestBlockByID(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Every block on the current path should be found at its height.
+	for h := types.BlockHeight(0); h <= cst.cs.Height(); h++ {
+		b, exists := cst.cs.BlockAtHeight(h)
+		if !exists {
+			t.Fatal("no block at height", h)
+		}
+		b2, height, exists := cst.cs.BlockByID(b.ID())
+		if !exists || height != h || b2.ID() != b.ID() {
+			t.Fatalf("BlockByID disagrees with BlockAtHeight at height %v", h)
+		}
+	}
+
+	// A block on a losing fork should be found, but not be in the current
+	// path.
+	parentHeight := cst.cs.Height() - 1
+	parent, _ := cst.cs.BlockAtHeight(parentHeight)
+	target, _ := cst.cs.ChildTarget(parent.ID())
+	fork := types.Block{
+		ParentID:     parent.ID(),
+		Timestamp:    types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{Value: types.CalculateCoinbase(parentHeight + 1)}},
+	}
+	fork, _ = cst.miner.SolveBlock(fork, target)
+	err = cst.cs.AcceptBlock(fork)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+	}
+	_, height, exists := cst.cs.BlockByID(fork.ID())
+	if !exists || height != parentHeight+1 {
+		t.Fatal("block on a losing fork was not found")
+	}
+	if cst.cs.InCurrentPath(fork.ID()) {
+		t.Fatal("block on a losing fork is in the current path")
+	}
+
+	// Unknown blocks should not be found.
+	if _, _, exists := cst.cs.BlockByID(types.BlockID{1}); exists {
+		t.Fatal("unknown block was found")
+	}
+}
+
+// TestTargetAtHeight checks that TargetAtHeight reports the target that each
+// block in the current path had to meet.
+func TestTargetAtHeight(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	if _, exists := cst.cs.TargetAtHeight(0); exists {
+		t.Error("target returned for the genesis block")
+	}
+	for h := types.BlockHeight(1); h <= cst.cs.Height(); h++ {
+		parent, _ := cst.cs.BlockAtHeight(h - 1)
+		childTarget, _ := cst.cs.ChildTarget(parent.ID())
+		target, exists := cst.cs.TargetAtHeight(h)
+		if !exists || target != childTarget {
+			t.Fatalf("wrong target at height %v", h)
+		}
+		b, _ := cst.cs.BlockAtHeight(h)
+		if !checkTarget(b, b.ID(), target) {
+			t.Fatalf("block at height %v does not meet its target", h)
+		}
+	}
+	if _, exists := cst.cs.TargetAtHeight(cst.cs.Height() + 1); exists {
+		t.Error("target returned for a height beyond the current height")
+	}
+}
+
+// TestMetrics checks that the consensus set counts accepted blocks and
+// reorgs.
+func TestMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	before := cst.cs.Metrics()
+	for i := 0; i < 3; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := cst.cs.Metrics()
+	if m.BlocksAccepted != before.BlocksAccepted+3 || m.Reorgs != before.Reorgs {
+		t.Fatalf("wrong metrics after mining: %+v", m)
+	}
+	if m.ValidationTime <= before.ValidationTime {
+		t.Error("validation time did not increase")
+	}
+
+	// Give the main tester a longer alternate chain to trigger a reorg of
+	// depth 3.
+	var altBlocks []types.Block
+	for i := 0; i < 4; i++ {
+		b, err := cstAlt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		altBlocks = append(altBlocks, b)
+	}
+	_, err = cst.cs.managedAcceptBlocks(altBlocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = cst.cs.Metrics()
+	if m.BlocksAccepted != before.BlocksAccepted+7 || m.Reorgs != before.Reorgs+1 || m.DeepestReorg != 3 {
+		t.Fatalf("wrong metrics after reorg: %+v", m)
+	}
+}
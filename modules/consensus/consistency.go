@@ -76,11 +76,14 @@ func consensusChecksum(tx *bolt.Tx) crypto.Hash {
 	return tree.Root()
 }
 
-// checkSiacoinCount checks that the number of siacoins countable within the
-// consensus set equal the expected number of siacoins for the block height.
-func checkSiacoinCount(tx *bolt.Tx) {
+// auditSiacoinSupply checks that the number of siacoins countable within the
+// consensus set equals the coin supply at the current block height. Coins are
+// counted in siacoin outputs, delayed siacoin outputs, the valid proof payouts
+// of open file contracts, and unclaimed siafund pool claims. A descriptive
+// error is returned if the counts differ.
+func auditSiacoinSupply(tx *bolt.Tx) error {
 	// Iterate through all the buckets looking for the delayed siacoin output
-	// buckets, and check that they are for the correct heights.
+	// buckets.
 	var dscoSiacoins types.Currency
 	err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
 		// Check if the bucket is a delayed siacoin output bucket.
@@ -89,22 +92,18 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		}
 
 		// Sum up the delayed outputs in this bucket.
-		err := b.ForEach(func(_, delayedOutput []byte) error {
+		return b.ForEach(func(_, delayedOutput []byte) error {
 			var sco types.SiacoinOutput
 			err := encoding.Unmarshal(delayedOutput, &sco)
 			if err != nil {
-				manageErr(tx, err)
+				return err
 			}
 			dscoSiacoins = dscoSiacoins.Add(sco.Value)
 			return nil
 		})
-		if err != nil {
-			return err
-		}
-		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the siacoin outputs.
@@ -113,13 +112,13 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var sco types.SiacoinOutput
 		err := encoding.Unmarshal(scoBytes, &sco)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		scoSiacoins = scoSiacoins.Add(sco.Value)
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the payouts from file contracts.
@@ -128,7 +127,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var fc types.FileContract
 		err := encoding.Unmarshal(fcBytes, &fc)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 		var fcCoins types.Currency
 		for _, output := range fc.ValidProofOutputs {
@@ -138,7 +137,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	// Add all of the siafund claims.
@@ -147,7 +146,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		var sfo types.SiafundOutput
 		err := encoding.Unmarshal(sfoBytes, &sfo)
 		if err != nil {
-			manageErr(tx, err)
+			return err
 		}
 
 		coinsPerFund := getSiafundPool(tx).Sub(sfo.ClaimStart)
@@ -156,7 +155,7 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		return nil
 	})
 	if err != nil {
-		manageErr(tx, err)
+		return err
 	}
 
 	expectedSiacoins := types.CalculateNumSiacoins(blockHeight(tx))
@@ -168,7 +167,17 @@ func checkSiacoinCount(tx *bolt.Tx) {
 		} else {
 			diagnostics += fmt.Sprintf("total: %v\nexpected: %v\n expected is bigger: %v", totalSiacoins, expectedSiacoins, totalSiacoins.Sub(expectedSiacoins))
 		}
-		manageErr(tx, errors.New(diagnostics))
+		return errors.New(diagnostics)
+	}
+	return nil
+}
+
+// checkSiacoinCount checks that the number of siacoins countable within the
+// consensus set equal the expected number of siacoins for the block height.
+func checkSiacoinCount(tx *bolt.Tx) {
+	err := auditSiacoinSupply(tx)
+	if err != nil {
+		manageErr(tx, err)
 	}
 }
 
@@ -18,3 +18,11 @@ func (cs *ConsensusSet) dbConsensusChecksum() (checksum crypto.Hash) {
 	}
 	return checksum
 }
+
+// dbAuditSiacoinSupply is a convenience function to call auditSiacoinSupply
+// without a bolt.Tx.
+func (cs *ConsensusSet) dbAuditSiacoinSupply() error {
+	return cs.db.View(func(tx *bolt.Tx) error {
+		return auditSiacoinSupply(tx)
+	})
+}
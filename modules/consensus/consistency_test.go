@@ -0,0 +1,67 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCoinSupply checks that the coins in the consensus set match the coin
+// supply while file contracts are created and resolved, and while the
+// consensus set is reorged onto other forks and back.
+func TestCoinSupply(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rs := createReorgSets(t.Name())
+	defer rs.Close()
+	cst := rs.cstMain
+
+	audit := func(step string) {
+		if supply := cst.cs.CoinSupply(); !supply.Equals(types.CalculateNumSiacoins(cst.cs.Height())) {
+			t.Fatalf("%v: wrong coin supply %v", step, supply)
+		}
+		if err := cst.cs.dbAuditSiacoinSupply(); err != nil {
+			t.Fatalf("%v: %v", step, err)
+		}
+	}
+	audit("start")
+
+	// Create one contract that will miss its proof and one that will still be
+	// open at the end of the test.
+	height := cst.cs.Height()
+	_, err := cst.addFileContract(randAddress(), randAddress(), height+2, height+3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	openID, err := cst.addFileContract(randAddress(), randAddress(), height+100, height+110)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		audit("mining")
+	}
+
+	// Reorg onto a fork without the contracts, and back again.
+	rs.save()
+	audit("save")
+	rs.extend()
+	audit("extend")
+	rs.restore()
+	audit("restore")
+
+	// Removing an open contract should be caught by the audit.
+	_, err = cst.cs.dbGetFileContract(openID)
+	if err != nil {
+		t.Fatal("open contract is missing:", err)
+	}
+	cst.cs.dbRemoveFileContract(openID)
+	if cst.cs.dbAuditSiacoinSupply() == nil {
+		t.Fatal("audit did not notice the missing contract")
+	}
+}
@@ -63,12 +63,15 @@ var (
 // or decreases rapidly on the network, and it also limits the amount of damange
 // that a malicious attacker can do if performing a difficulty raising attack.
 
-// childTargetOak sets the child target based on the total time delta and total
-// hashrate of the parent block. The deltas are known for the child block,
-// however we do not use the child block deltas because that would allow the
-// child block to influence the target of the following block, which makes abuse
-// easier in selfish mining scenarios.
-func (cs *ConsensusSet) childTargetOak(parentTotalTime int64, parentTotalTarget, currentTarget types.Target, parentHeight types.BlockHeight) types.Target {
+// CalculateTarget returns the Oak child target based on the total time delta
+// and total hashrate of the parent block. The deltas are known for the child
+// block, however we do not use the child block deltas because that would allow
+// the child block to influence the target of the following block, which makes
+// abuse easier in selfish mining scenarios.
+//
+// CalculateTarget depends only on its arguments, so it can be used to test or
+// simulate the difficulty algorithm outside of a running consensus set.
+func CalculateTarget(parentTotalTime int64, parentTotalTarget, currentTarget types.Target, parentHeight types.BlockHeight) types.Target {
 	// Determine the detla of the current total time vs. the desired total time.
 	expectedTime := types.BlockFrequency * parentHeight
 	delta := int64(expectedTime) - parentTotalTime
@@ -122,10 +125,9 @@ func (cs *ConsensusSet) getBlockTotals(tx *bolt.Tx, id types.BlockID) (totalTime
 	return
 }
 
-// storeBlockTotals computes the new total time and total target for the current
-// block and stores that new time in the database. It also returns the new
-// totals.
-func (cs *ConsensusSet) storeBlockTotals(tx *bolt.Tx, currentHeight types.BlockHeight, currentBlockID types.BlockID, prevTotalTime int64, parentTimestamp, currentTimestamp types.Timestamp, prevTotalTarget, targetOfCurrentBlock types.Target) (newTotalTime int64, newTotalTarget types.Target, err error) {
+// blockTotals computes the new total time and total target for the current
+// block from the totals of its parent.
+func blockTotals(currentHeight types.BlockHeight, prevTotalTime int64, parentTimestamp, currentTimestamp types.Timestamp, prevTotalTarget, targetOfCurrentBlock types.Target) (newTotalTime int64, newTotalTarget types.Target) {
 	// Reset the prevTotalTime to a delta of zero just before the hardfork.
 	if currentHeight == types.OakHardforkBlock-1 {
 		prevTotalTime = int64(types.BlockFrequency * currentHeight)
@@ -135,6 +137,14 @@ func (cs *ConsensusSet) storeBlockTotals(tx *bolt.Tx, currentHeight types.BlockH
 	// delta.
 	newTotalTime = (prevTotalTime * types.OakDecayNum / types.OakDecayDenom) + (int64(currentTimestamp) - int64(parentTimestamp))
 	newTotalTarget = prevTotalTarget.MulDifficulty(big.NewRat(types.OakDecayNum, types.OakDecayDenom)).AddDifficulties(targetOfCurrentBlock)
+	return newTotalTime, newTotalTarget
+}
+
+// storeBlockTotals computes the new total time and total target for the current
+// block and stores that new time in the database. It also returns the new
+// totals.
+func (cs *ConsensusSet) storeBlockTotals(tx *bolt.Tx, currentHeight types.BlockHeight, currentBlockID types.BlockID, prevTotalTime int64, parentTimestamp, currentTimestamp types.Timestamp, prevTotalTarget, targetOfCurrentBlock types.Target) (newTotalTime int64, newTotalTarget types.Target, err error) {
+	newTotalTime, newTotalTarget = blockTotals(currentHeight, prevTotalTime, parentTimestamp, currentTimestamp, prevTotalTarget, targetOfCurrentBlock)
 
 	// Store the new total time and total target in the database at the
 	// appropriate id.
@@ -174,15 +184,16 @@ func (cs *ConsensusSet) initOak(tx *bolt.Tx) error {
 	}
 
 	// Store base values for the genesis block.
-	totalTime, totalTarget, err := cs.storeBlockTotals(tx, 0, types.GenesisID, 0, types.GenesisTimestamp, types.GenesisTimestamp, types.RootDepth, types.RootTarget)
+	genesis := cs.blockRoot.Block
+	totalTime, totalTarget, err := cs.storeBlockTotals(tx, 0, genesis.ID(), 0, genesis.Timestamp, genesis.Timestamp, types.RootDepth, cs.blockRoot.ChildTarget)
 	if err != nil {
 		return errors.Extend(errors.New("unable to store genesis block totals"), err)
 	}
 
 	// The Oak fields have not been initialized, scan through the consensus set
 	// and set the fields for each block.
-	parentTimestamp := types.GenesisTimestamp
-	parentChildTarget := types.RootTarget
+	parentTimestamp := genesis.Timestamp
+	parentChildTarget := cs.blockRoot.ChildTarget
 	for i := types.BlockHeight(1); i <= height; i++ { // Skip Genesis block
 		// Fetch the processed block for the current block.
 		id, err := getPath(tx, i)
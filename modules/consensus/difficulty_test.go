@@ -10,19 +10,9 @@ import (
 	"github.com/NebulousLabs/bolt"
 )
 
-// TestChildTargetOak checks the childTargetOak function, espeically for edge
+// TestCalculateTarget checks the CalculateTarget function, espeically for edge
 // cases like overflows and underflows.
-func TestChildTargetOak(t *testing.T) {
-	// NOTE: Test must not be run in parallel.
-	if testing.Short() {
-		t.SkipNow()
-	}
-	cst, err := createConsensusSetTester(t.Name())
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer cst.Close()
-	cs := cst.cs
+func TestCalculateTarget(t *testing.T) {
 	// NOTE: Test must not be run in parallel.
 	//
 	// Set the constants to match the real-network constants, and then make sure
@@ -49,7 +39,7 @@ func TestChildTargetOak(t *testing.T) {
 	parentTarget := types.RootTarget
 	// newTarget should match the root target, as the hashrate and blocktime all
 	// match the existing target - there should be no reason for adjustment.
-	newTarget := cs.childTargetOak(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
+	newTarget := CalculateTarget(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
 	// New target should be barely moving. Some imprecision may cause slight
 	// adjustments, but the total difference should be less than 0.01%.
 	maxNewTarget := parentTarget.MulDifficulty(big.NewRat(10e3, 10001))
@@ -72,7 +62,7 @@ func TestChildTargetOak(t *testing.T) {
 	parentTarget = types.RootTarget
 	// newTarget should be higher, representing reduced difficulty. It should be
 	// as high as the adjustment clamp allows it to move.
-	newTarget = cs.childTargetOak(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
+	newTarget = CalculateTarget(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
 	expectedTarget := parentTarget.MulDifficulty(types.OakMaxDrop)
 	if newTarget.Cmp(expectedTarget) != 0 {
 		t.Log(parentTarget)
@@ -96,7 +86,7 @@ func TestChildTargetOak(t *testing.T) {
 	parentTarget = types.Target{0, 0, 97, 120}
 	// New target should be higher, but the adjustment clamp should not have
 	// kicked in.
-	newTarget = cs.childTargetOak(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
+	newTarget = CalculateTarget(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
 	minNewTarget = parentTarget.MulDifficulty(types.OakMaxDrop)
 	// Check that the difficulty of the new target decreased.
 	if parentTarget.Difficulty().Cmp(newTarget.Difficulty()) <= 0 {
@@ -116,7 +106,7 @@ func TestChildTargetOak(t *testing.T) {
 	parentTarget = types.RootTarget
 	// newTarget should be lower, representing increased difficulty. It should
 	// be as high as the adjustment clamp allows it to move.
-	newTarget = cs.childTargetOak(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
+	newTarget = CalculateTarget(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
 	expectedTarget = parentTarget.MulDifficulty(types.OakMaxRise)
 	if newTarget.Cmp(expectedTarget) != 0 {
 		t.Log(parentTarget)
@@ -140,7 +130,7 @@ func TestChildTargetOak(t *testing.T) {
 	parentTarget = types.Target{0, 0, 0, 0, 0, 0, 93, 70}
 	// New target should be higher, but the adjustment clamp should not have
 	// kicked in.
-	newTarget = cs.childTargetOak(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
+	newTarget = CalculateTarget(parentTotalTime, parentTotalTarget, parentTarget, parentHeight)
 	minNewTarget = parentTarget.MulDifficulty(types.OakMaxRise)
 	// Check that the difficulty of the new target decreased.
 	if parentTarget.Difficulty().Cmp(newTarget.Difficulty()) >= 0 {
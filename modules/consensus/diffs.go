@@ -201,13 +201,25 @@ func commitDiffSet(tx *bolt.Tx, pb *processedBlock, dir modules.DiffDirection) {
 // transactions are allowed to depend on each other. We can't be sure that a
 // transaction is valid unless we have applied all of the previous transactions
 // in the block, which means we need to apply while we verify.
-func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
+//
+// If standaloneValid is true, the transactions of the block have already been
+// checked for standalone validity at the current height, and only the checks
+// that depend on the consensus set are performed.
+func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock, standaloneValid bool) error {
 	// Sanity check - the block being applied should have the current block as
 	// a parent.
 	if build.DEBUG && pb.Block.ParentID != currentBlockID(tx) {
 		panic(errInvalidSuccessor)
 	}
 
+	// Reject blocks that spend the same output twice before anything is
+	// applied. Such a block would fail when the second spend is validated,
+	// but only after the transactions before it had been applied.
+	err := checkDuplicateSpends(pb.Block.Transactions)
+	if err != nil {
+		return err
+	}
+
 	// Create the bucket to hold all of the delayed siacoin outputs created by
 	// transactions this block. Needs to happen before any transactions are
 	// applied.
@@ -217,7 +229,11 @@ func generateAndApplyDiff(tx *bolt.Tx, pb *processedBlock) error {
 	// validated all at once because some transactions may not be valid until
 	// previous transactions have been applied.
 	for _, txn := range pb.Block.Transactions {
-		err := validTransaction(tx, txn)
+		if standaloneValid {
+			err = validTransactionState(tx, txn)
+		} else {
+			err = validTransaction(tx, txn)
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,33 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+var (
+	// maxDoSBlocks is the maximum number of DoS block ids that the consensus
+	// set will remember. Once the limit is reached, the oldest id is forgotten
+	// to make room for a new one.
+	maxDoSBlocks = build.Select(build.Var{
+		Standard: 10000,
+		Dev:      1000,
+		Testing:  10,
+	}).(int)
+)
+
+// addDoSBlock records a block as a DoS block, evicting the oldest recorded
+// DoS blocks if the limit has been reached. Forgetting a DoS block is safe,
+// as the block will simply be validated again, and rejected again, if it is
+// resubmitted. The caller must hold cs.mu.
+func (cs *ConsensusSet) addDoSBlock(id types.BlockID) {
+	if _, exists := cs.dosBlocks[id]; exists {
+		return
+	}
+	for len(cs.dosBlocks) >= maxDoSBlocks && len(cs.dosBlockOrder) > 0 {
+		delete(cs.dosBlocks, cs.dosBlockOrder[0])
+		cs.dosBlockOrder = cs.dosBlockOrder[1:]
+	}
+	cs.dosBlocks[id] = struct{}{}
+	cs.dosBlockOrder = append(cs.dosBlockOrder, id)
+}
@@ -0,0 +1,37 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestDoSBlocksBounded checks that the number of remembered DoS blocks is
+// capped, and that the oldest DoS blocks are forgotten first.
+func TestDoSBlocksBounded(t *testing.T) {
+	cs := &ConsensusSet{
+		dosBlocks: make(map[types.BlockID]struct{}),
+	}
+	var ids []types.BlockID
+	for i := 0; i < maxDoSBlocks*2; i++ {
+		var id types.BlockID
+		id[0] = byte(i)
+		ids = append(ids, id)
+		cs.addDoSBlock(id)
+		if len(cs.dosBlocks) > maxDoSBlocks {
+			t.Fatalf("%v DoS blocks are remembered, limit is %v", len(cs.dosBlocks), maxDoSBlocks)
+		}
+	}
+	for i, id := range ids {
+		_, exists := cs.dosBlocks[id]
+		if exists != (i >= len(ids)-maxDoSBlocks) {
+			t.Errorf("DoS block %v: expected remembered to be %v", i, !exists)
+		}
+	}
+
+	// Adding a known DoS block should not evict anything.
+	cs.addDoSBlock(ids[len(ids)-1])
+	if len(cs.dosBlocks) != maxDoSBlocks || len(cs.dosBlockOrder) != maxDoSBlocks {
+		t.Error("re-adding a DoS block changed the number of DoS blocks")
+	}
+}
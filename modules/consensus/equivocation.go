@@ -0,0 +1,82 @@
+package consensus
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// equivocationKey identifies the blocks paying out to a single miner address
+// at a single height.
+type equivocationKey struct {
+	height  types.BlockHeight
+	address types.UnlockHash
+}
+
+// DetectEquivocation scans every known block, including blocks that are not
+// on the current path, and returns the sets of blocks that share both a
+// height and a miner payout address. A miner producing multiple blocks at the
+// same height is competing with itself, which may indicate that it is trying
+// to cause reorgs. This is a diagnostic only; such blocks are not invalid.
+//
+// The sets are ordered by height, and the ids within each set are sorted.
+func (cs *ConsensusSet) DetectEquivocation() (sets [][]types.BlockID) {
+	err := cs.tg.Add()
+	if err != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	groups := make(map[equivocationKey][]types.BlockID)
+	var keys []equivocationKey
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(BlockMap).ForEach(func(_, pbBytes []byte) error {
+			var pb processedBlock
+			err := cs.marshaler.Unmarshal(pbBytes, &pb)
+			if build.DEBUG && err != nil {
+				panic(err)
+			}
+			id := pb.Block.ID()
+
+			// A block with several payouts to the same address should only
+			// be counted once for that address.
+			seen := make(map[types.UnlockHash]struct{})
+			for _, payout := range pb.Block.MinerPayouts {
+				if _, exists := seen[payout.UnlockHash]; exists {
+					continue
+				}
+				seen[payout.UnlockHash] = struct{}{}
+				key := equivocationKey{height: pb.Height, address: payout.UnlockHash}
+				if _, exists := groups[key]; !exists {
+					keys = append(keys, key)
+				}
+				groups[key] = append(groups[key], id)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].height != keys[j].height {
+			return keys[i].height < keys[j].height
+		}
+		return bytes.Compare(keys[i].address[:], keys[j].address[:]) < 0
+	})
+	for _, key := range keys {
+		ids := groups[key]
+		if len(ids) < 2 {
+			continue
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return bytes.Compare(ids[i][:], ids[j][:]) < 0
+		})
+		sets = append(sets, ids)
+	}
+	return sets
+}
@@ -0,0 +1,68 @@
+package consensus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+)
+
+// TestDetectEquivocation checks that two blocks at the same height paying the
+// same miner address are reported as an equivocation set.
+func TestDetectEquivocation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// An honest chain should not contain any equivocations.
+	if sets := cst.cs.DetectEquivocation(); len(sets) != 0 {
+		t.Fatal("honest chain reported equivocations:", sets)
+	}
+
+	// Create two blocks with the same parent and the same payouts.
+	block, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block1, _ := cst.miner.SolveBlock(block, target)
+	block.Timestamp++
+	block2, _ := cst.miner.SolveBlock(block, target)
+	if block1.ID() == block2.ID() {
+		t.Fatal("blocks should have different ids")
+	}
+	err = cst.cs.AcceptBlock(block1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.AcceptBlock(block2)
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatal(err)
+	}
+
+	sets := cst.cs.DetectEquivocation()
+	if len(sets) != 1 || len(sets[0]) != 2 {
+		t.Fatal("expected a single equivocation set of 2 blocks, got", sets)
+	}
+	id1, id2 := block1.ID(), block2.ID()
+	if bytes.Compare(id1[:], id2[:]) > 0 {
+		id1, id2 = id2, id1
+	}
+	if sets[0][0] != id1 || sets[0][1] != id2 {
+		t.Fatal("equivocation set contains the wrong blocks:", sets[0])
+	}
+
+	// Extending the chain honestly should not add any new equivocations.
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sets := cst.cs.DetectEquivocation(); len(sets) != 1 {
+		t.Fatal("wrong number of equivocation sets:", len(sets))
+	}
+}
@@ -0,0 +1,75 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// paysAddress returns true if any of the proof outputs of the file contract
+// pay out to the provided unlock hash.
+func paysAddress(fc types.FileContract, uh types.UnlockHash) bool {
+	for _, sco := range fc.ValidProofOutputs {
+		if sco.UnlockHash == uh {
+			return true
+		}
+	}
+	for _, sco := range fc.MissedProofOutputs {
+		if sco.UnlockHash == uh {
+			return true
+		}
+	}
+	return false
+}
+
+// FileContract returns the open file contract with the provided id. False is
+// returned if the file contract does not exist or has already been resolved.
+func (cs *ConsensusSet) FileContract(id types.FileContractID) (fc types.FileContract, exists bool) {
+	if err := cs.tg.Add(); err != nil {
+		return types.FileContract{}, false
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		fc, err = getFileContract(tx, id)
+		exists = err == nil
+		return nil
+	})
+	return fc, exists
+}
+
+// FileContractsByAddress returns every open file contract with a valid or
+// missed proof output that pays out to the provided unlock hash. The returned
+// contracts are decoded from the database, and can be modified freely by the
+// caller.
+func (cs *ConsensusSet) FileContractsByAddress(uh types.UnlockHash) map[types.FileContractID]types.FileContract {
+	if err := cs.tg.Add(); err != nil {
+		return nil
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	fcs := make(map[types.FileContractID]types.FileContract)
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(FileContracts).ForEach(func(idBytes, fcBytes []byte) error {
+			var fc types.FileContract
+			err := encoding.Unmarshal(fcBytes, &fc)
+			if build.DEBUG && err != nil {
+				panic(err)
+			}
+			if paysAddress(fc, uh) {
+				var id types.FileContractID
+				copy(id[:], idBytes)
+				fcs[id] = fc
+			}
+			return nil
+		})
+	})
+	return fcs
+}
@@ -0,0 +1,114 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// addFileContract submits a file contract paying 'valid' on a valid proof and
+// 'missed' on a missed proof to the transaction pool, and returns its id.
+func (cst *consensusSetTester) addFileContract(valid, missed types.UnlockHash, windowStart, windowEnd types.BlockHeight) (types.FileContractID, error) {
+	payout := types.NewCurrency64(400e6)
+	fc := types.FileContract{
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		Payout:      payout,
+		ValidProofOutputs: []types.SiacoinOutput{{
+			UnlockHash: valid,
+			Value:      types.PostTax(cst.cs.Height(), payout),
+		}},
+		MissedProofOutputs: []types.SiacoinOutput{{
+			UnlockHash: missed,
+			Value:      types.PostTax(cst.cs.Height(), payout),
+		}},
+	}
+	txnBuilder := cst.wallet.StartTransaction()
+	err := txnBuilder.FundSiacoins(payout)
+	if err != nil {
+		return types.FileContractID{}, err
+	}
+	fcIndex := txnBuilder.AddFileContract(fc)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		return types.FileContractID{}, err
+	}
+	err = cst.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		return types.FileContractID{}, err
+	}
+	return txnSet[len(txnSet)-1].FileContractID(fcIndex), nil
+}
+
+// TestFileContractsByAddress creates several file contracts and checks that
+// the contracts paying each address are returned, including after one of the
+// contracts expires.
+func TestFileContractsByAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	addrX, addrY, addrZ := randAddress(), randAddress(), randAddress()
+	height := cst.cs.Height()
+	fcidA, err := cst.addFileContract(addrX, addrY, height+2, height+3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcidB, err := cst.addFileContract(addrY, addrY, height+20, height+30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fcidC, err := cst.addFileContract(addrZ, randAddress(), height+20, height+30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkContracts checks that exactly the expected contracts pay 'uh'.
+	checkContracts := func(uh types.UnlockHash, expected ...types.FileContractID) {
+		fcs := cst.cs.FileContractsByAddress(uh)
+		if len(fcs) != len(expected) {
+			t.Fatalf("expected %v contracts, got %v", len(expected), len(fcs))
+		}
+		for _, id := range expected {
+			if _, exists := fcs[id]; !exists {
+				t.Fatal("missing contract", id)
+			}
+		}
+	}
+	checkContracts(addrX, fcidA)
+	checkContracts(addrY, fcidA, fcidB)
+	checkContracts(addrZ, fcidC)
+	checkContracts(randAddress())
+
+	// Modifying a returned contract should not affect the consensus set.
+	fc, exists := cst.cs.FileContract(fcidA)
+	if !exists {
+		t.Fatal("contract A does not exist")
+	}
+	fc.ValidProofOutputs[0].UnlockHash = addrZ
+	checkContracts(addrZ, fcidC)
+
+	// Mine past the end of contract A's proof window.
+	for cst.cs.Height() <= height+3 {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, exists := cst.cs.FileContract(fcidA); exists {
+		t.Fatal("expired contract is still reported as open")
+	}
+	checkContracts(addrX)
+	checkContracts(addrY, fcidB)
+	checkContracts(addrZ, fcidC)
+}
@@ -5,12 +5,27 @@ import (
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 
 	"github.com/NebulousLabs/bolt"
 )
 
 var (
 	errExternalRevert = errors.New("cannot revert to block outside of current path")
+	errReorgTooDeep   = errors.New("fork diverges from the current path deeper than the maximum reorg depth")
+)
+
+var (
+	// MaxReorgDepth is the default maximum number of blocks that the
+	// consensus set will revert to move onto a heavier fork. A fork that
+	// diverges from the current path further back than the limit is refused,
+	// even if it is heavier. The limit can be changed through
+	// GenesisParams.
+	MaxReorgDepth = build.Select(build.Var{
+		Standard: types.BlockHeight(5000),
+		Dev:      types.BlockHeight(1000),
+		Testing:  types.BlockHeight(50),
+	}).(types.BlockHeight)
 )
 
 // backtrackToCurrentPath traces backwards from 'pb' until it reaches a block
@@ -47,14 +62,18 @@ func backtrackToCurrentPath(tx *bolt.Tx, pb *processedBlock) []*processedBlock {
 // 'pb' is the current block. Blocks are returned in the order that they were
 // reverted.  'pb' is not reverted.
 func (cs *ConsensusSet) revertToBlock(tx *bolt.Tx, pb *processedBlock) (revertedBlocks []*processedBlock) {
+	// The id of a block is the hash of its merkle root, which covers every
+	// transaction in the block, so it is only computed once.
+	id := pb.Block.ID()
+
 	// Sanity check - make sure that pb is in the current path.
 	currentPathID, err := getPath(tx, pb.Height)
-	if build.DEBUG && (err != nil || currentPathID != pb.Block.ID()) {
+	if build.DEBUG && (err != nil || currentPathID != id) {
 		panic(errExternalRevert)
 	}
 
 	// Rewind blocks until 'pb' is the current block.
-	for currentBlockID(tx) != pb.Block.ID() {
+	for currentBlockID(tx) != id {
 		block := currentProcessedBlock(tx)
 		commitDiffSet(tx, block, modules.DiffRevert)
 		revertedBlocks = append(revertedBlocks, block)
@@ -81,10 +100,15 @@ func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (applie
 		if block.DiffsGenerated {
 			commitDiffSet(tx, block, modules.DiffApply)
 		} else {
-			err := generateAndApplyDiff(tx, block)
+			id := block.Block.ID()
+			height, prevalidated := cs.prevalidatedBlocks[id]
+			err := generateAndApplyDiff(tx, block, prevalidated && height == blockHeight(tx))
 			if err != nil {
-				// Mark the block as invalid.
-				cs.dosBlocks[block.Block.ID()] = struct{}{}
+				// Mark the block as invalid. The caller discards the bolt
+				// transaction, which leaves the consensus set on the block
+				// that was the tip before the fork was attempted.
+				cs.addDoSBlock(id)
+				cs.log.Debugf("WARN: block %v at height %v failed validation while moving onto a fork: %v", id, block.Height, err)
 				return nil, err
 			}
 		}
@@ -103,10 +127,14 @@ func (cs *ConsensusSet) applyUntilBlock(tx *bolt.Tx, pb *processedBlock) (applie
 
 // forkBlockchain will move the consensus set onto the 'newBlock' fork. An
 // error will be returned if any of the blocks applied in the transition are
-// found to be invalid. forkBlockchain is atomic; the ConsensusSet is only
+// found to be invalid, or if moving onto the fork would revert more than
+// cs.maxReorgDepth blocks. forkBlockchain is atomic; the ConsensusSet is only
 // updated if the function returns nil.
 func (cs *ConsensusSet) forkBlockchain(tx *bolt.Tx, newBlock *processedBlock) (revertedBlocks, appliedBlocks []*processedBlock, err error) {
 	commonParent := backtrackToCurrentPath(tx, newBlock)[0]
+	if blockHeight(tx)-commonParent.Height > cs.maxReorgDepth {
+		return nil, nil, errReorgTooDeep
+	}
 	revertedBlocks = cs.revertToBlock(tx, commonParent)
 	appliedBlocks, err = cs.applyUntilBlock(tx, newBlock)
 	if err != nil {
@@ -1,9 +1,14 @@
 package consensus
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // TestBacktrackToCurrentPath probes the backtrackToCurrentPath method of the
@@ -103,3 +108,222 @@ func TestRevertToNode(t *testing.T) {
 	}()
 	cst.cs.dbRevertToNode(pb)
 }
+
+// TestRevertReapplySubsidy checks that reverting blocks removes exactly their
+// miner payouts, and that reapplying them restores an identical consensus set.
+func TestRevertReapplySubsidy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Mine a few blocks, the first of which contains miner fees.
+	_, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocks []types.Block
+	for i := 0; i < 3; i++ {
+		b, err := cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, b)
+	}
+	if len(blocks[0].Transactions) == 0 {
+		t.Fatal("first block has no transactions")
+	}
+	startHeight := cst.cs.Height() - types.BlockHeight(len(blocks)) + 1
+	for i, b := range blocks {
+		height := startHeight + types.BlockHeight(i)
+		payout := types.ZeroCurrency
+		for _, mp := range b.MinerPayouts {
+			payout = payout.Add(mp.Value)
+		}
+		if !payout.Equals(b.CalculateSubsidy(height)) {
+			t.Fatalf("block at height %v paid %v, expected %v", height, payout, b.CalculateSubsidy(height))
+		}
+		_, err := cst.cs.dbGetDSCO(height+types.MaturityDelay, b.MinerPayoutID(0))
+		if err != nil {
+			t.Fatal("miner payout is not a delayed output:", err)
+		}
+	}
+	tip := cst.cs.dbCurrentProcessedBlock()
+	checksum := cst.cs.dbConsensusChecksum()
+
+	// Revert the blocks and check that their payouts are gone.
+	parent, err := cst.cs.dbGetBlockMap(blocks[0].ParentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reverted := cst.cs.dbRevertToNode(parent)
+	if len(reverted) != len(blocks) {
+		t.Fatal("wrong number of blocks reverted:", len(reverted))
+	}
+	for i, b := range blocks {
+		height := startHeight + types.BlockHeight(i)
+		for j := range b.MinerPayouts {
+			_, err := cst.cs.dbGetDSCO(height+types.MaturityDelay, b.MinerPayoutID(uint64(j)))
+			if err == nil {
+				t.Fatal("miner payout survived the revert of its block")
+			}
+		}
+	}
+	if cst.cs.dbConsensusChecksum() == checksum {
+		t.Fatal("reverting blocks did not change the consensus set")
+	}
+
+	// Reapply the blocks.
+	_, applied, err := cst.cs.dbForkBlockchain(tip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(blocks) {
+		t.Fatal("wrong number of blocks reapplied:", len(applied))
+	}
+	if cst.cs.dbConsensusChecksum() != checksum {
+		t.Fatal("consensus set differs after reverting and reapplying blocks")
+	}
+}
+
+// TestMaxReorgDepth checks that the consensus set refuses to move onto a
+// heavier fork that diverges deeper than MaxReorgDepth, without changing the
+// consensus set, and that a fork at exactly the limit is accepted.
+func TestMaxReorgDepth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// reorg gives the main tester a chain of 'depth' blocks, then feeds it a
+	// competing chain of 'depth+1' blocks. The consensus checksum from before
+	// the competing chain was fed is returned, along with the error for the
+	// first block that made the competing chain heavier.
+	reorg := func(name string, depth types.BlockHeight) (*consensusSetTester, crypto.Hash, types.Block, error) {
+		cst, err := blankConsensusSetTester(name + "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cstAlt, err := blankConsensusSetTester(name + "2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cstAlt.Close()
+		for i := types.BlockHeight(0); i < depth; i++ {
+			_, err = cst.miner.AddBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		var fork []types.Block
+		for i := types.BlockHeight(0); i < depth+1; i++ {
+			b, err := cstAlt.miner.AddBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			fork = append(fork, b)
+		}
+		checksum := cst.cs.dbConsensusChecksum()
+		for _, b := range fork {
+			err = cst.cs.AcceptBlock(b)
+			if err != modules.ErrNonExtendingBlock {
+				return cst, checksum, fork[len(fork)-1], err
+			}
+		}
+		t.Fatal("fork never became heavier than the main chain")
+		return nil, crypto.Hash{}, types.Block{}, nil
+	}
+
+	// A fork that diverges one block deeper than the limit is refused.
+	cst, checksum, _, err := reorg(t.Name()+"Deep", MaxReorgDepth+1)
+	defer cst.Close()
+	if err != errReorgTooDeep {
+		t.Fatalf("expected %v, got %v", errReorgTooDeep, err)
+	}
+	if cst.cs.Height() != MaxReorgDepth+1 || cst.cs.dbConsensusChecksum() != checksum {
+		t.Fatal("refused reorg changed the consensus set")
+	}
+
+	// A fork that diverges exactly at the limit is accepted.
+	cstLimit, _, forkTip, err := reorg(t.Name()+"Limit", MaxReorgDepth)
+	defer cstLimit.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cstLimit.cs.CurrentBlock().ID() != forkTip.ID() {
+		t.Fatal("consensus set did not move onto the fork at the reorg limit")
+	}
+}
+
+// TestMaxReorgDepthParams checks that the reorg depth limit can be set through
+// the genesis parameters.
+func TestMaxReorgDepthParams(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+
+	// Create a consensus set that refuses to revert more than 2 blocks.
+	g, err := gateway.New("localhost:0", false, filepath.Join(testdir, modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	params := DefaultGenesisParams()
+	params.MaxReorgDepth = 2
+	cs, err := NewWithParams(g, false, filepath.Join(testdir, modules.ConsensusDir), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	// Mine two competing chains of 3 and 4 blocks.
+	mineChain := func(name string, n int) []types.Block {
+		cst, err := blankConsensusSetTester(t.Name() + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cst.Close()
+		var chain []types.Block
+		for i := 0; i < n; i++ {
+			b, err := cst.miner.AddBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain = append(chain, b)
+		}
+		return chain
+	}
+	current, fork := mineChain("Main", 3), mineChain("Fork", 4)
+	for _, b := range current {
+		err = cs.AcceptBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Moving onto the fork would revert 3 blocks, which is over the limit,
+	// even though the same reorg is allowed by the default limit.
+	if MaxReorgDepth < 3 {
+		t.Fatal("test requires a default reorg depth of at least 3")
+	}
+	for _, b := range fork[:3] {
+		err = cs.AcceptBlock(b)
+		if err != modules.ErrNonExtendingBlock {
+			t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+		}
+	}
+	err = cs.AcceptBlock(fork[3])
+	if err != errReorgTooDeep {
+		t.Fatalf("expected %v, got %v", errReorgTooDeep, err)
+	}
+	if cs.CurrentBlock().ID() != current[2].ID() {
+		t.Fatal("refused reorg changed the current block")
+	}
+}
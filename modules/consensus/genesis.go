@@ -0,0 +1,68 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// GenesisParams holds the values that define the genesis block of a
+// consensus set and the initial state built on top of it. Consensus sets with
+// different parameters have different genesis blocks, and so form separate
+// networks.
+//
+// The block subsidy schedule and the rest of the consensus constants are
+// still read from the types package, as are the genesis checks made by the
+// gateway and by the modules that subscribe to the consensus set.
+type GenesisParams struct {
+	// Timestamp is the timestamp of the genesis block.
+	Timestamp types.Timestamp
+
+	// RootTarget is the target that the first block after the genesis block
+	// must meet.
+	RootTarget types.Target
+
+	// MinerPayoutAddress receives the subsidy of the genesis block once it
+	// matures. The mainnet subsidy is sent to the empty unlock hash, making
+	// it unspendable.
+	MinerPayoutAddress types.UnlockHash
+
+	// SiafundAllocation is the set of siafund outputs created by the genesis
+	// block. The values must add up to types.SiafundCount.
+	SiafundAllocation []types.SiafundOutput
+
+	// Checkpoints are block ids that the network has finalized at the given
+	// heights. They are loaded as if passed to AddCheckpoint when the
+	// consensus set is created.
+	Checkpoints map[types.BlockHeight]types.BlockID
+
+	// MaxReorgDepth is the maximum number of blocks that the consensus set
+	// will revert to move onto a heavier fork. If it is zero, the
+	// compiled-in MaxReorgDepth is used.
+	MaxReorgDepth types.BlockHeight
+}
+
+// DefaultGenesisParams returns the genesis parameters compiled into the types
+// package for the current build, along with the compiled-in checkpoints and
+// reorg depth limit.
+func DefaultGenesisParams() GenesisParams {
+	checkpoints := make(map[types.BlockHeight]types.BlockID, len(knownCheckpoints))
+	for height, id := range knownCheckpoints {
+		checkpoints[height] = id
+	}
+	return GenesisParams{
+		Timestamp:         types.GenesisTimestamp,
+		RootTarget:        types.RootTarget,
+		SiafundAllocation: types.GenesisSiafundAllocation,
+		Checkpoints:       checkpoints,
+		MaxReorgDepth:     MaxReorgDepth,
+	}
+}
+
+// GenesisBlock returns the genesis block defined by the parameters.
+func (p GenesisParams) GenesisBlock() types.Block {
+	return types.Block{
+		Timestamp: p.Timestamp,
+		Transactions: []types.Transaction{
+			{SiafundOutputs: p.SiafundAllocation},
+		},
+	}
+}
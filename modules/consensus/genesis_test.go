@@ -0,0 +1,126 @@
+package consensus
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/modules/gateway"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// solveChild returns a block that extends the current block of the consensus
+// set, paying the block subsidy to an empty unlock hash.
+func solveChild(cs *ConsensusSet) types.Block {
+	b := types.Block{
+		ParentID:  cs.CurrentBlockID(),
+		Timestamp: types.CurrentTimestamp(),
+		MinerPayouts: []types.SiacoinOutput{{
+			Value: types.CalculateCoinbase(cs.Height() + 1),
+		}},
+	}
+	target := cs.CurrentTarget()
+	for !checkHeaderTarget(b.Header(), target) {
+		b.Nonce[0]++
+		if b.Nonce[0] == 0 {
+			b.Nonce[1]++
+		}
+	}
+	return b
+}
+
+// TestGenesisParams checks that consensus sets created with different
+// genesis parameters have different genesis blocks, and that each accepts
+// only the blocks built on its own genesis block.
+func TestGenesisParams(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testdir := build.TempDir(modules.ConsensusDir, t.Name())
+
+	// newCS creates a consensus set with its own gateway in the directory
+	// 'name'.
+	var gateways []*gateway.Gateway
+	defer func() {
+		for _, g := range gateways {
+			g.Close()
+		}
+	}()
+	newCS := func(name string, params GenesisParams) (*ConsensusSet, error) {
+		g, err := gateway.New("localhost:0", false, filepath.Join(testdir, name, modules.GatewayDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gateways = append(gateways, g)
+		return NewWithParams(g, false, filepath.Join(testdir, name, modules.ConsensusDir), params)
+	}
+
+	defaultParams := DefaultGenesisParams()
+	customParams := GenesisParams{
+		Timestamp:  defaultParams.Timestamp - 1e3,
+		RootTarget: types.Target{64},
+		SiafundAllocation: []types.SiafundOutput{{
+			Value:      types.SiafundCount,
+			UnlockHash: types.UnlockHash{1},
+		}},
+	}
+	csDefault, err := newCS("default", defaultParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer csDefault.Close()
+	csCustom, err := newCS("custom", customParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the genesis blocks.
+	if csDefault.CurrentBlockID() != types.GenesisID {
+		t.Fatal("default parameters do not produce the compiled-in genesis block")
+	}
+	customGenesis, _ := csCustom.BlockAtHeight(0)
+	if customGenesis.ID() == types.GenesisID {
+		t.Fatal("custom parameters produce the default genesis block")
+	}
+	if customGenesis.ID() != customParams.GenesisBlock().ID() {
+		t.Fatal("custom consensus set has the wrong genesis block")
+	}
+	if csCustom.CurrentTarget() != customParams.RootTarget {
+		t.Fatal("custom consensus set has the wrong root target")
+	}
+
+	// Each consensus set should accept a block built on its own genesis block
+	// and treat the other block as an orphan.
+	bDefault, bCustom := solveChild(csDefault), solveChild(csCustom)
+	err = csDefault.AcceptBlock(bDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = csCustom.AcceptBlock(bCustom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = csDefault.AcceptBlock(bCustom)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	err = csCustom.AcceptBlock(bDefault)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	if csDefault.Height() != 1 || csCustom.Height() != 1 {
+		t.Fatal("consensus sets did not reach height 1")
+	}
+
+	// The custom database should not load with the default parameters.
+	err = csCustom.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = newCS("custom", defaultParams)
+	if err == nil {
+		t.Fatal("database with a different genesis block was loaded")
+	}
+}
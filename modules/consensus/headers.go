@@ -0,0 +1,379 @@
+package consensus
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// headers.go maintains a tree of block headers that have been accepted
+// through AcceptHeader but whose blocks have not yet been added to the block
+// tree. A header may build on a block in the block tree or on another header
+// in the header tree, which allows a chain of headers to be downloaded and
+// weighed against the current path before any of the block bodies are
+// fetched. A header is removed from the header tree once its block is added
+// to the block tree.
+
+var (
+	// maxPendingHeaders is the maximum number of headers that the consensus
+	// set will hold in memory while waiting for their block bodies.
+	maxPendingHeaders = build.Select(build.Var{
+		Standard: 1000,
+		Dev:      100,
+		Testing:  10,
+	}).(int)
+
+	// headerExpiration is how long, in seconds, a header is held while
+	// waiting for its block body before it is evicted from the header tree.
+	headerExpiration = build.Select(build.Var{
+		Standard: types.Timestamp(3 * 60 * 60),
+		Dev:      types.Timestamp(30 * 60),
+		Testing:  types.Timestamp(60),
+	}).(types.Timestamp)
+)
+
+var (
+	errBodyMismatch       = errors.New("block body does not match the accepted header")
+	errTooManyHeaders     = errors.New("too many headers are waiting for their block bodies")
+	errUnknownHeader      = errors.New("no accepted header has the provided id")
+	errUnknownChildTarget = errors.New("the target of the header cannot be computed until its parent's block is known")
+)
+
+// A headerNode is a header in the header tree, along with the values needed
+// to validate and weigh its children without their parents' block bodies.
+type headerNode struct {
+	header types.BlockHeader
+	height types.BlockHeight
+	depth  types.Target
+
+	// childTarget is the target that the children of the header must meet.
+	// Before the Oak hardfork, the target is adjusted based on a window of
+	// ancestors, and is only known if the header does not start a new
+	// window.
+	childTarget      types.Target
+	childTargetKnown bool
+
+	// totalTime and totalTarget are the Oak totals of the header, see
+	// difficulty.go.
+	totalTime   int64
+	totalTarget types.Target
+
+	received types.Timestamp
+}
+
+// heavierThan returns true if the header is sufficiently heavier than 'cmp'
+// for its chain to replace the chain ending in 'cmp', using the same rule as
+// processedBlock.heavierThan.
+func (hn *headerNode) heavierThan(cmp *processedBlock) bool {
+	requirement := cmp.Depth.AddDifficulties(cmp.ChildTarget.MulDifficulty(SurpassThreshold))
+	return requirement.Cmp(hn.depth) > 0 // Inversed, because the smaller target is actually heavier.
+}
+
+// processedHeaderNode returns a headerNode for a block that is already in the
+// block tree, so that headers building on the block can be validated in the
+// same way as headers building on other headers.
+func (cs *ConsensusSet) processedHeaderNode(tx *bolt.Tx, pb *processedBlock) *headerNode {
+	totalTime, totalTarget := cs.getBlockTotals(tx, pb.Block.ID())
+	return &headerNode{
+		header:           pb.Block.Header(),
+		height:           pb.Height,
+		depth:            pb.Depth,
+		childTarget:      pb.ChildTarget,
+		childTargetKnown: true,
+		totalTime:        totalTime,
+		totalTarget:      totalTarget,
+	}
+}
+
+// newHeaderNode creates the header tree node for a header that builds on
+// 'parent'. The header must already have been validated.
+func (cs *ConsensusSet) newHeaderNode(parent *headerNode, h types.BlockHeader) *headerNode {
+	hn := &headerNode{
+		header:   h,
+		height:   parent.height + 1,
+		depth:    parent.depth.AddDifficulties(parent.childTarget),
+		received: cs.clock.Now(),
+	}
+	hn.totalTime, hn.totalTarget = blockTotals(hn.height, parent.totalTime, parent.header.Timestamp, h.Timestamp, parent.totalTarget, parent.childTarget)
+	if parent.height >= types.OakHardforkBlock {
+		hn.childTarget = CalculateTarget(parent.totalTime, parent.totalTarget, parent.childTarget, parent.height)
+		hn.childTargetKnown = true
+	} else if hn.height%(types.TargetWindow/2) != 0 {
+		hn.childTarget = parent.childTarget
+		hn.childTargetKnown = parent.childTargetKnown
+	}
+	return hn
+}
+
+// minimumValidHeaderTimestamp returns the earliest timestamp that a child of
+// the header can have, following the same rule as
+// minimumValidChildTimestamp. Ancestors are read from the header tree until
+// the block tree is reached.
+func (cs *ConsensusSet) minimumValidHeaderTimestamp(blockMap *bolt.Bucket, hn *headerNode) types.Timestamp {
+	windowTimes := make(types.TimestampSlice, types.MedianTimestampWindow)
+	windowTimes[0] = hn.header.Timestamp
+	parent := hn.header.ParentID
+	for i := uint64(1); i < types.MedianTimestampWindow; i++ {
+		if parent == (types.BlockID{}) {
+			windowTimes[i] = windowTimes[i-1]
+			continue
+		}
+		if node, exists := cs.headers[parent]; exists {
+			windowTimes[i] = node.header.Timestamp
+			parent = node.header.ParentID
+			continue
+		}
+		// See minimumValidChildTimestamp for the layout of the parent's
+		// bytes.
+		parentBytes := blockMap.Get(parent[:])
+		copy(parent[:], parentBytes[:32])
+		windowTimes[i] = types.Timestamp(encoding.DecUint64(parentBytes[40:48]))
+	}
+	sort.Sort(windowTimes)
+	return windowTimes[len(windowTimes)/2]
+}
+
+// validateHeaderInTree checks a header that builds on either the block tree
+// or the header tree, and returns the header tree node for it. Headers that
+// build on the block tree get exactly the checks of validateHeader.
+func (cs *ConsensusSet) validateHeaderInTree(tx *bolt.Tx, h types.BlockHeader) (*headerNode, error) {
+	parent, exists := cs.headers[h.ParentID]
+	if !exists {
+		err := cs.validateHeader(boltTxWrapper{tx}, h)
+		if err != nil {
+			return nil, err
+		}
+		pb, err := getBlockMap(tx, h.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		return cs.newHeaderNode(cs.processedHeaderNode(tx, pb), h), nil
+	}
+
+	id := h.ID()
+	if _, exists := cs.dosBlocks[id]; exists {
+		return nil, modules.ErrDoSBlock
+	}
+	blockMap := tx.Bucket(BlockMap)
+	if blockMap.Get(id[:]) != nil {
+		return nil, modules.ErrBlockKnown
+	}
+	if !parent.childTargetKnown {
+		return nil, errUnknownChildTarget
+	}
+	err := cs.checkCheckpoint(parent.height+1, id)
+	if err != nil {
+		return nil, err
+	}
+	if !checkHeaderTarget(h, parent.childTarget) {
+		return nil, modules.ErrBlockUnsolved
+	}
+	if cs.minimumValidHeaderTimestamp(blockMap, parent) > h.Timestamp {
+		return nil, modules.ErrEarlyTimestamp
+	}
+	if h.Timestamp > cs.clock.Now()+types.ExtremeFutureThreshold {
+		return nil, modules.ErrExtremeFutureTimestamp
+	}
+	return cs.newHeaderNode(parent, h), nil
+}
+
+// removeHeaders removes the headers with the provided ids from the header
+// tree, along with all of their descendants, which can no longer be
+// validated or connected to the block tree. The caller must hold cs.mu.
+func (cs *ConsensusSet) removeHeaders(ids []types.BlockID) {
+	children := make(map[types.BlockID][]types.BlockID)
+	for id, hn := range cs.headers {
+		children[hn.header.ParentID] = append(children[hn.header.ParentID], id)
+	}
+	for len(ids) > 0 {
+		id := ids[len(ids)-1]
+		ids = ids[:len(ids)-1]
+		delete(cs.headers, id)
+		ids = append(ids, children[id]...)
+	}
+}
+
+// pruneHeaders removes the headers that have waited longer than
+// headerExpiration for their block bodies, and the headers that are buried
+// so far below the current height that their blocks could never cause a
+// reorg. The caller must hold cs.mu.
+func (cs *ConsensusSet) pruneHeaders(tx *bolt.Tx) {
+	now := cs.clock.Now()
+	height := blockHeight(tx)
+	var stale []types.BlockID
+	for id, hn := range cs.headers {
+		if hn.received+headerExpiration < now || hn.height+cs.maxReorgDepth < height {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) > 0 {
+		cs.removeHeaders(stale)
+	}
+}
+
+// evictLightestHeader makes room in a full header tree for 'hn' by removing
+// the lightest chain tip, provided that the tip is not the parent of 'hn' and
+// is lighter than 'hn'. False is returned if no header could be evicted. The
+// caller must hold cs.mu.
+func (cs *ConsensusSet) evictLightestHeader(hn *headerNode) bool {
+	hasChildren := make(map[types.BlockID]struct{})
+	for _, node := range cs.headers {
+		hasChildren[node.header.ParentID] = struct{}{}
+	}
+	var lightestID types.BlockID
+	var lightest *headerNode
+	for id, node := range cs.headers {
+		if _, exists := hasChildren[id]; exists || id == hn.header.ParentID {
+			continue
+		}
+		// A larger depth target means less work.
+		if lightest == nil || node.depth.Cmp(lightest.depth) > 0 {
+			lightestID, lightest = id, node
+		}
+	}
+	if lightest == nil || lightest.depth.Cmp(hn.depth) <= 0 {
+		return false
+	}
+	delete(cs.headers, lightestID)
+	return true
+}
+
+// AcceptHeader validates a block header and adds it to the header tree so
+// that the rest of the block can be supplied later through AcceptBlockBody.
+// The header may build on a block in the block tree or on a header that was
+// previously accepted. The same checks that are applied to relayed headers
+// are run: the header must meet its parent's child target and its timestamp
+// must be neither too early nor in the extreme future.
+//
+// Headers are evicted after headerExpiration, or once they fall more than
+// the maximum reorg depth below the current height. When the header tree is
+// full, the lightest chain tip is evicted to make room for a heavier header;
+// if there is no lighter tip, errTooManyHeaders is returned. A header is also
+// removed, along with the headers building on it, if its block is rejected as
+// invalid, whether the block arrives through AcceptBlockBody or AcceptBlock.
+//
+// Before the Oak hardfork, the target only changes at heights that are
+// multiples of types.TargetWindow/2, and the new target is computed from the
+// blocks in the block tree. A header at such a height is accepted, but the
+// headers building on it are rejected with errUnknownChildTarget until its
+// block has been supplied. Headers-first sync below the hardfork height must
+// therefore supply the block bodies at least every types.TargetWindow/2
+// blocks.
+func (cs *ConsensusSet) AcceptHeader(h types.BlockHeader) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	id := h.ID()
+	if _, exists := cs.headers[id]; exists {
+		return modules.ErrBlockKnown
+	}
+	return cs.db.View(func(tx *bolt.Tx) error {
+		cs.pruneHeaders(tx)
+		hn, err := cs.validateHeaderInTree(tx, h)
+		if err != nil {
+			return err
+		}
+		if len(cs.headers) >= maxPendingHeaders && !cs.evictLightestHeader(hn) {
+			return errTooManyHeaders
+		}
+		cs.headers[id] = hn
+		return nil
+	})
+}
+
+// HeaviestHeader returns the id and height of the heaviest header in the
+// header tree, and whether the chain ending in that header is heavy enough to
+// replace the current path once its block bodies are supplied. False is
+// returned if the header tree is empty.
+func (cs *ConsensusSet) HeaviestHeader() (id types.BlockID, height types.BlockHeight, heavier bool) {
+	err := cs.tg.Add()
+	if err != nil {
+		return types.BlockID{}, 0, false
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	var heaviest *headerNode
+	for hid, hn := range cs.headers {
+		if heaviest == nil || hn.depth.Cmp(heaviest.depth) < 0 {
+			id, heaviest = hid, hn
+		}
+	}
+	if heaviest == nil {
+		return types.BlockID{}, 0, false
+	}
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		heavier = heaviest.heavierThan(currentProcessedBlock(tx))
+		return nil
+	})
+	return id, heaviest.height, heavier
+}
+
+// AcceptBlockBody combines the miner payouts and transactions of a block with
+// a header previously accepted by AcceptHeader, and adds the resulting block
+// to the consensus set in the same way as AcceptBlock. An error is returned if
+// the header is unknown, or if the body does not match the header's merkle
+// root. If the block has already been added to the consensus set, the header
+// will have been discarded and modules.ErrBlockKnown is returned.
+//
+// A body whose parent block is not yet known is held as an orphan until the
+// parent's body is supplied. If the block turns out to be invalid, its header
+// and all of the headers building on it are removed from the header tree.
+func (cs *ConsensusSet) AcceptBlockBody(id types.BlockID, minerPayouts []types.SiacoinOutput, txns []types.Transaction) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+
+	cs.mu.Lock()
+	hn, exists := cs.headers[id]
+	if !exists {
+		var known bool
+		_ = cs.db.View(func(tx *bolt.Tx) error {
+			known = tx.Bucket(BlockMap).Get(id[:]) != nil
+			return nil
+		})
+		cs.mu.Unlock()
+		if known {
+			return modules.ErrBlockKnown
+		}
+		return errUnknownHeader
+	}
+	h := hn.header
+	b := types.Block{
+		ParentID:     h.ParentID,
+		Nonce:        h.Nonce,
+		Timestamp:    h.Timestamp,
+		MinerPayouts: minerPayouts,
+		Transactions: txns,
+	}
+	if b.MerkleRoot() != h.MerkleRoot {
+		cs.mu.Unlock()
+		return errBodyMismatch
+	}
+	cs.mu.Unlock()
+
+	// The header is removed from the header tree by acceptBlocks once the
+	// block reaches the block tree, or if the block is invalid.
+	chainExtended, err := cs.managedAcceptBlocks([]types.Block{b})
+	if err != nil {
+		return err
+	}
+	if chainExtended {
+		cs.managedBroadcastBlock(b)
+	}
+	return nil
+}
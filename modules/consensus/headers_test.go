@@ -0,0 +1,442 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestAcceptHeaderThenBody accepts a block's header before its body, checking
+// that the block is added once the matching body arrives.
+func TestAcceptHeaderThenBody(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Put a transaction in the block so that the body is not trivial.
+	_, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = cst.miner.SolveBlock(b, target)
+	if len(b.Transactions) == 0 {
+		t.Fatal("block has no transactions")
+	}
+	id := b.ID()
+
+	err = cst.cs.AcceptHeader(b.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.AcceptHeader(b.Header())
+	if err != modules.ErrBlockKnown {
+		t.Fatalf("expected %v, got %v", modules.ErrBlockKnown, err)
+	}
+	if cst.cs.CurrentBlockID() == id {
+		t.Fatal("header alone extended the chain")
+	}
+
+	// A body that does not match the header should be rejected without
+	// discarding the header.
+	err = cst.cs.AcceptBlockBody(id, b.MinerPayouts, nil)
+	if err != errBodyMismatch {
+		t.Fatalf("expected %v, got %v", errBodyMismatch, err)
+	}
+	err = cst.cs.AcceptBlockBody(id, b.MinerPayouts, b.Transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.CurrentBlockID() != id {
+		t.Fatal("block was not added after its body was accepted")
+	}
+
+	// Supplying the body again should report that the block is known.
+	err = cst.cs.AcceptBlockBody(id, b.MinerPayouts, b.Transactions)
+	if err != modules.ErrBlockKnown {
+		t.Fatalf("expected %v, got %v", modules.ErrBlockKnown, err)
+	}
+	err = cst.cs.AcceptHeader(b.Header())
+	if err != modules.ErrBlockKnown {
+		t.Fatalf("expected %v, got %v", modules.ErrBlockKnown, err)
+	}
+}
+
+// TestAcceptBodyWithoutHeader checks that block bodies are rejected unless
+// their header has been accepted, and that pending headers are discarded when
+// the full block arrives by other means.
+func TestAcceptBodyWithoutHeader(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	b, err := cst.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.AcceptBlockBody(b.ID(), b.MinerPayouts, b.Transactions)
+	if err != errUnknownHeader {
+		t.Fatalf("expected %v, got %v", errUnknownHeader, err)
+	}
+
+	// Headers with an unknown parent are not accepted.
+	orphan := b.Header()
+	orphan.ParentID = types.BlockID{1}
+	err = cst.cs.AcceptHeader(orphan)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+
+	// Accept the header, then the full block.
+	err = cst.cs.AcceptHeader(b.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cst.cs.mu.RLock()
+	numPending := len(cst.cs.headers)
+	cst.cs.mu.RUnlock()
+	if numPending != 0 {
+		t.Fatal("header was not discarded after its block was accepted")
+	}
+	err = cst.cs.AcceptBlockBody(b.ID(), b.MinerPayouts, b.Transactions)
+	if err != modules.ErrBlockKnown {
+		t.Fatalf("expected %v, got %v", modules.ErrBlockKnown, err)
+	}
+}
+
+// TestHeaderChain accepts a chain of headers that build on each other,
+// checking that the chain is weighed against the current path and that the
+// blocks are added as their bodies arrive, even out of order.
+func TestHeaderChain(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	for cst.cs.Height() < 40 {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var blocks []types.Block
+	for height := types.BlockHeight(1); height <= cst.cs.Height(); height++ {
+		b, _ := cst.cs.BlockAtHeight(height)
+		blocks = append(blocks, b)
+	}
+	cst2, err := blankConsensusSetTester(t.Name() + "-headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	base := 25
+	_, err = cst2.cs.AcceptBlocks(blocks[:base])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A sibling of the current block is not heavy enough to replace it.
+	sibling := blocks[base-1]
+	sibling.Timestamp++
+	target, _ := cst.cs.ChildTarget(sibling.ParentID)
+	sibling = solveAtTarget(sibling, target)
+	err = cst2.cs.AcceptHeader(sibling.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, heavier := cst2.cs.HeaviestHeader(); heavier {
+		t.Fatal("sibling header reported as heavier than the current path")
+	}
+
+	// Accept a chain of headers building on the current block. The targets
+	// of the headers should match the targets of the blocks.
+	chain := blocks[base : base+8]
+	for _, b := range chain {
+		err = cst2.cs.AcceptHeader(b.Header())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cst2.cs.mu.RLock()
+	for i, b := range chain {
+		hn := cst2.cs.headers[b.ID()]
+		target, _ := cst.cs.ChildTarget(b.ID())
+		if hn.height != types.BlockHeight(base+i+1) || hn.childTarget != target {
+			t.Error("header tree disagrees with the block tree at height", hn.height)
+		}
+	}
+	cst2.cs.mu.RUnlock()
+	id, height, heavier := cst2.cs.HeaviestHeader()
+	if id != chain[len(chain)-1].ID() || height != types.BlockHeight(base+len(chain)) || !heavier {
+		t.Fatal("header chain should be the heaviest and replace the current path", height, heavier)
+	}
+
+	// Supply the last body first; it is held until its parent arrives.
+	last := chain[len(chain)-1]
+	err = cst2.cs.AcceptBlockBody(last.ID(), last.MinerPayouts, last.Transactions)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	for _, b := range chain[:len(chain)-1] {
+		err = cst2.cs.AcceptBlockBody(b.ID(), b.MinerPayouts, b.Transactions)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cst2.cs.CurrentBlock().ID() != last.ID() {
+		t.Fatal("header chain was not added to the block tree")
+	}
+	id, _, heavier = cst2.cs.HeaviestHeader()
+	if id != sibling.ID() || heavier {
+		t.Fatal("only the sibling header should remain in the header tree")
+	}
+}
+
+// TestHeaderTreeEviction checks that a full header tree makes room for
+// heavier headers, and that headers are evicted once they are old or buried
+// deep below the current height.
+func TestHeaderTreeEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	base := 25
+	for cst.cs.Height() < types.BlockHeight(base)+MaxReorgDepth+3 {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var blocks []types.Block
+	for height := types.BlockHeight(1); height <= cst.cs.Height(); height++ {
+		b, _ := cst.cs.BlockAtHeight(height)
+		blocks = append(blocks, b)
+	}
+	cst2, err := blankConsensusSetTester(t.Name() + "-headers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+	_, err = cst2.cs.AcceptBlocks(blocks[:base])
+	if err != nil {
+		t.Fatal(err)
+	}
+	numHeaders := func() int {
+		cst2.cs.mu.RLock()
+		defer cst2.cs.mu.RUnlock()
+		return len(cst2.cs.headers)
+	}
+
+	// Fill the header tree with siblings of the current block.
+	target, _ := cst.cs.ChildTarget(blocks[base-1].ParentID)
+	sibling := func(i int) types.Block {
+		b := blocks[base-1]
+		b.MinerPayouts = []types.SiacoinOutput{{
+			Value:      b.CalculateSubsidy(types.BlockHeight(base)),
+			UnlockHash: types.UnlockHash{byte(i), byte(i >> 8)},
+		}}
+		return solveAtTarget(b, target)
+	}
+	for i := 0; i < maxPendingHeaders; i++ {
+		err = cst2.cs.AcceptHeader(sibling(i).Header())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another sibling is no heavier than the headers in the tree, but a
+	// header extending the current block is.
+	err = cst2.cs.AcceptHeader(sibling(maxPendingHeaders).Header())
+	if err != errTooManyHeaders {
+		t.Fatalf("expected %v, got %v", errTooManyHeaders, err)
+	}
+	err = cst2.cs.AcceptHeader(blocks[base].Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := numHeaders(); n != maxPendingHeaders {
+		t.Fatalf("expected %v headers, got %v", maxPendingHeaders, n)
+	}
+
+	// Once the headers are old, they are evicted to make room.
+	cst2.cs.mu.Lock()
+	cst2.cs.clock = mockClock{now: types.CurrentTimestamp() + headerExpiration + 1}
+	cst2.cs.mu.Unlock()
+	err = cst2.cs.AcceptHeader(sibling(maxPendingHeaders).Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := numHeaders(); n != 1 {
+		t.Fatalf("expected 1 header, got %v", n)
+	}
+
+	// Headers buried more than MaxReorgDepth blocks deep are evicted.
+	cst2.cs.mu.Lock()
+	cst2.cs.clock = types.StdClock{}
+	cst2.cs.mu.Unlock()
+	tip := base + int(MaxReorgDepth) + 2
+	_, err = cst2.cs.AcceptBlocks(blocks[base:tip])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst2.cs.AcceptHeader(blocks[tip].Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _, heavier := cst2.cs.HeaviestHeader()
+	if n := numHeaders(); n != 1 || id != blocks[tip].ID() || !heavier {
+		t.Fatal("buried header was not evicted")
+	}
+}
+
+// TestInvalidBlockRemovesHeaders checks that a header and the headers
+// building on it are removed from the header tree when its block is rejected
+// through AcceptBlock.
+func TestInvalidBlockRemovesHeaders(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Create a block that meets its target but pays out too much.
+	b, target, err := cst.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.MinerPayouts = []types.SiacoinOutput{{
+		Value:      b.CalculateSubsidy(cst.cs.Height() + 1).Add(types.SiacoinPrecision),
+		UnlockHash: types.UnlockHash{1},
+	}}
+	b = solveAtTarget(b, target)
+	err = cst.cs.AcceptHeader(b.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build a child header on top of it, so that the header chain is heavier
+	// than the current path.
+	cst.cs.mu.RLock()
+	childTarget := cst.cs.headers[b.ID()].childTarget
+	cst.cs.mu.RUnlock()
+	child := solveAtTarget(types.Block{
+		ParentID:  b.ID(),
+		Timestamp: b.Timestamp,
+	}, childTarget)
+	err = cst.cs.AcceptHeader(child.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _, heavier := cst.cs.HeaviestHeader(); id != child.ID() || !heavier {
+		t.Fatal("header chain should be heavier than the current path")
+	}
+
+	// Rejecting the block should discard both headers.
+	err = cst.cs.AcceptBlock(b)
+	if err != modules.ErrBadMinerPayouts {
+		t.Fatalf("expected %v, got %v", modules.ErrBadMinerPayouts, err)
+	}
+	cst.cs.mu.RLock()
+	numPending := len(cst.cs.headers)
+	cst.cs.mu.RUnlock()
+	if numPending != 0 {
+		t.Fatalf("expected no headers, got %v", numPending)
+	}
+	if _, _, heavier := cst.cs.HeaviestHeader(); heavier {
+		t.Fatal("invalid header chain still reported as heavier")
+	}
+}
+
+// TestHeaderUnknownChildTarget checks that, before the Oak hardfork, headers
+// cannot build on a header that starts a new target window.
+func TestHeaderUnknownChildTarget(t *testing.T) {
+	// NOTE: Test must not be run in parallel.
+	if testing.Short() {
+		t.SkipNow()
+	}
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Move the hardfork past the first target window so that the header
+	// tree uses the old difficulty rules, and make sure it is reset at the
+	// end of the test.
+	oldOakHardforkBlock := types.OakHardforkBlock
+	types.OakHardforkBlock = types.TargetWindow
+	defer func() {
+		types.OakHardforkBlock = oldOakHardforkBlock
+	}()
+
+	// Place a header just below the end of the first half window in the
+	// header tree.
+	genesis := cst.cs.CurrentBlock()
+	parent := types.BlockHeader{
+		ParentID:  genesis.ID(),
+		Timestamp: types.CurrentTimestamp(),
+	}
+	cst.cs.mu.Lock()
+	cst.cs.headers[parent.ID()] = &headerNode{
+		header:           parent,
+		height:           types.TargetWindow/2 - 1,
+		depth:            types.RootDepth,
+		childTarget:      types.RootTarget,
+		childTargetKnown: true,
+		totalTime:        int64(types.BlockFrequency * (types.TargetWindow/2 - 1)),
+		totalTarget:      types.RootDepth,
+		received:         parent.Timestamp,
+	}
+	cst.cs.mu.Unlock()
+
+	// A header at the window boundary is accepted, but its children are not.
+	boundary := solveAtTarget(types.Block{
+		ParentID:  parent.ID(),
+		Timestamp: parent.Timestamp,
+	}, types.RootTarget)
+	err = cst.cs.AcceptHeader(boundary.Header())
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := solveAtTarget(types.Block{
+		ParentID:  boundary.ID(),
+		Timestamp: boundary.Timestamp,
+	}, types.RootTarget)
+	err = cst.cs.AcceptHeader(child.Header())
+	if err != errUnknownChildTarget {
+		t.Fatalf("expected %v, got %v", errUnknownChildTarget, err)
+	}
+}
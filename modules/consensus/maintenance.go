@@ -144,6 +144,43 @@ func applyMissedStorageProof(tx *bolt.Tx, pb *processedBlock, fcid types.FileCon
 	return dscods, fcd
 }
 
+// contractEvents returns the events for the file contracts resolved by a
+// block. A contract is resolved either by a storage proof in one of the
+// block's transactions, or by reaching the end of its proof window, in which
+// case applyFileContractMaintenance removes it at that height. Contracts that
+// are removed because they were revised do not produce an event.
+func contractEvents(pb *processedBlock) []modules.ContractEvent {
+	proven := make(map[types.FileContractID]struct{})
+	for _, txn := range pb.Block.Transactions {
+		for _, sp := range txn.StorageProofs {
+			proven[sp.ParentID] = struct{}{}
+		}
+	}
+
+	var events []modules.ContractEvent
+	for _, fcd := range pb.FileContractDiffs {
+		if fcd.Direction != modules.DiffRevert {
+			continue
+		}
+		event := modules.ContractEvent{
+			Direction: modules.DiffApply,
+			ID:        fcd.ID,
+			Height:    pb.Height,
+		}
+		if _, exists := proven[fcd.ID]; exists {
+			event.Type = modules.ContractEventValidProof
+			event.Payouts = fcd.FileContract.ValidProofOutputs
+		} else if fcd.FileContract.WindowEnd == pb.Height {
+			event.Type = modules.ContractEventMissedProof
+			event.Payouts = fcd.FileContract.MissedProofOutputs
+		} else {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
 // applyFileContractMaintenance looks for all of the file contracts that have
 // expired without an appropriate storage proof, and calls 'applyMissedProof'
 // for the file contract.
@@ -0,0 +1,158 @@
+package consensus
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// maxOrphanBlocks is the maximum number of orphan blocks that the
+	// consensus set will hold in memory while waiting for their parents.
+	maxOrphanBlocks = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  10,
+	}).(int)
+
+	// orphanExpiration is how long, in seconds, an orphan block is held
+	// before it is considered stale and evicted from the orphan pool. A
+	// parent that has not arrived by then is unlikely to arrive at all.
+	orphanExpiration = build.Select(build.Var{
+		Standard: types.Timestamp(60 * 60),
+		Dev:      types.Timestamp(10 * 60),
+		Testing:  types.Timestamp(60),
+	}).(types.Timestamp)
+
+	// orphanTargetSlack is how many times easier than the child target of the
+	// current block an orphan's target may be. The parent of an orphan is
+	// unknown, so its exact target cannot be checked, but any parent worth
+	// waiting for is close to the current block and has a similar target.
+	orphanTargetSlack = build.Select(build.Var{
+		Standard: int64(1000),
+		Dev:      int64(100),
+		Testing:  int64(1),
+	}).(int64)
+)
+
+// An orphanBlock is a block whose parent is not yet known, along with the time
+// at which it was received.
+type orphanBlock struct {
+	block    types.Block
+	received types.Timestamp
+}
+
+// minimumOrphanTarget returns the easiest target that an orphan block must
+// meet to be held in the orphan pool.
+func minimumOrphanTarget(tx *bolt.Tx) types.Target {
+	childTarget := currentProcessedBlock(tx).ChildTarget
+	return childTarget.MulDifficulty(big.NewRat(1, orphanTargetSlack))
+}
+
+// addOrphan stores a block whose parent is not yet known so that it can be
+// accepted once the parent arrives. Blocks without enough work to plausibly
+// extend a nearby chain are not held. Stale orphans are evicted first, and if
+// the orphan pool is still full the oldest orphan is evicted to make room.
+// The caller must hold cs.mu.
+func (cs *ConsensusSet) addOrphan(tx *bolt.Tx, b types.Block, id types.BlockID) {
+	if !checkHeaderTarget(b.Header(), minimumOrphanTarget(tx)) {
+		return
+	}
+	for _, orphan := range cs.orphanBlocks[b.ParentID] {
+		if orphan.block.ID() == id {
+			return
+		}
+	}
+	now := cs.clock.Now()
+	cs.evictStaleOrphans(now)
+	if cs.numOrphanBlocks >= maxOrphanBlocks {
+		cs.evictOldestOrphan()
+	}
+	cs.orphanBlocks[b.ParentID] = append(cs.orphanBlocks[b.ParentID], orphanBlock{
+		block:    b,
+		received: now,
+	})
+	cs.numOrphanBlocks++
+}
+
+// evictOldestOrphan removes the orphan that was received first. The caller
+// must hold cs.mu.
+func (cs *ConsensusSet) evictOldestOrphan() {
+	var oldestParent types.BlockID
+	oldestIndex := -1
+	var oldest types.Timestamp
+	for parentID, orphans := range cs.orphanBlocks {
+		for i, orphan := range orphans {
+			if oldestIndex == -1 || orphan.received < oldest {
+				oldestParent, oldestIndex, oldest = parentID, i, orphan.received
+			}
+		}
+	}
+	if oldestIndex == -1 {
+		return
+	}
+	orphans := cs.orphanBlocks[oldestParent]
+	orphans = append(orphans[:oldestIndex], orphans[oldestIndex+1:]...)
+	if len(orphans) == 0 {
+		delete(cs.orphanBlocks, oldestParent)
+	} else {
+		cs.orphanBlocks[oldestParent] = orphans
+	}
+	cs.numOrphanBlocks--
+}
+
+// evictStaleOrphans removes every orphan that was received more than
+// orphanExpiration before 'now'. The caller must hold cs.mu.
+func (cs *ConsensusSet) evictStaleOrphans(now types.Timestamp) {
+	for parentID, orphans := range cs.orphanBlocks {
+		var fresh []orphanBlock
+		for _, orphan := range orphans {
+			if orphan.received+orphanExpiration >= now {
+				fresh = append(fresh, orphan)
+			}
+		}
+		cs.numOrphanBlocks -= len(orphans) - len(fresh)
+		if len(fresh) == 0 {
+			delete(cs.orphanBlocks, parentID)
+		} else {
+			cs.orphanBlocks[parentID] = fresh
+		}
+	}
+}
+
+// managedAcceptOrphans accepts every orphan that descends from the provided
+// blocks, including orphans of orphans. The lock is acquired separately for
+// each orphan. The orphans that extended the longest chain are returned, so
+// that they can be relayed.
+func (cs *ConsensusSet) managedAcceptOrphans(parents []types.BlockID) (extending []types.Block) {
+	for len(parents) > 0 {
+		cs.mu.Lock()
+		orphans := cs.orphanBlocks[parents[0]]
+		delete(cs.orphanBlocks, parents[0])
+		cs.numOrphanBlocks -= len(orphans)
+		cs.mu.Unlock()
+		parents = parents[1:]
+
+		for _, orphan := range orphans {
+			cs.mu.Lock()
+			start := time.Now()
+			extended, added, err := cs.acceptBlocks([]types.Block{orphan.block})
+			cs.metrics.BlocksAccepted += uint64(len(added))
+			cs.metrics.ValidationTime += time.Since(start)
+			cs.mu.Unlock()
+			if err != nil && err != modules.ErrNonExtendingBlock {
+				cs.log.Debugln("WARN: failed to accept an orphan block:", err)
+			}
+			if extended {
+				extending = append(extending, orphan.block)
+			}
+			parents = append(parents, added...)
+		}
+	}
+	return extending
+}
@@ -0,0 +1,223 @@
+package consensus
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestOrphanBlocks delivers a fork to the consensus set in reverse order and
+// checks that the orphans are attached once their parents arrive.
+func TestOrphanBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	// Give the main tester a chain of 1 block and the alternate tester a
+	// competing chain of 3 blocks.
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fork []types.Block
+	for i := 0; i < 3; i++ {
+		b, err := cstAlt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork = append(fork, b)
+	}
+
+	// Deliver the fork in reverse order. The first two blocks are orphans.
+	for i := len(fork) - 1; i > 0; i-- {
+		err = cst.cs.AcceptBlock(fork[i])
+		if err != modules.ErrOrphan {
+			t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+		}
+	}
+	// Delivering the same orphan again should not grow the pool.
+	err = cst.cs.AcceptBlock(fork[2])
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	if cst.cs.numOrphanBlocks != 2 {
+		t.Fatal("wrong number of orphans:", cst.cs.numOrphanBlocks)
+	}
+
+	// The first block of the fork does not extend the chain by itself, but
+	// attaching its orphans does. The attached orphans should be counted and
+	// relayed along with the block that attached them.
+	mg := &mockGatewayDoesBroadcast{
+		Gateway:         cst.cs.gateway,
+		broadcastCalled: make(chan struct{}, len(fork)),
+	}
+	cst.cs.gateway = mg
+	before := cst.cs.Metrics()
+	err = cst.cs.AcceptBlock(fork[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range fork {
+		select {
+		case <-mg.broadcastCalled:
+		case <-time.After(time.Second):
+			t.Fatalf("expected %v broadcasts, got %v", len(fork), i)
+		}
+	}
+	if accepted := cst.cs.Metrics().BlocksAccepted - before.BlocksAccepted; accepted != uint64(len(fork)) {
+		t.Fatalf("expected %v blocks to be counted, got %v", len(fork), accepted)
+	}
+	if cst.cs.CurrentBlock().ID() != fork[len(fork)-1].ID() {
+		t.Fatal("consensus set did not converge on the fork")
+	}
+	if cst.cs.numOrphanBlocks != 0 || len(cst.cs.orphanBlocks) != 0 {
+		t.Fatal("orphan pool was not emptied")
+	}
+}
+
+// solveOrphan changes the nonce of a block until it meets the minimum orphan
+// target of the consensus set.
+func (cst *consensusSetTester) solveOrphan(b types.Block) types.Block {
+	return solveAtTarget(b, cst.cs.CurrentTarget().MulDifficulty(big.NewRat(1, orphanTargetSlack)))
+}
+
+// TestOrphanBlocksCap checks that the orphan pool does not grow beyond
+// maxOrphanBlocks.
+func TestOrphanBlocksCap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Each orphan is received a second after the previous one, so that the
+	// oldest orphan is well defined.
+	now := types.CurrentTimestamp()
+	var orphans []types.Block
+	for i := 0; i < maxOrphanBlocks+5; i++ {
+		orphan := cst.solveOrphan(types.Block{
+			ParentID:  types.BlockID{byte(i), byte(i >> 8), 1},
+			Timestamp: now,
+		})
+		orphans = append(orphans, orphan)
+		cst.cs.mu.Lock()
+		cst.cs.clock = mockClock{now: now + types.Timestamp(i)}
+		cst.cs.mu.Unlock()
+		err = cst.cs.AcceptBlock(orphan)
+		if err != modules.ErrOrphan {
+			t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+		}
+	}
+	if cst.cs.numOrphanBlocks != maxOrphanBlocks {
+		t.Fatal("orphan pool exceeded its cap:", cst.cs.numOrphanBlocks)
+	}
+
+	// The oldest orphans should have been evicted to make room for the newest.
+	for i, orphan := range orphans {
+		_, held := cst.cs.orphanBlocks[orphan.ParentID]
+		if held != (i >= 5) {
+			t.Errorf("orphan %v: expected held to be %v", i, i >= 5)
+		}
+	}
+}
+
+// TestOrphanBlocksTarget checks that orphans without enough work to plausibly
+// extend a nearby chain are not held in the orphan pool.
+func TestOrphanBlocksTarget(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Find a nonce that does not meet the minimum orphan target.
+	target := cst.cs.CurrentTarget().MulDifficulty(big.NewRat(1, orphanTargetSlack))
+	orphan := types.Block{
+		ParentID:  types.BlockID{1},
+		Timestamp: types.CurrentTimestamp(),
+	}
+	for checkHeaderTarget(orphan.Header(), target) {
+		orphan.Nonce[0]++
+	}
+	err = cst.cs.AcceptBlock(orphan)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	if cst.cs.numOrphanBlocks != 0 {
+		t.Fatal("low work orphan was held in the orphan pool")
+	}
+}
+
+// TestOrphanBlocksExpire checks that stale orphans are evicted to make room
+// for new ones.
+func TestOrphanBlocksExpire(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	now := types.CurrentTimestamp()
+	newOrphan := func(i int) types.Block {
+		return cst.solveOrphan(types.Block{
+			ParentID:  types.BlockID{byte(i), byte(i >> 8), 1},
+			Timestamp: now,
+		})
+	}
+	cst.cs.mu.Lock()
+	cst.cs.clock = mockClock{now: now}
+	cst.cs.mu.Unlock()
+
+	// Fill the orphan pool. Further orphans replace the oldest orphans.
+	for i := 0; i <= maxOrphanBlocks; i++ {
+		err = cst.cs.AcceptBlock(newOrphan(i))
+		if err != modules.ErrOrphan {
+			t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+		}
+	}
+	if cst.cs.numOrphanBlocks != maxOrphanBlocks {
+		t.Fatal("wrong number of orphans:", cst.cs.numOrphanBlocks)
+	}
+
+	// Once the orphans are stale, they are evicted when a new orphan arrives.
+	cst.cs.mu.Lock()
+	cst.cs.clock = mockClock{now: now + orphanExpiration + 1}
+	cst.cs.mu.Unlock()
+	orphan := newOrphan(maxOrphanBlocks + 1)
+	err = cst.cs.AcceptBlock(orphan)
+	if err != modules.ErrOrphan {
+		t.Fatalf("expected %v, got %v", modules.ErrOrphan, err)
+	}
+	if cst.cs.numOrphanBlocks != 1 || len(cst.cs.orphanBlocks) != 1 {
+		t.Fatal("stale orphans were not evicted:", cst.cs.numOrphanBlocks)
+	}
+	if len(cst.cs.orphanBlocks[orphan.ParentID]) != 1 {
+		t.Fatal("new orphan was not added to the pool")
+	}
+}
@@ -1,12 +1,16 @@
 package consensus
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/gateway"
+
+	"github.com/NebulousLabs/bolt"
 )
 
 // TestSaveLoad populates a blockchain, saves it, loads it, and checks
@@ -41,3 +45,94 @@ func TestSaveLoad(t *testing.T) {
 		t.Fatal("consensus set hash changed after load")
 	}
 }
+
+// TestSaveLoadAcceptNextBlock checks that a consensus set that has been
+// reloaded from disk accepts the next block in exactly the same way as the
+// consensus set that saved it.
+func TestSaveLoadAcceptNextBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cst.testBlockSuite()
+
+	// Find the next block, but snapshot the database before submitting it.
+	block, err := cst.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadDir := build.TempDir(modules.ConsensusDir, t.Name(), "load")
+	err = os.MkdirAll(loadDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(filepath.Join(loadDir, DatabaseFilename), 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldHeight := cst.cs.Height()
+	err = cst.cs.AcceptBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedHash := cst.cs.dbConsensusChecksum()
+
+	// Load the snapshot and submit the same block.
+	g, err := gateway.New("localhost:0", false, build.TempDir(modules.ConsensusDir, t.Name(), "loadgateway"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	cs, err := New(g, false, loadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+	if cs.Height() != oldHeight || cs.CurrentBlock().ID() != block.ParentID {
+		t.Fatal("reloaded consensus set has the wrong tip")
+	}
+	err = cs.AcceptBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cs.CurrentBlock().ID() != block.ID() {
+		t.Fatal("reloaded consensus set did not extend with the next block")
+	}
+	if cs.dbConsensusChecksum() != expectedHash {
+		t.Fatal("reloaded consensus set diverged after accepting the next block")
+	}
+}
+
+// TestLoadCorruptDatabase checks that loading a corrupt consensus database
+// returns an error instead of panicking.
+func TestLoadCorruptDatabase(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	dir := build.TempDir(modules.ConsensusDir, t.Name(), modules.ConsensusDir)
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, DatabaseFilename), []byte("not a consensus database"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gateway.New("localhost:0", false, build.TempDir(modules.ConsensusDir, t.Name(), modules.GatewayDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	_, err = New(g, false, dir)
+	if err == nil {
+		t.Fatal("expected an error when loading a corrupt database")
+	}
+}
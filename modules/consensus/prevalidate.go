@@ -0,0 +1,125 @@
+package consensus
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// prevalidateBlocks checks the transactions of a set of consecutive blocks for
+// standalone validity, spreading the work across one goroutine per core. These
+// checks include signature verification, which dominates the cost of
+// validating a block, but depend on nothing in the consensus set besides the
+// height, so they are performed without holding cs.mu.
+//
+// Because the work is done before the header of each block is validated, only
+// blocks that pass the cheap header checks are prevalidated: the block must
+// not be a known DoS block or already be in the block tree, its parent must
+// be known, and it must meet the child target of the parent of the run. The
+// run is cut at the first block that fails these checks, so that a peer
+// cannot make the node verify the signatures of blocks with no work. Later
+// blocks in the run may have a slightly different target than the one
+// checked; blocks that are cut are still fully validated by acceptBlocks.
+//
+// The returned map holds the ids of the blocks whose transactions are all
+// standalone valid, along with the height that they were checked at. Blocks
+// that fail are left out, to be rejected by acceptBlocks. The block id
+// commits to every transaction, including its signatures, so the result
+// cannot be reused for a different block.
+func (cs *ConsensusSet) prevalidateBlocks(blocks []types.Block) map[types.BlockID]types.BlockHeight {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	// Transactions are validated at the height of the consensus set when the
+	// block is applied, which is the height of the block's parent. The
+	// blocks are expected to be consecutive; if they are not, the heights
+	// will not match when the blocks are applied and the results are unused.
+	var height types.BlockHeight
+	cs.mu.RLock()
+	err := cs.db.View(func(tx *bolt.Tx) error {
+		parent, err := getBlockMap(tx, blocks[0].ParentID)
+		if err != nil {
+			return err
+		}
+		height = parent.Height
+		blocks = cheapValidRun(tx, cs.dosBlocks, blocks, parent.ChildTarget)
+		return nil
+	})
+	cs.mu.RUnlock()
+	if err != nil || len(blocks) == 0 {
+		// The blocks are orphans or fail the header checks, leave them to
+		// acceptBlocks.
+		return nil
+	}
+
+	// Flatten the transactions so that the work can be split evenly, even if
+	// there is only one block.
+	type txnRef struct {
+		block int
+		txn   *types.Transaction
+	}
+	var txns []txnRef
+	for i := range blocks {
+		for j := range blocks[i].Transactions {
+			txns = append(txns, txnRef{i, &blocks[i].Transactions[j]})
+		}
+	}
+	errs := make([]error, len(txns))
+	var wg sync.WaitGroup
+	wg.Add(runtime.NumCPU())
+	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
+		go func(offset int) {
+			defer wg.Done()
+			for i := offset; i < len(txns); i += runtime.NumCPU() {
+				t := txns[i]
+				errs[i] = t.txn.StandaloneValid(height + types.BlockHeight(t.block))
+			}
+		}(cpu)
+	}
+	wg.Wait()
+
+	valid := make([]bool, len(blocks))
+	for i := range valid {
+		valid[i] = true
+	}
+	for i, err := range errs {
+		if err != nil {
+			valid[txns[i].block] = false
+		}
+	}
+	prevalidated := make(map[types.BlockID]types.BlockHeight)
+	for i := range blocks {
+		if valid[i] {
+			prevalidated[blocks[i].ID()] = height + types.BlockHeight(i)
+		}
+	}
+	return prevalidated
+}
+
+// cheapValidRun returns the longest prefix of a run of blocks that are not DoS
+// blocks, are not already known, are consecutive, and meet the target.
+func cheapValidRun(tx *bolt.Tx, dosBlocks map[types.BlockID]struct{}, blocks []types.Block, target types.Target) []types.Block {
+	blockMap := tx.Bucket(BlockMap)
+	parentID := blocks[0].ParentID
+	for i := range blocks {
+		id := blocks[i].ID()
+		if _, exists := dosBlocks[id]; exists {
+			return blocks[:i]
+		}
+		if blockMap.Get(id[:]) != nil {
+			return blocks[:i]
+		}
+		if blocks[i].ParentID != parentID {
+			return blocks[:i]
+		}
+		if !checkHeaderTarget(blocks[i].Header(), target) {
+			return blocks[:i]
+		}
+		parentID = id
+	}
+	return blocks
+}
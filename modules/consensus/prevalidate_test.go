@@ -0,0 +1,110 @@
+package consensus
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestPrevalidateBlocks checks that prevalidateBlocks reports blocks with
+// standalone valid transactions, and leaves out blocks with bad signatures,
+// orphan blocks, and blocks that fail the cheap header checks.
+func TestPrevalidateBlocks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Create a block containing a signed transaction.
+	_, err = cst.wallet.SendSiacoins(types.SiacoinPrecision, randAddress())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cst.miner.FindBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := -1
+	for i, txn := range b.Transactions {
+		if len(txn.TransactionSignatures) > 0 {
+			signed = i
+		}
+	}
+	if signed == -1 {
+		t.Fatal("block does not contain a signed transaction")
+	}
+
+	prevalidated := cst.cs.prevalidateBlocks([]types.Block{b})
+	height, exists := prevalidated[b.ID()]
+	if !exists {
+		t.Fatal("valid block was not prevalidated")
+	}
+	if height != cst.cs.Height() {
+		t.Errorf("block was prevalidated at height %v, expected %v", height, cst.cs.Height())
+	}
+
+	// Corrupt a signature in a copy of the block.
+	badBlock := b
+	badBlock.Transactions = append([]types.Transaction(nil), b.Transactions...)
+	badTxn := &badBlock.Transactions[signed]
+	badTxn.TransactionSignatures = append([]types.TransactionSignature(nil), badTxn.TransactionSignatures...)
+	badTxn.TransactionSignatures[0].Signature = append([]byte(nil), badTxn.TransactionSignatures[0].Signature...)
+	badTxn.TransactionSignatures[0].Signature[0]++
+	badBlock = solveAtTarget(badBlock, cst.cs.CurrentTarget())
+	if len(cst.cs.prevalidateBlocks([]types.Block{badBlock})) != 0 {
+		t.Error("block with a bad signature was prevalidated")
+	}
+
+	// A block that does not meet its target should not be prevalidated.
+	unsolved := b
+	for checkHeaderTarget(unsolved.Header(), cst.cs.CurrentTarget()) {
+		unsolved.Nonce[0]++
+	}
+	if len(cst.cs.prevalidateBlocks([]types.Block{unsolved})) != 0 {
+		t.Error("unsolved block was prevalidated")
+	}
+
+	// Neither should a known DoS block.
+	cst.cs.mu.Lock()
+	cst.cs.addDoSBlock(badBlock.ID())
+	cst.cs.mu.Unlock()
+	if len(cst.cs.prevalidateBlocks([]types.Block{badBlock})) != 0 {
+		t.Error("DoS block was prevalidated")
+	}
+
+	// Orphans cannot be prevalidated, as their height is unknown.
+	orphan := b
+	orphan.ParentID = types.BlockID{1}
+	if cst.cs.prevalidateBlocks([]types.Block{orphan}) != nil {
+		t.Error("orphan block was prevalidated")
+	}
+
+	// The prevalidated block should still be accepted.
+	err = cst.cs.AcceptBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst.cs.CurrentBlock().ID() != b.ID() {
+		t.Error("block was not accepted")
+	}
+
+	// Now that the block is known, it should not be prevalidated again.
+	if len(cst.cs.prevalidateBlocks([]types.Block{b})) != 0 {
+		t.Error("known block was prevalidated")
+	}
+}
+
+// solveAtTarget changes the nonce of a block until it meets the target.
+func solveAtTarget(b types.Block, target types.Target) types.Block {
+	for !checkHeaderTarget(b.Header(), target) {
+		b.Nonce[0]++
+		if b.Nonce[0] == 0 {
+			b.Nonce[1]++
+		}
+	}
+	return b
+}
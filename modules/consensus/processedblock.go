@@ -24,6 +24,13 @@ var SurpassThreshold = big.NewRat(20, 100)
 // processedBlock is a copy/rename of blockNode, with the pointers to
 // other blockNodes replaced with block ID's, and all the fields
 // exported, so that a block node can be marshalled
+//
+// The diffs record every change that the block made to the consensus set,
+// including the full outputs and contracts that it spent, which is what
+// allows the block to be reverted. They are stored on disk rather than in
+// memory, and are kept even for blocks deeper than MaxReorgDepth, because
+// subscribers starting from modules.ConsensusChangeBeginning are sent the
+// diffs of every block in the current path.
 type processedBlock struct {
 	Block       types.Block
 	Height      types.BlockHeight
@@ -144,7 +151,7 @@ func (cs *ConsensusSet) newChild(tx *bolt.Tx, pb *processedBlock, b types.Block)
 	if pb.Height < types.OakHardforkBlock {
 		cs.setChildTarget(blockMap, child)
 	} else {
-		child.ChildTarget = cs.childTargetOak(prevTotalTime, prevTotalTarget, pb.ChildTarget, pb.Height)
+		child.ChildTarget = CalculateTarget(prevTotalTime, prevTotalTarget, pb.ChildTarget, pb.Height)
 	}
 	err = blockMap.Put(childID[:], encoding.Marshal(*child))
 	if build.DEBUG && err != nil {
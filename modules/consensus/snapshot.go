@@ -0,0 +1,58 @@
+package consensus
+
+import (
+	"github.com/NebulousLabs/Sia/build"
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// UTXOSnapshot returns every siacoin output in the current consensus set,
+// sorted by id, along with the hash of the snapshot's encoding. Delayed
+// siacoin outputs are not included until they mature. Bolt iterates keys in
+// byte order, so two consensus sets at the same block will always produce
+// identical snapshots and hashes.
+func (cs *ConsensusSet) UTXOSnapshot() (entries []modules.UTXOEntry, commitment crypto.Hash) {
+	if err := cs.tg.Add(); err != nil {
+		return nil, crypto.Hash{}
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(SiacoinOutputs).ForEach(func(idBytes, scoBytes []byte) error {
+			var entry modules.UTXOEntry
+			copy(entry.ID[:], idBytes)
+			err := encoding.Unmarshal(scoBytes, &entry.SiacoinOutput)
+			if build.DEBUG && err != nil {
+				panic(err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, crypto.HashObject(entries)
+}
+
+// ConsensusChecksum returns the checksum of the current consensus set, which
+// commits to the current path, the siacoin, siafund, and delayed siacoin
+// outputs, the file contracts, and the siafund pool. Consensus sets at the
+// same block always produce the same checksum, so it can be compared across
+// nodes to check that they agree on the state of the blockchain.
+func (cs *ConsensusSet) ConsensusChecksum() (checksum crypto.Hash) {
+	if err := cs.tg.Add(); err != nil {
+		return crypto.Hash{}
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	_ = cs.db.View(func(tx *bolt.Tx) error {
+		checksum = consensusChecksum(tx)
+		return nil
+	})
+	return checksum
+}
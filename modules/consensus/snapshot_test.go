@@ -0,0 +1,126 @@
+package consensus
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestUTXOSnapshot checks that two consensus sets on the same block produce
+// identical snapshots, and that the snapshots diverge along with the chains.
+func TestUTXOSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := createConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Move cst2 onto cst1's chain.
+	for cst1.cs.Height() <= cst2.cs.Height() {
+		_, err := cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := types.BlockHeight(1); i <= cst1.cs.Height(); i++ {
+		b, _ := cst1.cs.BlockAtHeight(i)
+		_ = cst2.cs.AcceptBlock(b)
+	}
+	if cst1.cs.CurrentBlockID() != cst2.cs.CurrentBlockID() {
+		t.Fatal("consensus sets are not on the same block")
+	}
+
+	entries1, hash1 := cst1.cs.UTXOSnapshot()
+	entries2, hash2 := cst2.cs.UTXOSnapshot()
+	if len(entries1) == 0 {
+		t.Fatal("snapshot is empty")
+	}
+	if !bytes.Equal(encoding.Marshal(entries1), encoding.Marshal(entries2)) {
+		t.Fatal("snapshots differ at the same block")
+	}
+	if hash1 != hash2 {
+		t.Fatal("snapshot hashes differ at the same block")
+	}
+	for i := 1; i < len(entries1); i++ {
+		if bytes.Compare(entries1[i-1].ID[:], entries1[i].ID[:]) >= 0 {
+			t.Fatal("snapshot is not sorted by id")
+		}
+	}
+
+	// Mine a different block on each consensus set. The miner payouts go to
+	// different addresses, so the snapshots should differ once the payouts
+	// mature.
+	for i := types.BlockHeight(0); i <= types.MaturityDelay; i++ {
+		_, err = cst1.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cst2.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cst1.cs.Height() != cst2.cs.Height() {
+		t.Fatal("consensus sets are not at the same height")
+	}
+	_, hash1 = cst1.cs.UTXOSnapshot()
+	_, hash2 = cst2.cs.UTXOSnapshot()
+	if hash1 == hash2 {
+		t.Fatal("snapshot hashes match after the chains diverged")
+	}
+}
+
+// TestConsensusChecksum checks that consensus sets on the same block report
+// the same checksum, and that the checksum changes when a block is added.
+func TestConsensusChecksum(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst1, err := createConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst1.Close()
+	cst2, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst2.Close()
+
+	// Move cst2 onto cst1's chain.
+	for i := types.BlockHeight(1); i <= cst1.cs.Height(); i++ {
+		b, _ := cst1.cs.BlockAtHeight(i)
+		err = cst2.cs.AcceptBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checksum := cst1.cs.ConsensusChecksum()
+	if checksum != cst2.cs.ConsensusChecksum() {
+		t.Fatal("checksums differ at the same block")
+	}
+	if checksum != cst1.cs.dbCurrentProcessedBlock().ConsensusChecksum {
+		t.Error("checksum does not match the checksum recorded for the current block")
+	}
+
+	// Adding a block should change the checksum.
+	_, err = cst1.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cst1.cs.ConsensusChecksum() == checksum {
+		t.Error("checksum did not change after adding a block")
+	}
+}
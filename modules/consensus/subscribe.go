@@ -7,6 +7,43 @@ import (
 	"github.com/NebulousLabs/bolt"
 )
 
+var (
+	// changeNotifierBuffer is the number of consensus changes that can be
+	// queued on a channel returned by SubscribeChan before the receiver is
+	// considered to have fallen behind.
+	changeNotifierBuffer = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  5,
+	}).(int)
+)
+
+// A changeNotifier is a subscriber that forwards consensus changes to a
+// channel. Sending never blocks: if the buffer of the channel is full, the
+// channel is closed and the notifier is removed from the subscribers.
+// ProcessConsensusChange is always called while the consensus set lock is
+// held, which also protects 'closed'. The send is made under the lock rather
+// than from a separate goroutine so that changes are delivered in order, and
+// as it never waits on the receiver it cannot stall the consensus set.
+type changeNotifier struct {
+	c      chan modules.ConsensusChange
+	closed bool
+}
+
+// ProcessConsensusChange sends a consensus change to the notifier's channel,
+// closing the channel if the receiver has fallen behind.
+func (cn *changeNotifier) ProcessConsensusChange(cc modules.ConsensusChange) {
+	if cn.closed {
+		return
+	}
+	select {
+	case cn.c <- cc:
+	default:
+		close(cn.c)
+		cn.closed = true
+	}
+}
+
 // computeConsensusChange computes the consensus change from the change entry
 // at index 'i' in the change log. If i is out of bounds, an error is returned.
 func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (modules.ConsensusChange, error) {
@@ -48,6 +85,12 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 			sfpd.Direction = modules.DiffRevert
 			cc.SiafundPoolDiffs = append(cc.SiafundPoolDiffs, sfpd)
 		}
+		events := contractEvents(revertedBlock)
+		for i := len(events) - 1; i >= 0; i-- {
+			event := events[i]
+			event.Direction = modules.DiffRevert
+			cc.ContractEvents = append(cc.ContractEvents, event)
+		}
 	}
 	for _, appliedBlockID := range ce.AppliedBlocks {
 		appliedBlock, err := getBlockMap(tx, appliedBlockID)
@@ -72,6 +115,7 @@ func (cs *ConsensusSet) computeConsensusChange(tx *bolt.Tx, ce changeEntry) (mod
 		for _, sfpd := range appliedBlock.SiafundPoolDiffs {
 			cc.SiafundPoolDiffs = append(cc.SiafundPoolDiffs, sfpd)
 		}
+		cc.ContractEvents = append(cc.ContractEvents, contractEvents(appliedBlock)...)
 	}
 
 	// Grab the child target and the minimum valid child timestamp.
@@ -113,6 +157,24 @@ func (cs *ConsensusSet) updateSubscribers(ce changeEntry) {
 	for _, subscriber := range cs.subscribers {
 		subscriber.ProcessConsensusChange(cc)
 	}
+	cs.removeClosedNotifiers()
+}
+
+// removeClosedNotifiers removes the channel subscribers whose channels were
+// closed because their receivers fell behind, so that abandoned channels are
+// not kept around until UnsubscribeChan is called.
+func (cs *ConsensusSet) removeClosedNotifiers() {
+	subscribers := cs.subscribers[:0]
+	for _, subscriber := range cs.subscribers {
+		if cn, ok := subscriber.(*changeNotifier); ok && cn.closed {
+			continue
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	for i := len(subscribers); i < len(cs.subscribers); i++ {
+		cs.subscribers[i] = nil
+	}
+	cs.subscribers = subscribers
 }
 
 // managedInitializeSubscribe will take a subscriber and feed them all of the
@@ -238,3 +300,51 @@ func (cs *ConsensusSet) Unsubscribe(subscriber modules.ConsensusSetSubscriber) {
 		}
 	}
 }
+
+// SubscribeChan returns a channel that receives every consensus change that
+// occurs after the call, including reorgs, which are reported as a single
+// change that reverts blocks and then applies blocks. Changes are delivered
+// without blocking the consensus set. If the receiver falls more than
+// changeNotifierBuffer changes behind, the channel is closed and the
+// subscription is removed; the receiver can catch up by calling
+// ConsensusSetSubscribe with the id of the last change that it processed.
+func (cs *ConsensusSet) SubscribeChan() (<-chan modules.ConsensusChange, error) {
+	if err := cs.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cn := &changeNotifier{
+		c: make(chan modules.ConsensusChange, changeNotifierBuffer),
+	}
+	cs.subscribers = append(cs.subscribers, cn)
+	return cn.c, nil
+}
+
+// UnsubscribeChan stops the delivery of consensus changes to a channel
+// returned by SubscribeChan and closes the channel if it is not already
+// closed. If the channel is not found, for example because it was already
+// closed and removed after its receiver fell behind, no action is taken.
+func (cs *ConsensusSet) UnsubscribeChan(c <-chan modules.ConsensusChange) {
+	if cs.tg.Add() != nil {
+		return
+	}
+	defer cs.tg.Done()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for i := range cs.subscribers {
+		cn, ok := cs.subscribers[i].(*changeNotifier)
+		if !ok || (<-chan modules.ConsensusChange)(cn.c) != c {
+			continue
+		}
+		if !cn.closed {
+			close(cn.c)
+			cn.closed = true
+		}
+		cs.subscribers = append(cs.subscribers[0:i], cs.subscribers[i+1:]...)
+		break
+	}
+}
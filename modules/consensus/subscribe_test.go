@@ -3,7 +3,12 @@ package consensus
 import (
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+	"github.com/NebulousLabs/fastrand"
 )
 
 // mockSubscriber receives and holds changes to the consensus set, remembering
@@ -148,3 +153,259 @@ func TestUnsubscribe(t *testing.T) {
 		t.Error("mock subscriber was not correctly unsubscribed")
 	}
 }
+
+// TestSubscribeChanReorg checks that a reorg is delivered on a subscription
+// channel as a change that reverts the old blocks and then applies the new
+// ones.
+func TestSubscribeChanReorg(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name() + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	cstAlt, err := blankConsensusSetTester(t.Name() + "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cstAlt.Close()
+
+	c, err := cst.cs.SubscribeChan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.cs.UnsubscribeChan(c)
+
+	// Extend the main chain by one block.
+	oldBlock, err := cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := <-c
+	if len(cc.RevertedBlocks) != 0 || len(cc.AppliedBlocks) != 1 || cc.AppliedBlocks[0].ID() != oldBlock.ID() {
+		t.Fatal("wrong consensus change for a new block:", cc)
+	}
+
+	// Deliver a longer competing chain.
+	var fork []types.Block
+	for i := 0; i < 2; i++ {
+		b, err := cstAlt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fork = append(fork, b)
+	}
+	err = cst.cs.AcceptBlock(fork[0])
+	if err != modules.ErrNonExtendingBlock {
+		t.Fatalf("expected %v, got %v", modules.ErrNonExtendingBlock, err)
+	}
+	err = cst.cs.AcceptBlock(fork[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc = <-c
+	if len(cc.RevertedBlocks) != 1 || cc.RevertedBlocks[0].ID() != oldBlock.ID() {
+		t.Fatal("reorg did not revert the old block")
+	}
+	if len(cc.AppliedBlocks) != 2 || cc.AppliedBlocks[0].ID() != fork[0].ID() || cc.AppliedBlocks[1].ID() != fork[1].ID() {
+		t.Fatal("reorg did not apply the fork in order")
+	}
+	select {
+	case cc := <-c:
+		t.Fatal("unexpected consensus change:", cc)
+	default:
+	}
+}
+
+// TestSubscribeChanOverflow checks that a subscription channel is closed
+// instead of blocking the consensus set when the receiver falls behind, that
+// the closed channel is unsubscribed, and that UnsubscribeChan closes the
+// channel.
+func TestSubscribeChanOverflow(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := blankConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	slow, err := cst.cs.SubscribeChan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.cs.UnsubscribeChan(slow)
+	unsubscribed, err := cst.cs.SubscribeChan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cst.cs.UnsubscribeChan(unsubscribed)
+	if _, ok := <-unsubscribed; ok {
+		t.Fatal("unsubscribed channel was not closed")
+	}
+
+	// Mine more blocks than the channel can buffer without reading.
+	for i := 0; i < changeNotifierBuffer+1; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < changeNotifierBuffer; i++ {
+		if _, ok := <-slow; !ok {
+			t.Fatal("channel was closed before the buffered changes were read")
+		}
+	}
+	if _, ok := <-slow; ok {
+		t.Fatal("channel was not closed after the receiver fell behind")
+	}
+
+	// The closed channel should no longer be a subscriber.
+	cst.cs.mu.RLock()
+	for _, subscriber := range cst.cs.subscribers {
+		if _, ok := subscriber.(*changeNotifier); ok {
+			t.Error("closed channel is still subscribed")
+		}
+	}
+	cst.cs.mu.RUnlock()
+}
+
+// TestContractEvents checks that consensus changes report the file contracts
+// resolved by valid and missed storage proofs, and that reverting the block
+// reports the inverse events.
+func TestContractEvents(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	for cst.cs.dbBlockHeight() <= 10 {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms := newMockSubscriber()
+	err = cst.cs.ConsensusSetSubscribe(&ms, modules.ConsensusChangeRecent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create one contract that will be proven and one that will miss its
+	// proof. Both windows close at the same height.
+	file := fastrand.Bytes(int(4 * crypto.SegmentSize))
+	payout := types.NewCurrency64(400e6)
+	height := cst.cs.dbBlockHeight()
+	newContract := func(merkleRoot crypto.Hash) types.FileContract {
+		return types.FileContract{
+			FileSize:       uint64(len(file)),
+			FileMerkleRoot: merkleRoot,
+			WindowStart:    height + 1,
+			WindowEnd:      height + 2,
+			Payout:         payout,
+			ValidProofOutputs: []types.SiacoinOutput{{
+				UnlockHash: randAddress(),
+				Value:      types.PostTax(height, payout),
+			}},
+			MissedProofOutputs: []types.SiacoinOutput{{
+				UnlockHash: randAddress(),
+				Value:      types.PostTax(height, payout),
+			}},
+		}
+	}
+	validFC, missedFC := newContract(crypto.MerkleRoot(file)), newContract(crypto.Hash{})
+	txnBuilder := cst.wallet.StartTransaction()
+	err = txnBuilder.FundSiacoins(payout.Mul64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	validIndex := txnBuilder.AddFileContract(validFC)
+	missedIndex := txnBuilder.AddFileContract(missedFC)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validID := txnSet[len(txnSet)-1].FileContractID(validIndex)
+	missedID := txnSet[len(txnSet)-1].FileContractID(missedIndex)
+	if events := ms.updates[len(ms.updates)-1].ContractEvents; len(events) != 0 {
+		t.Fatalf("expected no contract events when contracts are created, got %v", len(events))
+	}
+
+	// Prove the first contract in the block that closes both windows.
+	segmentIndex, err := cst.cs.StorageProofSegment(validID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment, hashSet := crypto.MerkleProof(file, segmentIndex)
+	sp := types.StorageProof{
+		ParentID: validID,
+		HashSet:  hashSet,
+	}
+	copy(sp.Segment[:], segment)
+	txnBuilder = cst.wallet.StartTransaction()
+	txnBuilder.AddStorageProof(sp)
+	txnSet, err = txnBuilder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cst.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkEvent checks that an event matches the expected contract.
+	checkEvent := func(event modules.ContractEvent, dir modules.DiffDirection, typ modules.ContractEventType, id types.FileContractID, payouts []types.SiacoinOutput) {
+		t.Helper()
+		if event.Direction != dir || event.Type != typ || event.ID != id || event.Height != height+2 {
+			t.Fatalf("unexpected event %+v", event)
+		}
+		if len(event.Payouts) != 1 || event.Payouts[0].UnlockHash != payouts[0].UnlockHash || !event.Payouts[0].Value.Equals(payouts[0].Value) {
+			t.Fatalf("event has wrong payouts %v", event.Payouts)
+		}
+	}
+	events := ms.updates[len(ms.updates)-1].ContractEvents
+	if len(events) != 2 {
+		t.Fatalf("expected 2 contract events, got %v", len(events))
+	}
+	checkEvent(events[0], modules.DiffApply, modules.ContractEventValidProof, validID, validFC.ValidProofOutputs)
+	checkEvent(events[1], modules.DiffApply, modules.ContractEventMissedProof, missedID, missedFC.MissedProofOutputs)
+
+	// Reverting the block should report the inverse events in reverse order.
+	var cc modules.ConsensusChange
+	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+		cc, err = cst.cs.computeConsensusChange(tx, changeEntry{
+			RevertedBlocks: []types.BlockID{b.ID()},
+			AppliedBlocks:  []types.BlockID{b.ParentID},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cc.ContractEvents) != 2 {
+		t.Fatalf("expected 2 contract events, got %v", len(cc.ContractEvents))
+	}
+	checkEvent(cc.ContractEvents[0], modules.DiffRevert, modules.ContractEventMissedProof, missedID, missedFC.MissedProofOutputs)
+	checkEvent(cc.ContractEvents[1], modules.DiffRevert, modules.ContractEventValidProof, validID, validFC.ValidProofOutputs)
+}
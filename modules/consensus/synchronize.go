@@ -426,7 +426,7 @@ func (cs *ConsensusSet) threadedRPCRelayHeader(conn modules.PeerConn) error {
 		return cs.validateHeader(boltTxWrapper{tx}, h)
 	})
 	cs.mu.RUnlock()
-	if err == errOrphan {
+	if err == modules.ErrOrphan {
 		// If the header is an orphan, try to find the parents. Call needs to
 		// be made in a separate goroutine as execution requires calling an
 		// exported gateway method - threadedRPCRelayHeader was likely called
@@ -853,7 +853,7 @@ func TestThreadedReceiveBlock(t *testing.T) {
 
 				fnErr <- nil
 			},
-			errWant: errOrphan,
+			errWant: modules.ErrOrphan,
 			msg:     "the function returned from threadedReceiveBlock should not accept an invalid block",
 		},
 		// Test with a valid conn and a valid block.
@@ -984,8 +984,8 @@ func TestIntegrationSendBlkRPC(t *testing.T) {
 		t.Fatal(err)
 	}
 	err = cst1.cs.gateway.RPC(cst2.cs.gateway.Address(), "SendBlk", cst1.cs.managedReceiveBlock(block.ID()))
-	if err != errOrphan {
-		t.Errorf("cst1 should not accept an orphan block: expected error '%v', got '%v'", errOrphan, err)
+	if err != modules.ErrOrphan {
+		t.Errorf("cst1 should not accept an orphan block: expected error '%v', got '%v'", modules.ErrOrphan, err)
 	}
 }
 
@@ -2,7 +2,6 @@ package consensus
 
 import (
 	"errors"
-	"math/big"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -15,16 +14,13 @@ import (
 
 var (
 	errAlteredRevisionPayouts     = errors.New("file contract revision has altered payout volume")
+	errDuplicateSpend             = errors.New("block contains multiple transactions spending the same output")
 	errInvalidStorageProof        = errors.New("provided storage proof is invalid")
 	errLateRevision               = errors.New("file contract revision submitted after deadline")
 	errLowRevisionNumber          = errors.New("transaction has a file contract with an outdated revision number")
-	errMissingSiacoinOutput       = errors.New("transaction spends a nonexisting siacoin output")
-	errMissingSiafundOutput       = errors.New("transaction spends a nonexisting siafund output")
-	errSiacoinInputOutputMismatch = errors.New("siacoin inputs do not equal siacoin outputs for transaction")
-	errSiafundInputOutputMismatch = errors.New("siafund inputs do not equal siafund outputs for transaction")
+	errStorageProofLength         = errors.New("storage proof has the wrong number of hashes for the file size")
 	errUnfinishedFileContract     = errors.New("file contract window has not yet openend")
 	errUnrecognizedFileContractID = errors.New("cannot fetch storage proof segment for unknown file contract")
-	errWrongUnlockConditions      = errors.New("transaction contains incorrect unlock conditions")
 )
 
 // validSiacoins checks that the siacoin inputs and outputs are valid in the
@@ -36,7 +32,7 @@ func validSiacoins(tx *bolt.Tx, t types.Transaction) error {
 		// Check that the input spends an existing output.
 		scoBytes := scoBucket.Get(sci.ParentID[:])
 		if scoBytes == nil {
-			return errMissingSiacoinOutput
+			return modules.ErrMissingSiacoinOutput
 		}
 
 		// Check that the unlock conditions match the required unlock hash.
@@ -46,13 +42,13 @@ func validSiacoins(tx *bolt.Tx, t types.Transaction) error {
 			panic(err)
 		}
 		if sci.UnlockConditions.UnlockHash() != sco.UnlockHash {
-			return errWrongUnlockConditions
+			return modules.ErrWrongUnlockConditions
 		}
 
 		inputSum = inputSum.Add(sco.Value)
 	}
 	if !inputSum.Equals(t.SiacoinOutputSum()) {
-		return errSiacoinInputOutputMismatch
+		return modules.ErrSiacoinInputOutputMismatch
 	}
 	return nil
 }
@@ -82,20 +78,7 @@ func storageProofSegment(tx *bolt.Tx, fcid types.FileContractID) (uint64, error)
 	}
 	var triggerID types.BlockID
 	copy(triggerID[:], blockPath.Get(encoding.EncUint64(uint64(triggerHeight))))
-
-	// Get the index by appending the file contract ID to the trigger block and
-	// taking the hash, then converting the hash to a numerical value and
-	// modding it against the number of segments in the file. The result is a
-	// random number in range [0, numSegments]. The probability is very
-	// slightly weighted towards the beginning of the file, but because the
-	// size difference between the number of segments and the random number
-	// being modded, the difference is too small to make any practical
-	// difference.
-	seed := crypto.HashAll(triggerID, fcid)
-	numSegments := int64(crypto.CalculateLeaves(fc.FileSize))
-	seedInt := new(big.Int).SetBytes(seed[:])
-	index := seedInt.Mod(seedInt, big.NewInt(numSegments)).Uint64()
-	return index, nil
+	return modules.ChallengeSegment(triggerID, fcid, fc.FileSize), nil
 }
 
 // validStorageProofsPre100e3 runs the code that was running before height
@@ -120,6 +103,9 @@ func validStorageProofs100e3(tx *bolt.Tx, t types.Transaction) error {
 			return err
 		}
 		leaves := crypto.CalculateLeaves(fc.FileSize)
+		if len(sp.HashSet) != crypto.MerkleProofLength(leaves, segmentIndex) {
+			return errStorageProofLength
+		}
 		segmentLen := uint64(crypto.SegmentSize)
 		if segmentIndex == leaves-1 {
 			segmentLen = fc.FileSize % crypto.SegmentSize
@@ -179,6 +165,9 @@ func validStorageProofs(tx *bolt.Tx, t types.Transaction) error {
 			return err
 		}
 		leaves := crypto.CalculateLeaves(fc.FileSize)
+		if fc.FileSize > 0 && len(sp.HashSet) != crypto.MerkleProofLength(leaves, segmentIndex) {
+			return errStorageProofLength
+		}
 		segmentLen := uint64(crypto.SegmentSize)
 
 		// If this segment chosen is the final segment, it should only be as
@@ -229,7 +218,7 @@ func validFileContractRevisions(tx *bolt.Tx, t types.Transaction) error {
 
 		// Check that the unlock conditions match the unlock hash.
 		if fcr.UnlockConditions.UnlockHash() != fc.UnlockHash {
-			return errWrongUnlockConditions
+			return modules.ErrWrongUnlockConditions
 		}
 
 		// Check that the payout of the revision matches the payout of the
@@ -262,13 +251,15 @@ func validSiafunds(tx *bolt.Tx, t types.Transaction) (err error) {
 	var siafundOutputSum types.Currency
 	for _, sfi := range t.SiafundInputs {
 		sfo, err := getSiafundOutput(tx, sfi.ParentID)
-		if err != nil {
+		if err == errNilItem {
+			return modules.ErrMissingSiafundOutput
+		} else if err != nil {
 			return err
 		}
 
 		// Check the unlock conditions match the unlock hash.
 		if sfi.UnlockConditions.UnlockHash() != sfo.UnlockHash {
-			return errWrongUnlockConditions
+			return modules.ErrWrongUnlockConditions
 		}
 
 		siafundInputSum = siafundInputSum.Add(sfo.Value)
@@ -277,7 +268,7 @@ func validSiafunds(tx *bolt.Tx, t types.Transaction) (err error) {
 		siafundOutputSum = siafundOutputSum.Add(sfo.Value)
 	}
 	if !siafundOutputSum.Equals(siafundInputSum) {
-		return errSiafundInputOutputMismatch
+		return modules.ErrSiafundInputOutputMismatch
 	}
 	return
 }
@@ -291,10 +282,14 @@ func validTransaction(tx *bolt.Tx, t types.Transaction) error {
 	if err != nil {
 		return err
 	}
+	return validTransactionState(tx, t)
+}
 
-	// Check that each portion of the transaction is legal given the current
-	// consensus set.
-	err = validSiacoins(tx, t)
+// validTransactionState checks that each portion of the transaction is legal
+// given the current consensus set. The standalone validity of the transaction
+// is not checked.
+func validTransactionState(tx *bolt.Tx, t types.Transaction) error {
+	err := validSiacoins(tx, t)
 	if err != nil {
 		return err
 	}
@@ -313,6 +308,24 @@ func validTransaction(tx *bolt.Tx, t types.Transaction) error {
 	return nil
 }
 
+// checkDuplicateSpends checks that no siacoin output, siafund output, or file
+// contract is consumed by more than one transaction in a set of transactions.
+// File contract revisions are not counted, as a contract may legally be
+// revised several times within a block. The check needs no consensus state,
+// so it can reject a block before any of its transactions are applied.
+func checkDuplicateSpends(txns []types.Transaction) error {
+	spent := make(map[types.OutputID]struct{})
+	for _, t := range txns {
+		for _, oid := range t.SpentOutputIDs() {
+			if _, exists := spent[oid]; exists {
+				return errDuplicateSpend
+			}
+			spent[oid] = struct{}{}
+		}
+	}
+	return nil
+}
+
 // tryTransactionSet applies the input transactions to the consensus set to
 // determine if they are valid. An error is returned IFF they are not a valid
 // set in the current consensus set. The size of the transactions and the set
@@ -371,6 +384,26 @@ func (cs *ConsensusSet) TryTransactionSet(txns []types.Transaction) (modules.Con
 	return cs.tryTransactionSet(txns)
 }
 
+// ValidateTransaction checks that a transaction is valid in the current
+// consensus set without applying it. Unlike TryTransactionSet, the
+// transaction may not depend on other unconfirmed transactions. Failures are
+// reported with the exported errors of the modules and types packages, such
+// as modules.ErrMissingSiacoinOutput, modules.ErrWrongUnlockConditions,
+// types.ErrDoubleSpend and types.ErrPrematureSignature, or with
+// crypto.ErrInvalidSignature for a bad signature.
+func (cs *ConsensusSet) ValidateTransaction(t types.Transaction) error {
+	err := cs.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cs.tg.Done()
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.db.View(func(tx *bolt.Tx) error {
+		return validTransaction(tx, t)
+	})
+}
+
 // LockedTryTransactionSet calls fn while under read-lock, passing it a
 // version of TryTransactionSet that can be called under read-lock. This fixes
 // an edge case in the transaction pool.
@@ -1,10 +1,12 @@
 package consensus
 
 import (
+	"math"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
 
@@ -79,6 +81,104 @@ func TestTryInvalidTransactionSet(t *testing.T) {
 	}
 }
 
+// TestValidateTransaction checks that ValidateTransaction reports each kind of
+// invalid transaction with the matching exported error.
+func TestValidateTransaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Create anyone-can-spend siacoin and siafund outputs.
+	value := types.SiacoinPrecision
+	anyone := types.UnlockConditions{}
+	scTxns, err := cst.wallet.SendSiacoins(value, anyone.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfTxns, err := cst.wallet.SendSiafunds(types.NewCurrency64(10), anyone.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cst.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scoid types.SiacoinOutputID
+	for _, txn := range scTxns {
+		for i, sco := range txn.SiacoinOutputs {
+			if sco.UnlockHash == anyone.UnlockHash() {
+				scoid = txn.SiacoinOutputID(uint64(i))
+			}
+		}
+	}
+	var sfoid types.SiafundOutputID
+	for _, txn := range sfTxns {
+		for i, sfo := range txn.SiafundOutputs {
+			if sfo.UnlockHash == anyone.UnlockHash() {
+				sfoid = txn.SiafundOutputID(uint64(i))
+			}
+		}
+	}
+	spend := func(id types.SiacoinOutputID, uc types.UnlockConditions, v types.Currency) types.Transaction {
+		return types.Transaction{
+			SiacoinInputs:  []types.SiacoinInput{{ParentID: id, UnlockConditions: uc}},
+			SiacoinOutputs: []types.SiacoinOutput{{Value: v, UnlockHash: randAddress()}},
+		}
+	}
+	doubleSpend := spend(scoid, anyone, value.Mul64(2))
+	doubleSpend.SiacoinInputs = append(doubleSpend.SiacoinInputs, doubleSpend.SiacoinInputs[0])
+
+	spendSiafunds := func(id types.SiafundOutputID, v types.Currency) types.Transaction {
+		return types.Transaction{
+			SiafundInputs:  []types.SiafundInput{{ParentID: id, UnlockConditions: anyone}},
+			SiafundOutputs: []types.SiafundOutput{{Value: v, UnlockHash: randAddress()}},
+		}
+	}
+
+	// Create a signed transaction and corrupt its signature.
+	builder := cst.wallet.StartTransaction()
+	err = builder.FundSiacoins(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.AddMinerFee(value)
+	signed, err := builder.Sign(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badSig := signed[len(signed)-1]
+	badSig.TransactionSignatures[0].Signature = append([]byte(nil), badSig.TransactionSignatures[0].Signature...)
+	badSig.TransactionSignatures[0].Signature[0]++
+
+	tests := []struct {
+		name string
+		txn  types.Transaction
+		err  error
+	}{
+		{"valid", spend(scoid, anyone, value), nil},
+		{"nonexistent output", spend(types.SiacoinOutputID{1}, anyone, value), modules.ErrMissingSiacoinOutput},
+		{"wrong unlock conditions", spend(scoid, types.UnlockConditions{Timelock: 1}, value), modules.ErrWrongUnlockConditions},
+		{"timelock not expired", spend(scoid, types.UnlockConditions{Timelock: 1e6}, value), types.ErrTimelockNotSatisfied},
+		{"double spend", doubleSpend, types.ErrDoubleSpend},
+		{"unbalanced", spend(scoid, anyone, value.Mul64(2)), modules.ErrSiacoinInputOutputMismatch},
+		{"valid siafunds", spendSiafunds(sfoid, types.NewCurrency64(10)), nil},
+		{"nonexistent siafund output", spendSiafunds(types.SiafundOutputID{1}, types.NewCurrency64(10)), modules.ErrMissingSiafundOutput},
+		{"unbalanced siafunds", spendSiafunds(sfoid, types.NewCurrency64(1)), modules.ErrSiafundInputOutputMismatch},
+		{"bad signature", badSig, crypto.ErrInvalidSignature},
+	}
+	for _, test := range tests {
+		if err := cst.cs.ValidateTransaction(test.txn); err != test.err {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
 // TestStorageProofBoundaries creates file contracts and submits storage proofs
 // for them, probing segment boundaries (first segment, last segment,
 // incomplete segment, etc.).
@@ -338,7 +438,7 @@ func TestValidSiacoins(t *testing.T) {
 	}
 	err = cst.cs.db.View(func(tx *bolt.Tx) error {
 		err := validSiacoins(tx, txn)
-		if err != errMissingSiacoinOutput {
+		if err != modules.ErrMissingSiacoinOutput {
 			t.Fatal(err)
 		}
 		return nil
@@ -359,7 +459,7 @@ func TestValidSiacoins(t *testing.T) {
 	}
 	err = cst.cs.db.View(func(tx *bolt.Tx) error {
 		err := validSiacoins(tx, txn)
-		if err != errWrongUnlockConditions {
+		if err != modules.ErrWrongUnlockConditions {
 			t.Fatal(err)
 		}
 		return nil
@@ -376,7 +476,7 @@ func TestValidSiacoins(t *testing.T) {
 	}
 	err = cst.cs.db.View(func(tx *bolt.Tx) error {
 		err := validSiacoins(tx, txn)
-		if err != errSiacoinInputOutputMismatch {
+		if err != modules.ErrSiacoinInputOutputMismatch {
 			t.Fatal(err)
 		}
 		return nil
@@ -386,6 +486,59 @@ func TestValidSiacoins(t *testing.T) {
 	}
 }
 
+// TestValidSiacoinsOverflow checks that a transaction whose outputs would
+// sum to its input value under 64-bit wraparound is rejected.
+func TestValidSiacoinsOverflow(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// Add an output that can be spent with empty unlock conditions.
+	scoid := types.SiacoinOutputID{1}
+	value := types.NewCurrency64(100)
+	err = cst.cs.db.Update(func(tx *bolt.Tx) error {
+		addSiacoinOutput(tx, scoid, types.SiacoinOutput{
+			Value:      value,
+			UnlockHash: types.UnlockConditions{}.UnlockHash(),
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// With uint64 arithmetic, MaxUint64 + 101 would wrap around to 100.
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: scoid}},
+		SiacoinOutputs: []types.SiacoinOutput{
+			{Value: types.NewCurrency64(math.MaxUint64)},
+			{Value: value.Add(types.NewCurrency64(1))},
+		},
+	}
+	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+		return validSiacoins(tx, txn)
+	})
+	if err != modules.ErrSiacoinInputOutputMismatch {
+		t.Fatalf("expected %v, got %v", modules.ErrSiacoinInputOutputMismatch, err)
+	}
+
+	// The same must hold when the wraparound is split across a miner fee.
+	txn.SiacoinOutputs = txn.SiacoinOutputs[1:]
+	txn.MinerFees = []types.Currency{types.NewCurrency64(math.MaxUint64)}
+	err = cst.cs.db.View(func(tx *bolt.Tx) error {
+		return validSiacoins(tx, txn)
+	})
+	if err != modules.ErrSiacoinInputOutputMismatch {
+		t.Fatalf("expected %v, got %v", modules.ErrSiacoinInputOutputMismatch, err)
+	}
+}
+
 // TestStorageProofSegment probes the storageProofSegment method of the
 // consensus set.
 func TestStorageProofSegment(t *testing.T) {
@@ -416,6 +569,78 @@ func TestStorageProofSegment(t *testing.T) {
 	}
 }
 
+// TestChallengeSegment checks that modules.ChallengeSegment is deterministic,
+// stays in range, is roughly uniform over the segments of a file, and agrees
+// with storageProofSegment.
+func TestChallengeSegment(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// The same inputs should always produce the same index.
+	var triggerID types.BlockID
+	var fcid types.FileContractID
+	fastrand.Read(triggerID[:])
+	fastrand.Read(fcid[:])
+	fileSize := uint64(100 * crypto.SegmentSize)
+	index := modules.ChallengeSegment(triggerID, fcid, fileSize)
+	for i := 0; i < 10; i++ {
+		if modules.ChallengeSegment(triggerID, fcid, fileSize) != index {
+			t.Fatal("ChallengeSegment is not deterministic")
+		}
+	}
+
+	// Files with a single segment, including empty files, always challenge
+	// the first segment.
+	if modules.ChallengeSegment(triggerID, fcid, 0) != 0 || modules.ChallengeSegment(triggerID, fcid, crypto.SegmentSize) != 0 {
+		t.Error("single segment file challenged a segment other than the first")
+	}
+
+	// Count the segments challenged across many trigger blocks.
+	const numSegments = 8
+	const trials = 8000
+	counts := make([]int, numSegments)
+	for i := 0; i < trials; i++ {
+		fastrand.Read(triggerID[:])
+		index := modules.ChallengeSegment(triggerID, fcid, numSegments*crypto.SegmentSize)
+		if index >= numSegments {
+			t.Fatal("challenged segment is out of range:", index)
+		}
+		counts[index]++
+	}
+	for i, count := range counts {
+		if count < trials/numSegments*3/4 || count > trials/numSegments*5/4 {
+			t.Errorf("segment %v was challenged %v times out of %v", i, count, trials)
+		}
+	}
+
+	// storageProofSegment should use the block preceding the proof window as
+	// the trigger block.
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+	fc := types.FileContract{
+		FileSize:    fileSize,
+		Payout:      types.NewCurrency64(1),
+		WindowStart: cst.cs.Height(),
+	}
+	cst.cs.dbAddFileContract(fcid, fc)
+	segment, err := cst.cs.dbStorageProofSegment(fcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trigger, exists := cst.cs.BlockAtHeight(fc.WindowStart - 1)
+	if !exists {
+		t.Fatal("trigger block does not exist")
+	}
+	if segment != modules.ChallengeSegment(trigger.ID(), fcid, fileSize) {
+		t.Error("storageProofSegment does not match ChallengeSegment")
+	}
+}
+
 // TestValidStorageProofs probes the validStorageProofs method of the consensus
 // set.
 func TestValidStorageProofs(t *testing.T) {
@@ -530,6 +755,140 @@ func TestValidStorageProofs(t *testing.T) {
 	}
 }
 
+// TestValidStorageProofsTampered checks that validStorageProofs accepts a
+// correct storage proof and rejects proofs that have been tampered with.
+func TestValidStorageProofsTampered(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cst, err := createConsensusSetTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cst.Close()
+
+	// COMPATv0.4.0
+	//
+	// Mine 10 blocks so that the post-hardfork rules are in effect.
+	for i := 0; i < 10; i++ {
+		_, err = cst.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create a file contract with a multi-segment file.
+	var fcid types.FileContractID
+	fcid[0] = 13
+	simFile := fastrand.Bytes(64 * 1024)
+	fc := types.FileContract{
+		FileSize:       uint64(len(simFile)),
+		FileMerkleRoot: crypto.MerkleRoot(simFile),
+		Payout:         types.NewCurrency64(1),
+		WindowStart:    2,
+		WindowEnd:      1200,
+	}
+	cst.cs.dbAddFileContract(fcid, fc)
+	proofIndex, err := cst.cs.dbStorageProofSegment(fcid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numSegments := crypto.CalculateLeaves(fc.FileSize)
+	wrongIndex := (proofIndex + 1) % numSegments
+
+	// Create a second contract covering a different file, so that a valid
+	// proof for simFile is checked against the wrong root.
+	var wrongRootID types.FileContractID
+	wrongRootID[0] = 14
+	wrongRootFC := fc
+	wrongRootFC.FileMerkleRoot = crypto.MerkleRoot(fastrand.Bytes(len(simFile)))
+	cst.cs.dbAddFileContract(wrongRootID, wrongRootFC)
+	wrongRootIndex, err := cst.cs.dbStorageProofSegment(wrongRootID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// proof builds a storage proof for the given contract, using the given
+	// segment of simFile.
+	proof := func(parentID types.FileContractID, index uint64) types.StorageProof {
+		base, hashSet := crypto.MerkleProof(simFile, index)
+		sp := types.StorageProof{
+			ParentID: parentID,
+			HashSet:  hashSet,
+		}
+		copy(sp.Segment[:], base)
+		return sp
+	}
+
+	tests := []struct {
+		name   string
+		tamper func() types.StorageProof
+		err    error
+	}{
+		{
+			name:   "valid",
+			tamper: func() types.StorageProof { return proof(fcid, proofIndex) },
+			err:    nil,
+		},
+		{
+			name:   "wrong segment",
+			tamper: func() types.StorageProof { return proof(fcid, wrongIndex) },
+			err:    errInvalidStorageProof,
+		},
+		{
+			name: "wrong segment data",
+			tamper: func() types.StorageProof {
+				sp := proof(fcid, proofIndex)
+				sp.Segment[0]++
+				return sp
+			},
+			err: errInvalidStorageProof,
+		},
+		{
+			name: "truncated path",
+			tamper: func() types.StorageProof {
+				sp := proof(fcid, proofIndex)
+				sp.HashSet = sp.HashSet[:len(sp.HashSet)-1]
+				return sp
+			},
+			err: errStorageProofLength,
+		},
+		{
+			name: "extended path",
+			tamper: func() types.StorageProof {
+				sp := proof(fcid, proofIndex)
+				sp.HashSet = append(sp.HashSet, crypto.Hash{})
+				return sp
+			},
+			err: errStorageProofLength,
+		},
+		{
+			name: "empty path",
+			tamper: func() types.StorageProof {
+				sp := proof(fcid, proofIndex)
+				sp.HashSet = nil
+				return sp
+			},
+			err: errStorageProofLength,
+		},
+		{
+			name:   "wrong root",
+			tamper: func() types.StorageProof { return proof(wrongRootID, wrongRootIndex) },
+			err:    errInvalidStorageProof,
+		},
+	}
+	for _, test := range tests {
+		txn := types.Transaction{
+			StorageProofs: []types.StorageProof{test.tamper()},
+		}
+		err := cst.cs.dbValidStorageProofs(txn)
+		if err != test.err {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
 // HARDFORK 21,000
 //
 // TestPreForkValidStorageProofs checks that storage proofs which are invalid
@@ -693,7 +1052,7 @@ func TestValidFileContractRevisions(t *testing.T) {
 	cst.cs.dbAddFileContract(fcid, fc)
 	txn.FileContractRevisions[0].UnlockConditions.Timelock++
 	err = cst.cs.dbValidFileContractRevisions(txn)
-	if err != errWrongUnlockConditions {
+	if err != modules.ErrWrongUnlockConditions {
 		t.Error(err)
 	}
 	txn.FileContractRevisions[0].UnlockConditions.Timelock--
@@ -1,6 +1,7 @@
 package modules
 
 import (
+	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/types"
 )
 
@@ -45,6 +46,18 @@ type (
 		TotalRevisionVolume types.Currency `json:"totalrevisionvolume"`
 	}
 
+	// TransactionProof proves that a transaction is included in a block. It
+	// can be checked against the block's header with
+	// types.VerifyTransactionProof, without access to the rest of the block.
+	TransactionProof struct {
+		Header          types.BlockHeader `json:"header"`
+		Height          types.BlockHeight `json:"height"`
+		Index           int               `json:"index"`
+		NumMinerPayouts int               `json:"numminerpayouts"`
+		NumTransactions int               `json:"numtransactions"`
+		Proof           []crypto.Hash     `json:"proof"`
+	}
+
 	// Explorer tracks the blockchain and provides tools for gathering
 	// statistics and finding objects or patterns within the blockchain.
 	Explorer interface {
@@ -67,6 +80,11 @@ type (
 		// consensus set.
 		Transaction(types.TransactionID) (types.Block, types.BlockHeight, bool)
 
+		// TransactionProof returns a proof that the transaction with the
+		// input id is included in the blockchain. The bool indicates whether
+		// the transaction was found.
+		TransactionProof(types.TransactionID) (TransactionProof, bool)
+
 		// UnlockHash returns all of the transaction ids associated with the
 		// provided unlock hash.
 		UnlockHash(types.UnlockHash) []types.TransactionID
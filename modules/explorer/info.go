@@ -69,6 +69,36 @@ func (e *Explorer) Transaction(id types.TransactionID) (types.Block, types.Block
 	return block, height, true
 }
 
+// TransactionProof takes a transaction ID and returns a proof that the
+// transaction is included in the block that contains it, along with the header
+// needed to check the proof. Block IDs are indexed alongside transaction IDs
+// for the miner payouts, but the payouts are not transactions, so no proof is
+// returned for a block ID.
+func (e *Explorer) TransactionProof(id types.TransactionID) (modules.TransactionProof, bool) {
+	block, height, exists := e.Transaction(id)
+	if !exists {
+		return modules.TransactionProof{}, false
+	}
+	for i, txn := range block.Transactions {
+		if txn.ID() != id {
+			continue
+		}
+		proof, err := block.TransactionProof(i)
+		if err != nil {
+			return modules.TransactionProof{}, false
+		}
+		return modules.TransactionProof{
+			Header:          block.Header(),
+			Height:          height,
+			Index:           i,
+			NumMinerPayouts: len(block.MinerPayouts),
+			NumTransactions: len(block.Transactions),
+			Proof:           proof,
+		}, true
+	}
+	return modules.TransactionProof{}, false
+}
+
 // UnlockHash returns the IDs of all the transactions that contain the unlock
 // hash. An empty set indicates that the unlock hash does not appear in the
 // blockchain.
@@ -50,6 +50,50 @@ func TestBlock(t *testing.T) {
 	}
 }
 
+// TestTransactionProof checks that the explorer returns valid inclusion proofs
+// for transactions in the blockchain.
+func TestTransactionProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put a transaction in a block.
+	txns, err := et.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := et.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn := txns[len(txns)-1]
+	tp, exists := et.explorer.TransactionProof(txn.ID())
+	if !exists {
+		t.Fatal("no proof returned for a transaction in the blockchain")
+	}
+	if tp.Header.ID() != b.ID() || tp.Height != et.cs.Height() {
+		t.Error("proof is for the wrong block")
+	}
+	if !types.VerifyTransactionProof(tp.Header.MerkleRoot, txn, tp.Index, tp.NumMinerPayouts, tp.NumTransactions, tp.Proof) {
+		t.Error("proof does not verify")
+	}
+	if len(txns) > 1 && types.VerifyTransactionProof(tp.Header.MerkleRoot, txns[0], tp.Index, tp.NumMinerPayouts, tp.NumTransactions, tp.Proof) {
+		t.Error("proof verifies for a different transaction")
+	}
+
+	// Block ids and unknown ids have no proofs.
+	if _, exists := et.explorer.TransactionProof(types.TransactionID(b.ID())); exists {
+		t.Error("proof returned for a block id")
+	}
+	if _, exists := et.explorer.TransactionProof(types.TransactionID{}); exists {
+		t.Error("proof returned for an unknown id")
+	}
+}
+
 // TestBlockFacts checks that the correct block facts are returned for a query.
 func TestBlockFacts(t *testing.T) {
 	if testing.Short() {
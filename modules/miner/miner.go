@@ -56,6 +56,10 @@ type splitSet struct {
 	averageFee   types.Currency
 	size         uint64
 	transactions []types.Transaction
+
+	// local is set if the split set contains a transaction submitted by this
+	// node's wallet. Local sets rank above all other sets regardless of fee.
+	local bool
 }
 
 type splitSetID int
@@ -80,8 +80,12 @@ func (mh mapHeap) len() int {
 
 // less returns true if the mapElement at index i is less than the element at
 // index j if the mapHeap is a min-heap. If the mapHeap is a max-heap, it
-// returns true if the element at index i is greater.
+// returns true if the element at index i is greater. Local sets are greater
+// than all other sets.
 func (mh mapHeap) less(i, j int) bool {
+	if mh.data[i].set.local != mh.data[j].set.local {
+		return mh.data[i].set.local != mh.minHeap
+	}
 	if mh.minHeap {
 		return mh.data[i].set.averageFee.Cmp(mh.data[j].set.averageFee) == -1
 	}
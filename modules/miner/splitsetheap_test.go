@@ -355,3 +355,51 @@ func TestMapHeapPeek(t *testing.T) {
 		}
 	}
 }
+
+// TestMapHeapLocal checks that local sets rank above all other sets in both
+// the max-heap and the min-heap, regardless of fee.
+func TestMapHeapLocal(t *testing.T) {
+	max := &mapHeap{
+		selectID: make(map[splitSetID]*mapElement),
+		minHeap:  false,
+	}
+	min := &mapHeap{
+		selectID: make(map[splitSetID]*mapElement),
+		minHeap:  true,
+	}
+	max.init()
+	min.init()
+
+	// Even ids are local sets paying low fees, odd ids are other sets paying
+	// high fees.
+	for _, i := range fastrand.Perm(100) {
+		fee := uint64(100 + i)
+		if i%2 == 0 {
+			fee = uint64(i)
+		}
+		for _, mh := range []*mapHeap{max, min} {
+			mh.push(&mapElement{
+				set: &splitSet{
+					averageFee:   types.SiacoinPrecision.Mul64(fee),
+					size:         10,
+					transactions: make([]types.Transaction, 0),
+					local:        i%2 == 0,
+				},
+				id: splitSetID(i),
+			})
+		}
+	}
+
+	// The max-heap should return the local sets first, and the min-heap
+	// should return them last.
+	for i := 0; i < 100; i++ {
+		maxPop := max.pop()
+		minPop := min.pop()
+		if maxPop.set.local != (i < 50) {
+			t.Fatal("max-heap did not rank local sets first")
+		}
+		if minPop.set.local != (i >= 50) {
+			t.Fatal("min-heap did not rank local sets last")
+		}
+	}
+}
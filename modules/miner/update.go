@@ -31,6 +31,7 @@ func (m *Miner) getNewSplitSets(diff *modules.TransactionPoolDiff) []*mapElement
 			size:         size,
 			averageFee:   totalFees.Div64(size),
 			transactions: newSet.Transactions,
+			local:        newSet.Local,
 		}
 
 		elem := &mapElement{
@@ -102,10 +103,16 @@ func (m *Miner) addMapElementTxns(elem *mapElement) {
 		sizeOfBottomSets += nextSet.set.size
 		averageFeeOfBottomSets := totalBottomFees.Div64(sizeOfBottomSets)
 
+		// Local sets displace other sets regardless of fee, and are never
+		// displaced by them.
+		if candidateSet.local && !nextSet.set.local {
+			continue
+		}
+
 		// If the average fee of the bottom sets from the block is higher than
-		// the fee from this candidate set, put the candidate into the overflow
-		// MapHeap.
-		if averageFeeOfBottomSets.Cmp(candidateSet.averageFee) == 1 {
+		// the fee from this candidate set, or the candidate would displace a
+		// local set, put the candidate into the overflow MapHeap.
+		if averageFeeOfBottomSets.Cmp(candidateSet.averageFee) == 1 || (nextSet.set.local && !candidateSet.local) {
 			// CandidateSet goes into the overflow.
 			m.overflowMapHeap.push(elem)
 			// Put transaction sets from bottom back into the blockMapHeap.
@@ -160,6 +167,11 @@ func (m *Miner) adjustUnsolvedBlock() {
 		set := elem.set
 		m.persist.UnsolvedBlock.Transactions = append(m.persist.UnsolvedBlock.Transactions, set.transactions...)
 	}
+
+	// The sets were gathered from a map, so put the transactions in their
+	// canonical order. Other nodes holding the same transactions can then
+	// reconstruct the block without being told the order.
+	copy(m.persist.UnsolvedBlock.Transactions, modules.CanonicalTransactionOrder(m.persist.UnsolvedBlock.Transactions))
 }
 
 // deleteReverts deletes transactions from the miner's transaction selection
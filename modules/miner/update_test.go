@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
 )
 
 // TestIntegrationBlockHeightReorg checks that the miner has the correct block
@@ -96,3 +97,49 @@ func TestIntegrationBlockHeightReorg(t *testing.T) {
 		t.Fatal("mt1 and mt3 should have the same current block")
 	}
 }
+
+// TestAddMapElementTxnsLocal checks that a local set is placed in the block
+// even when the block is full of sets paying higher fees, and that other sets
+// cannot displace it.
+func TestAddMapElementTxnsLocal(t *testing.T) {
+	m := &Miner{
+		blockMapHeap: &mapHeap{
+			selectID: make(map[splitSetID]*mapElement),
+			minHeap:  true,
+		},
+		overflowMapHeap: &mapHeap{
+			selectID: make(map[splitSetID]*mapElement),
+			minHeap:  false,
+		},
+	}
+	newElem := func(id int, fee uint64, local bool) *mapElement {
+		return &mapElement{
+			set: &splitSet{
+				averageFee: types.NewCurrency64(fee),
+				size:       types.BlockSizeLimit / 4,
+				local:      local,
+			},
+			id: splitSetID(id),
+		}
+	}
+
+	// Fill the block with expensive sets, then add a cheap local set.
+	for i := 0; i < 3; i++ {
+		m.addMapElementTxns(newElem(i, 100, false))
+	}
+	m.addMapElementTxns(newElem(3, 1, true))
+	if _, exists := m.blockMapHeap.selectID[3]; !exists {
+		t.Fatal("local set was not placed in the block")
+	}
+	if len(m.overflowMapHeap.selectID) != 1 {
+		t.Fatal("expected one set to be moved to the overflow")
+	}
+
+	// A more expensive set should only displace the other sets.
+	for i := 4; i < 8; i++ {
+		m.addMapElementTxns(newElem(i, 1000, false))
+	}
+	if _, exists := m.blockMapHeap.selectID[3]; !exists {
+		t.Fatal("local set was displaced from the block")
+	}
+}
@@ -0,0 +1,78 @@
+package modules
+
+import (
+	"io"
+	"math/big"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// ChallengeSegment returns the index of the segment that must be proven for a
+// file contract, given the id of the block preceding the contract's proof
+// window. The trigger block is not known until the window opens, so the
+// segment cannot be predicted in advance.
+//
+// The index is found by appending the file contract ID to the trigger block
+// and taking the hash, then converting the hash to a numerical value and
+// modding it against the number of segments in the file. The result is a
+// random number in range [0, numSegments). The probability is very slightly
+// weighted towards the beginning of the file, but because the size difference
+// between the number of segments and the random number being modded, the
+// difference is too small to make any practical difference.
+func ChallengeSegment(triggerID types.BlockID, fcid types.FileContractID, fileSize uint64) uint64 {
+	seed := crypto.HashAll(triggerID, fcid)
+	numSegments := int64(crypto.CalculateLeaves(fileSize))
+	seedInt := new(big.Int).SetBytes(seed[:])
+	return seedInt.Mod(seedInt, big.NewInt(numSegments)).Uint64()
+}
+
+// BuildStorageProof builds the storage proof for a file contract covering
+// 'fileSize' bytes of 'file', given the id of the block preceding the
+// contract's proof window. The file is read one segment at a time, so only
+// the Merkle branch of the challenged segment is held in memory. The returned
+// proof can be placed in a transaction as is.
+func BuildStorageProof(file io.ReaderAt, fileSize uint64, fcid types.FileContractID, triggerID types.BlockID) (types.StorageProof, error) {
+	sp := types.StorageProof{
+		ParentID: fcid,
+	}
+	index := ChallengeSegment(triggerID, fcid, fileSize)
+	t := crypto.NewTree()
+	err := t.SetIndex(index)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+
+	// Push each segment of the file into the tree. The tree keeps a
+	// reference to the challenged segment, so it is pushed from the proof
+	// itself rather than from the shared buffer.
+	r := io.NewSectionReader(file, 0, int64(fileSize))
+	buf := make([]byte, crypto.SegmentSize)
+	for i, remaining := uint64(0), fileSize; remaining > 0; i++ {
+		n := uint64(crypto.SegmentSize)
+		if remaining < n {
+			n = remaining
+		}
+		_, err := io.ReadFull(r, buf[:n])
+		if err != nil {
+			return types.StorageProof{}, err
+		}
+		if i == index {
+			copy(sp.Segment[:], buf[:n])
+			t.Push(sp.Segment[:n])
+		} else {
+			t.Push(buf[:n])
+		}
+		remaining -= n
+	}
+
+	// An empty file has no segments, and therefore no hash set.
+	_, proof, _, _ := t.Prove()
+	if len(proof) > 1 {
+		sp.HashSet = make([]crypto.Hash, len(proof)-1)
+		for i, p := range proof[1:] {
+			copy(sp.HashSet[i][:], p)
+		}
+	}
+	return sp, nil
+}
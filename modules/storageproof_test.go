@@ -0,0 +1,52 @@
+package modules
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/types"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestBuildStorageProof checks that BuildStorageProof produces proofs for the
+// challenged segment that verify against the file's Merkle root, including
+// files whose final segment is partial.
+func TestBuildStorageProof(t *testing.T) {
+	t.Parallel()
+	var fcid types.FileContractID
+	fastrand.Read(fcid[:])
+	for _, fileSize := range []uint64{1, crypto.SegmentSize, 3*crypto.SegmentSize + 7, 100 * crypto.SegmentSize} {
+		file := fastrand.Bytes(int(fileSize))
+		root := crypto.MerkleRoot(file)
+		leaves := crypto.CalculateLeaves(fileSize)
+		for i := 0; i < 20; i++ {
+			var triggerID types.BlockID
+			fastrand.Read(triggerID[:])
+			sp, err := BuildStorageProof(bytes.NewReader(file), fileSize, fcid, triggerID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sp.ParentID != fcid {
+				t.Fatal("proof has the wrong parent id")
+			}
+			index := ChallengeSegment(triggerID, fcid, fileSize)
+			if len(sp.HashSet) != crypto.MerkleProofLength(leaves, index) {
+				t.Fatalf("proof for segment %v of %v has %v hashes", index, leaves, len(sp.HashSet))
+			}
+			segmentLen := uint64(crypto.SegmentSize)
+			if index == leaves-1 && fileSize%crypto.SegmentSize != 0 {
+				segmentLen = fileSize % crypto.SegmentSize
+			}
+			if !crypto.VerifySegment(sp.Segment[:segmentLen], sp.HashSet, leaves, index, root) {
+				t.Fatalf("proof for segment %v of a %v byte file does not verify", index, fileSize)
+			}
+		}
+	}
+
+	// A file that is shorter than claimed cannot be proven.
+	_, err := BuildStorageProof(bytes.NewReader(make([]byte, 10)), 100, fcid, types.BlockID{})
+	if err == nil {
+		t.Error("expected an error when the file is too short")
+	}
+}
@@ -0,0 +1,166 @@
+package modules
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// transactionorder.go defines the canonical order of the transactions in a
+// block. Transactions come after the transactions that they depend on, and
+// among the transactions whose dependencies have been placed, the one paying
+// the highest fee per byte comes first, with ties broken by the lowest
+// transaction id. Because the order depends only on the transactions
+// themselves, a node holding all of a block's transactions can reconstruct
+// the block without being told the order.
+//
+// The order is not a consensus rule; blocks with other orders remain valid.
+
+type (
+	// orderedTxn holds the data needed to order a transaction.
+	orderedTxn struct {
+		id   types.TransactionID
+		fees types.Currency
+		size uint64
+	}
+
+	// readyHeap holds the indices of the transactions whose dependencies have
+	// all been placed, with the next transaction in the canonical order at
+	// the top.
+	readyHeap struct {
+		txns    []orderedTxn
+		indices []int
+	}
+)
+
+func (h readyHeap) Len() int { return len(h.indices) }
+func (h readyHeap) Less(i, j int) bool {
+	a, b := h.txns[h.indices[i]], h.txns[h.indices[j]]
+	// Compare the fee rates by cross multiplying, so that no precision is
+	// lost to division.
+	if c := a.fees.Mul64(b.size).Cmp(b.fees.Mul64(a.size)); c != 0 {
+		return c > 0
+	}
+	return bytes.Compare(a.id[:], b.id[:]) < 0
+}
+func (h readyHeap) Swap(i, j int)       { h.indices[i], h.indices[j] = h.indices[j], h.indices[i] }
+func (h *readyHeap) Push(x interface{}) { h.indices = append(h.indices, x.(int)) }
+func (h *readyHeap) Pop() interface{} {
+	x := h.indices[len(h.indices)-1]
+	h.indices = h.indices[:len(h.indices)-1]
+	return x
+}
+
+// CanonicalTransactionOrder returns the transactions in their canonical
+// order. The input slice is not modified. If the transactions have circular
+// dependencies, which no valid set of transactions has, the transactions that
+// could not be ordered are appended in their original order.
+func CanonicalTransactionOrder(txns []types.Transaction) []types.Transaction {
+	// Find the transaction that creates each object.
+	ordered := make([]orderedTxn, len(txns))
+	creators := make(map[types.OutputID]int)
+	for i, t := range txns {
+		ordered[i].id = t.ID()
+		for _, fee := range t.MinerFees {
+			ordered[i].fees = ordered[i].fees.Add(fee)
+		}
+		ordered[i].size = uint64(len(encoding.Marshal(t)))
+		for j := range t.SiacoinOutputs {
+			creators[types.OutputID(t.SiacoinOutputID(uint64(j)))] = i
+		}
+		for j := range t.FileContracts {
+			creators[types.OutputID(t.FileContractID(uint64(j)))] = i
+		}
+		for j := range t.SiafundOutputs {
+			creators[types.OutputID(t.SiafundOutputID(uint64(j)))] = i
+		}
+	}
+
+	// Build the dependency graph. A transaction depends on the transactions
+	// creating the objects that it uses, and a file contract revision depends
+	// on the revisions of the same contract with lower revision numbers.
+	children := make([][]int, len(txns))
+	numParents := make([]int, len(txns))
+	addDependency := func(parent, child int) {
+		if parent != child {
+			children[parent] = append(children[parent], child)
+			numParents[child]++
+		}
+	}
+	type revision struct {
+		number uint64
+		txn    int
+	}
+	revisions := make(map[types.FileContractID][]revision)
+	for i, t := range txns {
+		var parentIDs []types.OutputID
+		for _, sci := range t.SiacoinInputs {
+			parentIDs = append(parentIDs, types.OutputID(sci.ParentID))
+		}
+		for _, fcr := range t.FileContractRevisions {
+			parentIDs = append(parentIDs, types.OutputID(fcr.ParentID))
+			revisions[fcr.ParentID] = append(revisions[fcr.ParentID], revision{fcr.NewRevisionNumber, i})
+		}
+		for _, sp := range t.StorageProofs {
+			parentIDs = append(parentIDs, types.OutputID(sp.ParentID))
+		}
+		for _, sfi := range t.SiafundInputs {
+			parentIDs = append(parentIDs, types.OutputID(sfi.ParentID))
+		}
+		for _, id := range parentIDs {
+			if parent, exists := creators[id]; exists {
+				addDependency(parent, i)
+			}
+		}
+	}
+	for _, revs := range revisions {
+		sort.Slice(revs, func(i, j int) bool { return revs[i].number < revs[j].number })
+		for i := 1; i < len(revs); i++ {
+			addDependency(revs[i-1].txn, revs[i].txn)
+		}
+	}
+
+	// Place the transactions, always choosing the best transaction whose
+	// dependencies have been placed.
+	h := &readyHeap{txns: ordered}
+	for i := range txns {
+		if numParents[i] == 0 {
+			h.indices = append(h.indices, i)
+		}
+	}
+	heap.Init(h)
+	placed := make([]bool, len(txns))
+	result := make([]types.Transaction, 0, len(txns))
+	for h.Len() > 0 {
+		i := heap.Pop(h).(int)
+		placed[i] = true
+		result = append(result, txns[i])
+		for _, child := range children[i] {
+			numParents[child]--
+			if numParents[child] == 0 {
+				heap.Push(h, child)
+			}
+		}
+	}
+	for i := range txns {
+		if !placed[i] {
+			result = append(result, txns[i])
+		}
+	}
+	return result
+}
+
+// IsCanonicalTransactionOrder returns true if the transactions are in their
+// canonical order.
+func IsCanonicalTransactionOrder(txns []types.Transaction) bool {
+	canonical := CanonicalTransactionOrder(txns)
+	for i := range txns {
+		if txns[i].ID() != canonical[i].ID() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package modules
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestCanonicalTransactionOrder checks that transactions are ordered with
+// dependencies first, then by fee rate, then by id.
+func TestCanonicalTransactionOrder(t *testing.T) {
+	t.Parallel()
+
+	// Create a parent paying no fees, a child paying a high fee, and two
+	// independent transactions paying the same medium fee.
+	parent := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{Value: types.NewCurrency64(100)}},
+	}
+	child := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: parent.SiacoinOutputID(0)}},
+		MinerFees:     []types.Currency{types.NewCurrency64(100e3)},
+	}
+	medium1 := types.Transaction{
+		MinerFees:     []types.Currency{types.NewCurrency64(1e3)},
+		ArbitraryData: [][]byte{{1}},
+	}
+	medium2 := types.Transaction{
+		MinerFees:     []types.Currency{types.NewCurrency64(1e3)},
+		ArbitraryData: [][]byte{{2}},
+	}
+	first, second := medium1, medium2
+	if id1, id2 := medium1.ID(), medium2.ID(); string(id2[:]) < string(id1[:]) {
+		first, second = medium2, medium1
+	}
+	expected := []types.Transaction{first, second, parent, child}
+
+	// Every permutation of the input should produce the same order.
+	inputs := [][]types.Transaction{
+		{parent, child, medium1, medium2},
+		{child, parent, medium2, medium1},
+		{medium2, child, medium1, parent},
+	}
+	for i, input := range inputs {
+		ordered := CanonicalTransactionOrder(input)
+		for j := range expected {
+			if ordered[j].ID() != expected[j].ID() {
+				t.Fatalf("input %v: wrong transaction at index %v", i, j)
+			}
+		}
+		if IsCanonicalTransactionOrder(input) {
+			t.Errorf("input %v: reported as canonical", i)
+		}
+		if !IsCanonicalTransactionOrder(ordered) {
+			t.Errorf("input %v: output not reported as canonical", i)
+		}
+	}
+
+	// Revisions of the same contract are ordered by revision number, even if
+	// a later revision pays a higher fee.
+	fcid := types.FileContractID{1}
+	rev1 := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{ParentID: fcid, NewRevisionNumber: 1}},
+	}
+	rev2 := types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{{ParentID: fcid, NewRevisionNumber: 2}},
+		MinerFees:             []types.Currency{types.NewCurrency64(1e6)},
+	}
+	ordered := CanonicalTransactionOrder([]types.Transaction{rev2, rev1})
+	if ordered[0].ID() != rev1.ID() || ordered[1].ID() != rev2.ID() {
+		t.Error("revisions were not ordered by revision number")
+	}
+}
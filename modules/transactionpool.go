@@ -3,6 +3,7 @@ package modules
 import (
 	"errors"
 
+	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
 	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/types"
@@ -19,6 +20,37 @@ const (
 	TransactionSetSizeLimit = 250e3
 )
 
+const (
+	// FeePriorityLow targets getting a transaction confirmed within about 10
+	// blocks.
+	FeePriorityLow FeePriority = iota
+
+	// FeePriorityMedium targets getting a transaction confirmed within about
+	// 3 blocks.
+	FeePriorityMedium
+
+	// FeePriorityHigh targets getting a transaction confirmed in the next
+	// block.
+	FeePriorityHigh
+)
+
+const (
+	// The reasons for which the transaction pool rejects transaction sets,
+	// which are used to count the rejected sets in TransactionPoolMetrics.
+	// RejectionNonStandard covers sets breaking the standardness rules,
+	// RejectionLowFees covers sets that pay too little in fees or too small
+	// a fee bump, and RejectionInvalid covers sets that are invalid given
+	// the consensus set.
+	RejectionDuplicate      RejectionReason = "duplicate"
+	RejectionNonStandard    RejectionReason = "nonstandard"
+	RejectionLowFees        RejectionReason = "lowfees"
+	RejectionFullPool       RejectionReason = "fullpool"
+	RejectionDoubleSpend    RejectionReason = "doublespend"
+	RejectionMissingParents RejectionReason = "missingparents"
+	RejectionInvalid        RejectionReason = "invalid"
+	RejectionOther          RejectionReason = "other"
+)
+
 var (
 	// ErrDuplicateTransactionSet is the error that gets returned if a
 	// duplicate transaction set is given to the transaction pool.
@@ -50,6 +82,16 @@ var (
 	// TransactionPoolDir is the name of the directory that is used to store
 	// the transaction pool's persistent data.
 	TransactionPoolDir = "transactionpool"
+
+	// DustThreshold is the smallest siacoin output that the transaction pool
+	// will relay. Smaller outputs cost more in fees to spend than they are
+	// worth, and would bloat the consensus set forever. Wallets should add
+	// change below this threshold to the miner fees instead.
+	DustThreshold = build.Select(build.Var{
+		Standard: types.SiacoinPrecision.Div64(1e3),
+		Dev:      types.ZeroCurrency,
+		Testing:  types.ZeroCurrency,
+	}).(types.Currency)
 )
 
 type (
@@ -59,13 +101,67 @@ type (
 	// it is unlikely that the transaction will ever be valid.
 	ConsensusConflict string
 
+	// A DoubleSpend records a transaction that was rejected by the transaction
+	// pool because it spends an object that is already spent by a transaction
+	// in the pool. Wallets can use it to warn that a competing spend of an
+	// output is in flight.
+	DoubleSpend struct {
+		OutputID        types.OutputID
+		PoolTransaction types.TransactionID
+		Transaction     types.Transaction
+	}
+
+	// FeePriority indicates how quickly a transaction should be confirmed
+	// when asking the transaction pool for a fee estimate.
+	FeePriority int
+
+	// RejectionReason is a category of reasons for which the transaction
+	// pool rejects a transaction set.
+	RejectionReason string
+
+	// TransactionPoolMetrics is a snapshot of counters describing the
+	// transaction sets submitted to the transaction pool since it was
+	// started. Rejected counts the rejected sets by the reason that they were
+	// rejected for.
+	TransactionPoolMetrics struct {
+		Accepted uint64
+		Rejected map[RejectionReason]uint64
+	}
+
+	// TransactionPoolSettings control the policy that the transaction pool
+	// applies to transaction sets submitted to it. MaxPoolSize is the
+	// maximum combined encoded size of the sets in the pool, MinRelayFee is
+	// the minimum fee per byte, and DustThreshold is the smallest siacoin
+	// output allowed. They do not affect which transactions are accepted in
+	// blocks. PersistTransactionSets controls whether the sets in the pool
+	// are saved at shutdown and restored on startup.
+	//
+	// The remaining settings are the limits of the standardness rules:
+	// MaxTransactionSize and MaxTransactionSetSize are the largest encoded
+	// sizes of a transaction and of a set, MaxSignaturesPerInput is the most
+	// signatures that an input may require, and MaxArbitraryDataSize is the
+	// most bytes of arbitrary data that a transaction may carry.
+	TransactionPoolSettings struct {
+		MaxPoolSize   int            `json:"maxpoolsize"`
+		MinRelayFee   types.Currency `json:"minrelayfee"`
+		DustThreshold types.Currency `json:"dustthreshold"`
+
+		PersistTransactionSets bool `json:"persisttransactionsets"`
+
+		MaxTransactionSize    int    `json:"maxtransactionsize"`
+		MaxTransactionSetSize int    `json:"maxtransactionsetsize"`
+		MaxSignaturesPerInput uint64 `json:"maxsignaturesperinput"`
+		MaxArbitraryDataSize  int    `json:"maxarbitrarydatasize"`
+	}
+
 	// TransactionSetID is a type-safe wrapper for a crypto.Hash that represents
 	// the ID of an entire transaction set.
 	TransactionSetID crypto.Hash
 
 	// A TransactionPoolDiff indicates the adding or removal of a transaction set to
-	// the transaction pool. The transactions in the pool are not persisted, so at
-	// startup modules should assume an empty transaction pool.
+	// the transaction pool. Sets restored from disk at startup are reported to
+	// new subscribers like any other set, so modules should not assume an empty
+	// transaction pool.
 	TransactionPoolDiff struct {
 		AppliedTransactions  []*UnconfirmedTransactionSet
 		RevertedTransactions []TransactionSetID
@@ -74,7 +170,9 @@ type (
 	// UnconfirmedTransactionSet defines a new unconfirmed transaction that has
 	// been added to the transaction pool. ID is the ID of the set, IDs contians
 	// an ID for each transaction, eliminating the need to recompute it (because
-	// that's an expensive operation).
+	// that's an expensive operation). Local is set if the set contains a
+	// transaction submitted by this node's wallet, and every other
+	// transaction in the set is an ancestor of such a transaction.
 	UnconfirmedTransactionSet struct {
 		Change *ConsensusChange
 		ID     TransactionSetID
@@ -82,6 +180,8 @@ type (
 		IDs          []types.TransactionID
 		Sizes        []uint64
 		Transactions []types.Transaction
+
+		Local bool
 	}
 )
 
@@ -102,6 +202,12 @@ type (
 		// transactions.
 		AcceptTransactionSet([]types.Transaction) error
 
+		// AcceptLocalTransactionSet accepts a set of transactions created by
+		// this node's wallet. Local transactions are never evicted in favor
+		// of sets paying higher fees, and are always included in this node's
+		// block templates.
+		AcceptLocalTransactionSet([]types.Transaction) error
+
 		// Broadcast broadcasts a transaction set to all of the transaction pool's
 		// peers.
 		Broadcast(ts []types.Transaction)
@@ -109,6 +215,15 @@ type (
 		// Close is necessary for clean shutdown (e.g. during testing).
 		Close() error
 
+		// DoubleSpends returns the most recent transactions that were
+		// rejected for spending an object already spent in the pool.
+		DoubleSpends() []DoubleSpend
+
+		// EstimateFee returns a fee per byte that is likely to get a
+		// transaction confirmed within the number of blocks targeted by the
+		// priority, based on the fees in the pool and in recent blocks.
+		EstimateFee(FeePriority) types.Currency
+
 		// FeeEstimation returns an estimation for how high the transaction fee
 		// needs to be per byte. The minimum recommended targets getting accepted
 		// in ~3 blocks, and the maximum recommended targets getting accepted
@@ -117,6 +232,14 @@ type (
 		// within 10 blocks.
 		FeeEstimation() (minimumRecommended, maximumRecommended types.Currency)
 
+		// Metrics returns a snapshot of the transaction pool's counters.
+		Metrics() TransactionPoolMetrics
+
+		// MinAcceptableFee returns the minimum total fee that a transaction
+		// set of the provided size (in bytes) must pay to be accepted into the
+		// transaction pool right now.
+		MinAcceptableFee(setSize uint64) types.Currency
+
 		// PurgeTransactionPool is a temporary function available to the miner. In
 		// the event that a miner mines an unacceptable block, the transaction pool
 		// will be purged to clear out the transaction pool and get rid of the
@@ -124,6 +247,19 @@ type (
 		// that make this condition necessary.
 		PurgeTransactionPool()
 
+		// SetSettings changes the policy that the transaction pool applies
+		// to transaction sets submitted to it. Sets already in the pool are
+		// not removed.
+		SetSettings(TransactionPoolSettings) error
+
+		// Settings returns the policy that the transaction pool applies to
+		// transaction sets submitted to it.
+		Settings() TransactionPoolSettings
+
+		// Size returns the number of transactions in the transaction pool
+		// and their total encoded size in bytes.
+		Size() (transactions int, size int)
+
 		// TransactionList returns a list of all transactions in the transaction
 		// pool. The transactions are provided in an order that can acceptably be
 		// put into a block.
@@ -138,6 +274,10 @@ type (
 		// corresponding to the provided transaction id.
 		Transaction(id types.TransactionID) (txn types.Transaction, unconfirmedParents []types.Transaction, exists bool)
 
+		// TransactionsByAddress returns the transactions in the pool that
+		// send siacoins or siafunds to the address, or spend them from it.
+		TransactionsByAddress(types.UnlockHash) []types.Transaction
+
 		// Unsubscribe removes a subscriber from the transaction pool.
 		// This is necessary for clean shutdown of the miner.
 		Unsubscribe(TransactionPoolSubscriber)
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/NebulousLabs/Sia/build"
@@ -21,6 +22,17 @@ var (
 	errFullTransactionPool = errors.New("transaction pool cannot accept more transactions")
 	errLowMinerFees        = errors.New("transaction set needs more miner fees to be accepted")
 	errEmptySet            = errors.New("transaction set is empty")
+	errLowFeeBump          = errors.New("replacement transaction set does not pay enough additional miner fees")
+
+	// errMissingParents is a consensus conflict, so that callers treat it
+	// like any other invalid set, but is kept distinct so that relayed sets
+	// can be held as orphans.
+	errMissingParents = modules.NewConsensusConflict("transaction set spends outputs that do not exist")
+
+	// errDoubleSpend is returned for a set that spends an object already
+	// spent by a set in the pool. It is a consensus conflict, so that callers
+	// treat it like any other invalid set.
+	errDoubleSpend = modules.NewConsensusConflict("transaction set spends an object already spent in the transaction pool")
 )
 
 // relatedObjectIDs determines all of the object ids related to a transaction.
@@ -57,20 +69,208 @@ func relatedObjectIDs(ts []types.Transaction) []ObjectID {
 	return oids
 }
 
+// spentObjectIDs returns the ids of the objects that a transaction set
+// consumes from outside of the set. Unlike SpentOutputIDs, the file contracts
+// revised by the set are included, so that a set revising a contract can only
+// be replaced by a set revising the same contract.
+func spentObjectIDs(ts []types.Transaction) map[ObjectID]struct{} {
+	created := make(map[ObjectID]struct{})
+	for _, t := range ts {
+		for i := range t.SiacoinOutputs {
+			created[ObjectID(t.SiacoinOutputID(uint64(i)))] = struct{}{}
+		}
+		for i := range t.FileContracts {
+			created[ObjectID(t.FileContractID(uint64(i)))] = struct{}{}
+		}
+		for i := range t.SiafundOutputs {
+			created[ObjectID(t.SiafundOutputID(uint64(i)))] = struct{}{}
+		}
+	}
+	spent := make(map[ObjectID]struct{})
+	addSpent := func(oid ObjectID) {
+		if _, exists := created[oid]; !exists {
+			spent[oid] = struct{}{}
+		}
+	}
+	for _, t := range ts {
+		for _, oid := range t.SpentOutputIDs() {
+			addSpent(ObjectID(oid))
+		}
+		for _, fcr := range t.FileContractRevisions {
+			addSpent(ObjectID(fcr.ParentID))
+		}
+	}
+	return spent
+}
+
+// replaceableSet returns the id of the pool set that 'ts' would replace by
+// fee. A set can only be replaced if it is the only set that 'ts' conflicts
+// with, the two sets share no transactions, and both sets spend exactly the
+// same objects from outside of the set.
+func (tp *TransactionPool) replaceableSet(ts []types.Transaction, conflicts []TransactionSetID) (TransactionSetID, bool) {
+	id := conflicts[0]
+	for _, conflict := range conflicts[1:] {
+		if conflict != id {
+			return TransactionSetID{}, false
+		}
+	}
+	oldSet := tp.transactionSets[id]
+	oldIDs := make(map[types.TransactionID]struct{})
+	for _, t := range oldSet {
+		oldIDs[t.ID()] = struct{}{}
+	}
+	for _, t := range ts {
+		if _, exists := oldIDs[t.ID()]; exists {
+			return TransactionSetID{}, false
+		}
+	}
+
+	oldSpent, newSpent := spentObjectIDs(oldSet), spentObjectIDs(ts)
+	if len(newSpent) == 0 || len(newSpent) != len(oldSpent) {
+		return TransactionSetID{}, false
+	}
+	for oid := range newSpent {
+		if _, exists := oldSpent[oid]; !exists {
+			return TransactionSetID{}, false
+		}
+	}
+	return id, true
+}
+
 // requiredFeesToExtendTpool returns the amount of fees required to extend the
 // transaction pool to fit another transaction set. The amount returned has the
-// unit 'currency per byte'.
+// unit 'currency per byte'. It is never less than the minimum relay fee.
 func (tp *TransactionPool) requiredFeesToExtendTpool() types.Currency {
-	// If the transaction pool is nearly empty, it can be extended even if there
-	// are no fees.
+	// If the transaction pool is nearly empty, it can be extended by paying
+	// only the minimum relay fee.
 	if tp.transactionListSize < TransactionPoolSizeForFee {
-		return types.ZeroCurrency
+		return tp.minRelayFee
 	}
 
 	// Calculate the fee required to bump out the size of the transaction pool.
 	ratioToTarget := float64(tp.transactionListSize) / TransactionPoolSizeTarget
 	feeFactor := math.Pow(ratioToTarget, TransactionPoolExponentiation)
-	return types.SiacoinPrecision.MulFloat(feeFactor).Div64(1000) // Divide by 1000 to get SC / kb
+	fee := types.SiacoinPrecision.MulFloat(feeFactor).Div64(1000) // Divide by 1000 to get SC / kb
+	if fee.Cmp(tp.minRelayFee) < 0 {
+		return tp.minRelayFee
+	}
+	return fee
+}
+
+// isStorageProofSet returns true if every transaction in the set contains
+// storage proofs and nothing else. Such sets are exempt from the miner fee
+// requirement and are never evicted to make room for sets paying higher fees,
+// so that hosts can get their proofs confirmed during congestion. A file
+// contract can only be proven once, and proofs that conflict with the pool are
+// rejected, which bounds the space these sets can take up.
+func isStorageProofSet(ts []types.Transaction) bool {
+	for _, t := range ts {
+		if len(t.StorageProofs) == 0 ||
+			len(t.SiacoinInputs) != 0 ||
+			len(t.SiacoinOutputs) != 0 ||
+			len(t.FileContracts) != 0 ||
+			len(t.FileContractRevisions) != 0 ||
+			len(t.SiafundInputs) != 0 ||
+			len(t.SiafundOutputs) != 0 ||
+			len(t.MinerFees) != 0 ||
+			len(t.ArbitraryData) != 0 ||
+			len(t.TransactionSignatures) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// transactionSetFees returns the sum of the miner fees paid by a transaction
+// set.
+func transactionSetFees(ts []types.Transaction) types.Currency {
+	var fees types.Currency
+	for _, txn := range ts {
+		for _, fee := range txn.MinerFees {
+			fees = fees.Add(fee)
+		}
+	}
+	return fees
+}
+
+// lowerFeeRate returns true if a set of size 'size1' paying 'fees1' pays a
+// lower fee per byte than a set of size 'size2' paying 'fees2'.
+func lowerFeeRate(fees1 types.Currency, size1 int, fees2 types.Currency, size2 int) bool {
+	return fees1.Mul64(uint64(size2)).Cmp(fees2.Mul64(uint64(size1))) < 0
+}
+
+// setsToEvict returns the transaction sets that need to be removed from the
+// pool to make room for a new set with the given encoded size and fees. Sets
+// are evicted in order of increasing fee rate, and only sets paying a lower
+// fee rate than the new set can be evicted. Local sets and storage proof sets
+// are never evicted. The sets in 'replaced' are about to be replaced by the
+// new set, so their space is counted as free and they are never returned.
+// errFullTransactionPool is returned if enough room cannot be made.
+func (tp *TransactionPool) setsToEvict(size int, fees types.Currency, replaced map[TransactionSetID]struct{}) ([]TransactionSetID, error) {
+	poolSize := tp.transactionListSize
+	for id := range replaced {
+		poolSize -= tp.transactionSetSizes[id]
+	}
+	if poolSize+size <= tp.sizeLimit {
+		return nil, nil
+	}
+
+	type candidate struct {
+		id   TransactionSetID
+		size int
+		fees types.Currency
+	}
+	var candidates []candidate
+	for id, set := range tp.transactionSets {
+		if _, exists := replaced[id]; exists {
+			continue
+		}
+		if tp.isLocalSet(set) || isStorageProofSet(set) {
+			continue
+		}
+		candidates = append(candidates, candidate{
+			id:   id,
+			size: tp.transactionSetSizes[id],
+			fees: transactionSetFees(set),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return lowerFeeRate(candidates[i].fees, candidates[i].size, candidates[j].fees, candidates[j].size)
+	})
+	var evictions []TransactionSetID
+	for _, c := range candidates {
+		if poolSize+size <= tp.sizeLimit {
+			break
+		}
+		if !lowerFeeRate(c.fees, c.size, fees, size) {
+			break
+		}
+		evictions = append(evictions, c.id)
+		poolSize -= c.size
+	}
+	if poolSize+size > tp.sizeLimit {
+		return nil, errFullTransactionPool
+	}
+	return evictions, nil
+}
+
+// removeTransactionSet removes a transaction set and all of the objects it
+// created or consumed from the pool.
+func (tp *TransactionPool) removeTransactionSet(id TransactionSetID) {
+	set := tp.transactionSets[id]
+	for _, oid := range relatedObjectIDs(set) {
+		if tp.knownObjects[oid] == id {
+			delete(tp.knownObjects, oid)
+		}
+	}
+	for _, txn := range set {
+		delete(tp.transactionHeights, txn.ID())
+	}
+	tp.transactionListSize -= tp.transactionSetSizes[id]
+	tp.unindexAddresses(id, set)
+	delete(tp.transactionSets, id)
+	delete(tp.transactionSetSizes, id)
+	delete(tp.transactionSetDiffs, id)
 }
 
 // checkTransactionSetComposition checks if the transaction set is valid given
@@ -92,10 +292,20 @@ func (tp *TransactionPool) checkTransactionSetComposition(ts []types.Transaction
 	// fly.
 
 	// Check that all transactions follow 'Standard.md' guidelines.
-	setSize, err := isStandardTransactionSet(ts)
+	setSize, err := isStandardTransactionSet(ts, tp.limits)
 	if err != nil {
 		return 0, err
 	}
+	for _, t := range ts {
+		err = checkContractWindows(t, tp.blockHeight)
+		if err != nil {
+			return 0, err
+		}
+		err = checkDustOutputs(t, tp.dustThreshold)
+		if err != nil {
+			return 0, err
+		}
+	}
 
 	return setSize, nil
 }
@@ -161,6 +371,15 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 	}
 	superset = append(superset, dedupSet...)
 
+	// Check that the input set does not spend anything that the conflicts
+	// already spend. Such a set can never be valid alongside them, and the
+	// attempt is recorded so that wallets can be warned.
+	doubleSpends := findDoubleSpends(superset[:len(superset)-len(dedupSet)], dedupSet)
+	if len(doubleSpends) > 0 {
+		tp.addDoubleSpends(doubleSpends)
+		return errDoubleSpend
+	}
+
 	// Check the composition of the transaction set, including fees and
 	// IsStandard rules (this is a new set, the rules must be rechecked).
 	setSize, err := tp.checkTransactionSetComposition(superset)
@@ -174,35 +393,48 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 	if err != nil {
 		return err
 	}
-	var setFees types.Currency
-	for _, txn := range superset {
-		for _, fee := range txn.MinerFees {
-			setFees = setFees.Add(fee)
-		}
-	}
-	if requiredFees.Cmp(setFees) > 0 {
+	setFees := transactionSetFees(superset)
+	if requiredFees.Cmp(setFees) > 0 && !isStorageProofSet(superset) {
 		// TODO: check if there is an existing set with lower fees that we can
 		// kick out.
 		return errLowMinerFees
 	}
 
+	// Check that there is room for the superset once the sets it replaces
+	// have been removed, evicting cheaper sets if necessary.
+	tsetSize := len(encoding.Marshal(superset))
+	evictions, err := tp.setsToEvict(tsetSize, setFees, supersetMap)
+	if err != nil {
+		return err
+	}
+
 	// Check that the transaction set is valid.
 	cc, err := txnFn(superset)
+	if isMissingParentErr(err) {
+		return errMissingParents
+	}
 	if err != nil {
 		return modules.NewConsensusConflict("provided transaction set has prereqs, but is still invalid: " + err.Error())
 	}
+	for _, id := range evictions {
+		tp.removeTransactionSet(id)
+	}
 
 	// Remove the conflicts from the transaction pool.
 	for conflict := range supersetMap {
 		conflictSet := tp.transactionSets[conflict]
-		tp.transactionListSize -= len(encoding.Marshal(conflictSet))
+		tp.transactionListSize -= tp.transactionSetSizes[conflict]
+		tp.unindexAddresses(conflict, conflictSet)
 		delete(tp.transactionSets, conflict)
+		delete(tp.transactionSetSizes, conflict)
 		delete(tp.transactionSetDiffs, conflict)
 	}
 
 	// Add the transaction set to the pool.
 	setID := TransactionSetID(crypto.HashObject(superset))
 	tp.transactionSets[setID] = superset
+	tp.transactionSetSizes[setID] = tsetSize
+	tp.indexAddresses(setID, superset)
 	for _, diff := range cc.SiacoinOutputDiffs {
 		tp.knownObjects[ObjectID(diff.ID)] = setID
 	}
@@ -213,7 +445,6 @@ func (tp *TransactionPool) handleConflicts(ts []types.Transaction, conflicts []T
 		tp.knownObjects[ObjectID(diff.ID)] = setID
 	}
 	tp.transactionSetDiffs[setID] = &cc
-	tsetSize := len(encoding.Marshal(superset))
 	tp.transactionListSize += tsetSize
 
 	// debug logging
@@ -255,18 +486,15 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction, txnFn fu
 	}
 
 	// Check that the transaction set has enough fees to justify adding it to
-	// the transaction list.
+	// the transaction list. The required fee is the per-byte fee needed to
+	// extend the pool, multiplied by the encoded size of the set. Sets made
+	// up only of storage proofs are exempt.
 	requiredFees := tp.requiredFeesToExtendTpool().Mul64(setSize)
 	if err != nil {
 		return err
 	}
-	var setFees types.Currency
-	for _, txn := range ts {
-		for _, fee := range txn.MinerFees {
-			setFees = setFees.Add(fee)
-		}
-	}
-	if requiredFees.Cmp(setFees) > 0 {
+	setFees := transactionSetFees(ts)
+	if requiredFees.Cmp(setFees) > 0 && !isStorageProofSet(ts) {
 		// TODO: check if there is an existing set with lower fees that we can
 		// kick out.
 		return errLowMinerFees
@@ -283,22 +511,58 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction, txnFn fu
 			conflicts = append(conflicts, conflict)
 		}
 	}
+
+	// A set that spends exactly the same objects as a single set in the pool
+	// replaces that set if it pays at least MinRBFBump more in fees. Any
+	// other conflict is handled by merging the sets.
+	var replaced map[TransactionSetID]struct{}
 	if len(conflicts) > 0 {
-		return tp.handleConflicts(ts, conflicts, txnFn)
+		oldID, ok := tp.replaceableSet(ts, conflicts)
+		if !ok {
+			return tp.handleConflicts(ts, conflicts, txnFn)
+		}
+		oldFees := transactionSetFees(tp.transactionSets[oldID])
+		if setFees.Cmp(oldFees.Add(MinRBFBump)) < 0 {
+			tp.addDoubleSpends(findDoubleSpends(tp.transactionSets[oldID], ts))
+			return errLowFeeBump
+		}
+		replaced = map[TransactionSetID]struct{}{oldID: {}}
 	}
+
+	// Check the set against the consensus set before making room for it. A
+	// set with missing parents may claim fees that its inputs cannot cover,
+	// so it must not be weighed against the sets in the pool.
 	cc, err := txnFn(ts)
+	if isMissingParentErr(err) {
+		return errMissingParents
+	}
 	if err != nil {
 		return modules.NewConsensusConflict("provided transaction set is standalone and invalid: " + err.Error())
 	}
 
+	// Check that there is room for the set, evicting cheaper sets if
+	// necessary.
+	tsetSize := len(encoding.Marshal(ts))
+	evictions, err := tp.setsToEvict(tsetSize, setFees, replaced)
+	if err != nil {
+		return err
+	}
+	for _, id := range evictions {
+		tp.removeTransactionSet(id)
+	}
+	for id := range replaced {
+		tp.removeTransactionSet(id)
+	}
+
 	// Add the transaction set to the pool.
 	setID := TransactionSetID(crypto.HashObject(ts))
 	tp.transactionSets[setID] = ts
+	tp.transactionSetSizes[setID] = tsetSize
+	tp.indexAddresses(setID, ts)
 	for _, oid := range oids {
 		tp.knownObjects[oid] = setID
 	}
 	tp.transactionSetDiffs[setID] = &cc
-	tsetSize := len(encoding.Marshal(ts))
 	tp.transactionListSize += tsetSize
 	for _, txn := range ts {
 		if _, exists := tp.transactionHeights[txn.ID()]; !exists {
@@ -323,6 +587,48 @@ func (tp *TransactionPool) acceptTransactionSet(ts []types.Transaction, txnFn fu
 //
 // TODO: Break into component sets when the set gets accepted.
 func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
+	return tp.managedAcceptTransactionSet(ts, originModule, "")
+}
+
+// AcceptLocalTransactionSet adds a transaction set created by this node's
+// wallet to the unconfirmed set of transactions. Local transactions are not
+// evicted to make room for sets paying higher fees, and are always included
+// in this node's block templates.
+func (tp *TransactionPool) AcceptLocalTransactionSet(ts []types.Transaction) error {
+	return tp.managedAcceptTransactionSet(ts, originLocal, "")
+}
+
+// rejectionReason returns the reason that a transaction set was rejected for,
+// given the error that it was rejected with. Errors are grouped into a fixed
+// set of reasons so that the counts do not depend on error messages, which may
+// wrap the errors of the consensus set.
+func rejectionReason(err error) modules.RejectionReason {
+	switch {
+	case err == modules.ErrDuplicateTransactionSet:
+		return modules.RejectionDuplicate
+	case err == errEmptySet || isNonStandardErr(err):
+		return modules.RejectionNonStandard
+	case err == errLowMinerFees || err == errLowFeeBump:
+		return modules.RejectionLowFees
+	case err == errFullTransactionPool:
+		return modules.RejectionFullPool
+	case err == errDoubleSpend || err == errObjectConflict:
+		return modules.RejectionDoubleSpend
+	case err == errMissingParents:
+		return modules.RejectionMissingParents
+	}
+	if _, ok := err.(modules.ConsensusConflict); ok {
+		return modules.RejectionInvalid
+	}
+	return modules.RejectionOther
+}
+
+// managedAcceptTransactionSet adds a transaction set to the pool. Sets that
+// were relayed by a peer are held as orphans if they spend outputs that do not
+// exist yet, as their parents may still be propagating. The peer is only used
+// for sets relayed by a peer, to bound the orphans held for each peer. Sets
+// submitted locally are rejected outright, so that the caller can fix them.
+func (tp *TransactionPool) managedAcceptTransactionSet(ts []types.Transaction, origin setOrigin, peer modules.NetAddress) error {
 	// assert on consensus set to get special method
 	cs, ok := tp.consensusSet.(interface {
 		LockedTryTransactionSet(fn func(func(txns []types.Transaction) (modules.ConsensusChange, error)) error) error
@@ -335,10 +641,23 @@ func (tp *TransactionPool) AcceptTransactionSet(ts []types.Transaction) error {
 		tp.mu.Lock()
 		defer tp.mu.Unlock()
 		err := tp.acceptTransactionSet(ts, txnFn)
+		if err == errMissingParents && origin == originPeer {
+			tp.addOrphan(ts, peer)
+		}
 		if err != nil {
+			tp.metrics.Rejected[rejectionReason(err)]++
 			return err
 		}
+		tp.metrics.Accepted++
+		if origin == originLocal {
+			tp.markLocal(ts)
+		}
 		go tp.gateway.Broadcast("RelayTransactionSet", ts, tp.gateway.Peers())
+		// The set may provide the parents of some orphans.
+		for _, set := range tp.retryOrphans(createdObjectIDs(ts), txnFn) {
+			tp.metrics.Accepted++
+			go tp.gateway.Broadcast("RelayTransactionSet", set, tp.gateway.Peers())
+		}
 		// Notify subscribers of an accepted transaction set
 		tp.updateSubscribersTransactions()
 		return nil
@@ -370,5 +689,5 @@ func (tp *TransactionPool) relayTransactionSet(conn modules.PeerConn) error {
 		return err
 	}
 
-	return tp.AcceptTransactionSet(ts)
+	return tp.managedAcceptTransactionSet(ts, originPeer, conn.RPCAddr())
 }
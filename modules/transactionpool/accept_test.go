@@ -1,8 +1,11 @@
 package transactionpool
 
 import (
+	"sync"
 	"testing"
 
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/types"
 	"github.com/NebulousLabs/fastrand"
@@ -226,6 +229,395 @@ func TestCheckMinerFees(t *testing.T) {
 	}
 }
 
+// TestMinAcceptableFee checks that MinAcceptableFee returns the exact fee
+// required by the transaction pool once the pool is full enough to demand
+// fees.
+func TestMinAcceptableFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// An empty pool should not require any fees.
+	if !tpt.tpool.MinAcceptableFee(1e3).IsZero() {
+		t.Fatal("empty transaction pool should not require fees")
+	}
+
+	// Create an output that can be used to build a transaction graph, and
+	// mine it so that it does not share a set with the graph.
+	graphFund := types.SiacoinPrecision.Mul64(1000)
+	ids, err := tpt.fundUnlockedOutputs(graphFund, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := ids[0]
+
+	// Fill the transaction pool beyond the point where fees are required.
+	for i := 0; i < TransactionPoolSizeForFee/10e3; i++ {
+		arbData := make([]byte, 10e3)
+		copy(arbData, modules.PrefixNonSia[:])
+		fastrand.Read(arbData[100:116])
+		txn := types.Transaction{ArbitraryData: [][]byte{arbData}}
+		err := tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Build a graph paying exactly the minimum fee. The encoded size of the
+	// fee affects the size of the graph, so iterate until the fee and the
+	// size agree.
+	graphWithFee := func(fee types.Currency) ([]types.Transaction, uint64) {
+		graph, err := types.TransactionGraph(source, []types.TransactionGraphEdge{{
+			Dest:   1,
+			Fee:    fee,
+			Source: 0,
+			Value:  graphFund.Sub(fee),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := isStandardTransactionSet(graph, defaultStandardLimits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return graph, size
+	}
+	minFee := types.SiacoinPrecision
+	exactGraph, size := graphWithFee(minFee)
+	for i := 0; tpt.tpool.MinAcceptableFee(size).Cmp(minFee) != 0; i++ {
+		if i > 5 {
+			t.Fatal("fee and graph size did not converge")
+		}
+		minFee = tpt.tpool.MinAcceptableFee(size)
+		exactGraph, size = graphWithFee(minFee)
+	}
+	if minFee.IsZero() {
+		t.Fatal("full transaction pool should require fees")
+	}
+
+	// A graph paying one hasting less than the minimum should be rejected.
+	lowGraph, _ := graphWithFee(minFee.Sub(types.NewCurrency64(1)))
+	err = tpt.tpool.AcceptTransactionSet(lowGraph)
+	if err != errLowMinerFees {
+		t.Fatal("expected errLowMinerFees, got", err)
+	}
+
+	// The graph paying the minimum should be accepted.
+	err = tpt.tpool.AcceptTransactionSet(exactGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createStorageProof creates a file contract whose proof window opens in the
+// next block, mines the contract, and returns a valid storage proof for it.
+func (tpt *tpoolTester) createStorageProof() (types.StorageProof, error) {
+	// The file is a whole number of segments. At low heights the testing
+	// build still uses the pre-hardfork rules, which verify the full final
+	// segment even when the file ends partway through it.
+	file := fastrand.Bytes(64 * crypto.SegmentSize)
+	payout := types.NewCurrency64(400e6)
+	height := tpt.cs.Height()
+	fc := types.FileContract{
+		FileSize:       uint64(len(file)),
+		FileMerkleRoot: crypto.MerkleRoot(file),
+		WindowStart:    height + 1,
+		WindowEnd:      height + 5,
+		Payout:         payout,
+		ValidProofOutputs: []types.SiacoinOutput{{
+			Value: types.PostTax(height, payout),
+		}},
+		MissedProofOutputs: []types.SiacoinOutput{{
+			Value: types.PostTax(height, payout),
+		}},
+	}
+	txnBuilder := tpt.wallet.StartTransaction()
+	err := txnBuilder.FundSiacoins(payout)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+	fcIndex := txnBuilder.AddFileContract(fc)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+	err = tpt.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+
+	fcid := txnSet[len(txnSet)-1].FileContractID(fcIndex)
+	segmentIndex, err := tpt.cs.StorageProofSegment(fcid)
+	if err != nil {
+		return types.StorageProof{}, err
+	}
+	segment, hashSet := crypto.MerkleProof(file, segmentIndex)
+	sp := types.StorageProof{
+		ParentID: fcid,
+		HashSet:  hashSet,
+	}
+	copy(sp.Segment[:], segment)
+	return sp, nil
+}
+
+// TestAcceptStorageProofWithoutFees checks that a transaction set made up only
+// of storage proofs is accepted without fees even when the pool is full enough
+// to require fees from other sets.
+func TestAcceptStorageProofWithoutFees(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+	sp, err := tpt.createStorageProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the transaction pool beyond the point where fees are required.
+	for i := 0; i < TransactionPoolSizeForFee/10e3; i++ {
+		arbData := make([]byte, 10e3)
+		copy(arbData, modules.PrefixNonSia[:])
+		fastrand.Read(arbData[100:116])
+		txn := types.Transaction{ArbitraryData: [][]byte{arbData}}
+		err := tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{}})
+	if err != errLowMinerFees {
+		t.Fatal("expected errLowMinerFees, got", err)
+	}
+
+	// Submit the storage proof without any fees.
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{{StorageProofs: []types.StorageProof{sp}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStorageProofSetNotEvicted checks that a storage proof set, which pays
+// no fees, is not evicted to make room for a set paying fees.
+func TestStorageProofSetNotEvicted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+	sp, err := tpt.createStorageProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the pool with the storage proof and a set of arbitrary data, both
+	// paying no fees.
+	proofSet := []types.Transaction{{StorageProofs: []types.StorageProof{sp}}}
+	err = tpt.tpool.AcceptTransactionSet(proofSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arbData := make([]byte, 10e3)
+	copy(arbData, modules.PrefixNonSia[:])
+	fastrand.Read(arbData[100:116])
+	dataSet := []types.Transaction{{ArbitraryData: [][]byte{arbData}}}
+	err = tpt.tpool.AcceptTransactionSet(dataSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = tpt.tpool.transactionListSize
+	tpt.tpool.mu.Unlock()
+
+	// A set paying fees should evict the arbitrary data rather than the
+	// storage proof.
+	_, err = tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, exists := tpt.tpool.Transaction(proofSet[0].ID()); !exists {
+		t.Error("storage proof set was evicted")
+	}
+	if _, _, exists := tpt.tpool.Transaction(dataSet[0].ID()); exists {
+		t.Error("arbitrary data set was not evicted")
+	}
+}
+
+// TestStorageProofOnlyTransaction submits a transaction that has no inputs and
+// only a storage proof, mines it, and checks that it leaves the pool and
+// resolves the file contract.
+func TestStorageProofOnlyTransaction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+	sp, err := tpt.createStorageProof()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txn := types.Transaction{StorageProofs: []types.StorageProof{sp}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, exists := tpt.tpool.Transaction(txn.ID()); !exists {
+		t.Fatal("storage proof transaction is not in the pool")
+	}
+	// A second proof for the same contract conflicts with the first.
+	dupTxn := types.Transaction{
+		StorageProofs: []types.StorageProof{sp},
+		ArbitraryData: [][]byte{modules.PrefixNonSia[:]},
+	}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{dupTxn})
+	if err == nil {
+		t.Fatal("conflicting storage proof was accepted")
+	}
+
+	b, err := tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined := false
+	for _, bt := range b.Transactions {
+		if bt.ID() == txn.ID() {
+			mined = true
+		}
+	}
+	if !mined {
+		t.Fatal("storage proof transaction was not mined")
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("transaction pool is not empty after mining the storage proof")
+	}
+	if _, err := tpt.cs.StorageProofSegment(sp.ParentID); err == nil {
+		t.Fatal("file contract still exists after its storage proof was mined")
+	}
+}
+
+// TestConcurrentAcceptTransactionAndBlock submits conflicting transaction sets
+// to the transaction pool while blocks are being mined, and then checks that
+// the pool is left without double spends and consistent with the tip of the
+// consensus set.
+func TestConcurrentAcceptTransactionAndBlock(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create a set of outputs that can be spent without signatures, and mine
+	// them into the blockchain.
+	numOutputs := 20
+	fund := types.SiacoinPrecision.Mul64(100)
+	sources, err := tpt.fundUnlockedOutputs(fund, numOutputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend creates a transaction spending the source output to a destination
+	// derived from 'variant', so that different variants conflict.
+	spend := func(source types.SiacoinOutputID, variant byte) types.Transaction {
+		fee := types.SiacoinPrecision.Mul64(uint64(variant) + 1)
+		return types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{
+				ParentID: source,
+			}},
+			SiacoinOutputs: []types.SiacoinOutput{{
+				UnlockHash: types.UnlockHash{variant},
+				Value:      fund.Sub(fee),
+			}},
+			MinerFees: []types.Currency{fee},
+		}
+	}
+
+	// Submit several conflicting spends of every output while mining blocks
+	// in parallel.
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		for variant := byte(0); variant < 3; variant++ {
+			wg.Add(1)
+			go func(source types.SiacoinOutputID, variant byte) {
+				defer wg.Done()
+				// Errors are expected, as only one variant can win.
+				tpt.tpool.AcceptTransactionSet([]types.Transaction{spend(source, variant)})
+			}(source, variant)
+		}
+	}
+	mineErrs := make(chan error, 5)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < cap(mineErrs); i++ {
+			_, err := tpt.miner.AddBlock()
+			mineErrs <- err
+		}
+	}()
+	wg.Wait()
+	close(mineErrs)
+	for err := range mineErrs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The pool should not contain any double spends, and should be valid
+	// on top of the current tip.
+	spent := make(map[types.SiacoinOutputID]struct{})
+	for _, txn := range tpt.tpool.TransactionList() {
+		for _, sci := range txn.SiacoinInputs {
+			if _, exists := spent[sci.ParentID]; exists {
+				t.Fatal("transaction pool contains a double spend")
+			}
+			spent[sci.ParentID] = struct{}{}
+		}
+	}
+	_, err = tpt.cs.TryTransactionSet(tpt.tpool.TransactionList())
+	if err != nil {
+		t.Fatal("transaction pool is inconsistent with the consensus set:", err)
+	}
+
+	// Mine the rest of the pool. Afterwards, every source output should have
+	// been spent exactly once, so no variant can be accepted any more.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("transaction pool should be empty after mining a block")
+	}
+	for _, source := range sources {
+		for variant := byte(0); variant < 3; variant++ {
+			err = tpt.tpool.AcceptTransactionSet([]types.Transaction{spend(source, variant)})
+			if err == nil {
+				t.Fatal("output was not spent after all sets were mined")
+			}
+		}
+	}
+}
+
 // TestTransactionGraph checks that the TransactionGraph method of the types
 // package is able to create transasctions that actually validate and can get
 // inserted into the tpool.
@@ -749,3 +1141,294 @@ func TestPartialConfirmationWeave(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestTransactionPoolSizeLimit fills the transaction pool and checks that a
+// set paying a higher fee rate evicts the cheapest set, while a set paying a
+// lower fee rate is rejected.
+func TestTransactionPoolSizeLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create several outputs that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend creates a transaction set spending output 'i' with the given
+	// miner fee. All of the sets have the same size.
+	spend := func(i int, fee uint64) []types.Transaction {
+		return []types.Transaction{spendUnlockedOutputs(value, types.NewCurrency64(fee), ids[i])}
+	}
+	inPool := func(ts []types.Transaction) bool {
+		_, _, exists := tpt.tpool.Transaction(ts[0].ID())
+		return exists
+	}
+
+	// Limit the pool to three sets and fill it.
+	tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = 3 * len(encoding.Marshal(spend(0, 2000)))
+	tpt.tpool.mu.Unlock()
+	for i, fee := range []uint64{3000, 2000, 4000} {
+		err = tpt.tpool.AcceptTransactionSet(spend(i, fee))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sets paying the same or a lower fee rate than the cheapest set in the
+	// pool should be rejected.
+	for _, fee := range []uint64{1000, 2000} {
+		err = tpt.tpool.AcceptTransactionSet(spend(3, fee))
+		if err != errFullTransactionPool {
+			t.Fatalf("expected %v, got %v", errFullTransactionPool, err)
+		}
+	}
+
+	// A set paying more should evict the cheapest set.
+	err = tpt.tpool.AcceptTransactionSet(spend(4, 5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inPool(spend(1, 2000)) {
+		t.Error("cheapest set was not evicted")
+	}
+	for _, ts := range [][]types.Transaction{spend(0, 3000), spend(2, 4000), spend(4, 5000)} {
+		if !inPool(ts) {
+			t.Error("set was unexpectedly evicted")
+		}
+	}
+	tpt.tpool.mu.Lock()
+	size, limit := tpt.tpool.transactionListSize, tpt.tpool.sizeLimit
+	tpt.tpool.mu.Unlock()
+	if size > limit {
+		t.Errorf("pool size %v exceeds the limit of %v", size, limit)
+	}
+
+	// The output spent by the evicted set can be spent again, and the evicted
+	// set's objects should no longer conflict.
+	err = tpt.tpool.AcceptTransactionSet(spend(1, 6000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inPool(spend(0, 3000)) {
+		t.Error("cheapest set was not evicted")
+	}
+
+	// Mining should empty the pool.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Error("pool was not emptied by mining a block")
+	}
+}
+
+// TestMinRelayFee checks that transaction sets paying less than the minimum
+// relay fee are rejected, even when the pool is nearly empty.
+func TestMinRelayFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create an output that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := func(fee types.Currency) []types.Transaction {
+		return []types.Transaction{spendUnlockedOutputs(value, fee, ids[0])}
+	}
+
+	// Raise the minimum relay fee through the pool's settings. A pool size
+	// limit of zero is refused.
+	settings := tpt.tpool.Settings()
+	settings.MinRelayFee = types.NewCurrency64(100)
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.MaxPoolSize = 0
+	err = tpt.tpool.SetSettings(settings)
+	if err != errInvalidPoolSize {
+		t.Fatalf("expected %v, got %v", errInvalidPoolSize, err)
+	}
+	// The encoded size of a currency depends on its value, so the size is
+	// measured with a fee of similar magnitude to the ones being tested.
+	size := uint64(len(encoding.Marshal(spend(types.NewCurrency64(1000))[0])))
+	if min := tpt.tpool.MinAcceptableFee(size); !min.Equals(types.NewCurrency64(100 * size)) {
+		t.Fatalf("expected a minimum fee of %v, got %v", 100*size, min)
+	}
+
+	// A free set and a set paying just below the minimum are rejected.
+	for _, fee := range []types.Currency{types.ZeroCurrency, types.NewCurrency64(100*size - 1)} {
+		err = tpt.tpool.AcceptTransactionSet(spend(fee))
+		if err != errLowMinerFees {
+			t.Fatalf("expected %v, got %v", errLowMinerFees, err)
+		}
+	}
+
+	// A set paying the minimum is accepted.
+	err = tpt.tpool.AcceptTransactionSet(spend(types.NewCurrency64(100 * size)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReplaceByFee checks that a transaction set can be replaced by a set
+// spending the same outputs with higher fees, and that other conflicts are
+// still rejected.
+func TestReplaceByFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create two outputs that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend creates a transaction set spending the given outputs with the
+	// given miner fee.
+	spend := func(fee types.Currency, outputIDs ...types.SiacoinOutputID) []types.Transaction {
+		return []types.Transaction{spendUnlockedOutputs(value, fee, outputIDs...)}
+	}
+	inPool := func(ts []types.Transaction) bool {
+		_, _, exists := tpt.tpool.Transaction(ts[0].ID())
+		return exists
+	}
+
+	fee := types.SiacoinPrecision.Div64(100)
+	original := spend(fee, ids[0])
+	err = tpt.tpool.AcceptTransactionSet(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A replacement that does not increase the fees enough should be
+	// rejected.
+	err = tpt.tpool.AcceptTransactionSet(spend(fee.Add(MinRBFBump).Sub(types.NewCurrency64(1)), ids[0]))
+	if err != errLowFeeBump {
+		t.Fatalf("expected %v, got %v", errLowFeeBump, err)
+	}
+
+	// A set that only partially overlaps with the original should be
+	// rejected, no matter how much it pays.
+	err = tpt.tpool.AcceptTransactionSet(spend(fee.Mul64(10), ids[0], ids[1]))
+	if err == nil {
+		t.Fatal("partially conflicting set was accepted")
+	}
+	if !inPool(original) {
+		t.Fatal("original set was removed by a rejected set")
+	}
+
+	// A sufficient fee bump should replace the original set.
+	replacement := spend(fee.Add(MinRBFBump), ids[0])
+	err = tpt.tpool.AcceptTransactionSet(replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inPool(original) {
+		t.Error("original set was not removed")
+	}
+	if !inPool(replacement) {
+		t.Error("replacement set was not added")
+	}
+	txnList := tpt.tpool.TransactionList()
+	if len(txnList) != 1 || txnList[0].ID() != replacement[0].ID() {
+		t.Errorf("expected only the replacement in the transaction list, got %v transactions", len(txnList))
+	}
+	tpt.tpool.mu.Lock()
+	size := tpt.tpool.transactionListSize
+	tpt.tpool.mu.Unlock()
+	if size != len(encoding.Marshal(replacement)) {
+		t.Errorf("pool size is %v, expected %v", size, len(encoding.Marshal(replacement)))
+	}
+
+	// The replacement should be the transaction that gets mined.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Error("pool was not emptied by mining a block")
+	}
+	tpt.tpool.mu.Lock()
+	confirmed := tpt.tpool.transactionConfirmed(tpt.tpool.dbTx, replacement[0].ID())
+	tpt.tpool.mu.Unlock()
+	if !confirmed {
+		t.Error("replacement set was not confirmed")
+	}
+}
+
+// TestAcceptDistantFileContract checks that the transaction pool only accepts
+// file contracts whose proof windows are within the allowed distance of the
+// current height.
+func TestAcceptDistantFileContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	payout := types.NewCurrency64(1e9)
+	height := tpt.cs.Height()
+	tests := []struct {
+		start, end types.BlockHeight
+		err        error
+	}{
+		{height + MaxContractStartDelay + 1, height + MaxContractStartDelay + 2, errContractStartTooLate},
+		{height + 2, height + MaxContractDuration + 1, errContractTooLong},
+		{height + MaxContractStartDelay, height + MaxContractDuration, nil},
+	}
+	for i, test := range tests {
+		builder := tpt.wallet.StartTransaction()
+		err = builder.FundSiacoins(payout)
+		if err != nil {
+			t.Fatal(err)
+		}
+		builder.AddFileContract(types.FileContract{
+			WindowStart:        test.start,
+			WindowEnd:          test.end,
+			Payout:             payout,
+			ValidProofOutputs:  []types.SiacoinOutput{{Value: types.PostTax(height, payout)}},
+			MissedProofOutputs: []types.SiacoinOutput{{Value: types.PostTax(height, payout)}},
+			UnlockHash:         types.UnlockConditions{}.UnlockHash(),
+		})
+		tSet, err := builder.Sign(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tpt.tpool.AcceptTransactionSet(tSet)
+		if err != test.err {
+			t.Errorf("contract %v: expected %v, got %v", i, test.err, err)
+		}
+		if err != nil {
+			builder.Drop()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// addresses.go maintains an index from addresses to the transaction sets in
+// the pool that send coins to or spend coins from them, so that the unconfirmed
+// activity of an address can be found without scanning the whole pool.
+
+// relatedAddresses returns the addresses that a transaction sends siacoins or
+// siafunds to, or spends siacoins or siafunds from.
+func relatedAddresses(t types.Transaction) []types.UnlockHash {
+	var addrs []types.UnlockHash
+	for _, sci := range t.SiacoinInputs {
+		addrs = append(addrs, sci.UnlockConditions.UnlockHash())
+	}
+	for _, sco := range t.SiacoinOutputs {
+		addrs = append(addrs, sco.UnlockHash)
+	}
+	for _, sfi := range t.SiafundInputs {
+		addrs = append(addrs, sfi.UnlockConditions.UnlockHash())
+	}
+	for _, sfo := range t.SiafundOutputs {
+		addrs = append(addrs, sfo.UnlockHash)
+	}
+	return addrs
+}
+
+// indexAddresses adds a transaction set to the address index.
+func (tp *TransactionPool) indexAddresses(id TransactionSetID, ts []types.Transaction) {
+	for _, t := range ts {
+		for _, addr := range relatedAddresses(t) {
+			if tp.addressSets[addr] == nil {
+				tp.addressSets[addr] = make(map[TransactionSetID]struct{})
+			}
+			tp.addressSets[addr][id] = struct{}{}
+		}
+	}
+}
+
+// unindexAddresses removes a transaction set from the address index.
+func (tp *TransactionPool) unindexAddresses(id TransactionSetID, ts []types.Transaction) {
+	for _, t := range ts {
+		for _, addr := range relatedAddresses(t) {
+			delete(tp.addressSets[addr], id)
+			if len(tp.addressSets[addr]) == 0 {
+				delete(tp.addressSets, addr)
+			}
+		}
+	}
+}
+
+// TransactionsByAddress returns the transactions in the pool that send
+// siacoins or siafunds to the address, or spend siacoins or siafunds from it.
+func (tp *TransactionPool) TransactionsByAddress(addr types.UnlockHash) []types.Transaction {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	var txns []types.Transaction
+	for id := range tp.addressSets[addr] {
+		for _, t := range tp.transactionSets[id] {
+			for _, related := range relatedAddresses(t) {
+				if related == addr {
+					txns = append(txns, t)
+					break
+				}
+			}
+		}
+	}
+	return txns
+}
@@ -0,0 +1,74 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestTransactionsByAddress checks that the address index tracks the
+// transactions in the pool as they are added and mined.
+func TestTransactionsByAddress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Send coins to an address that can be spent without signatures.
+	uc := types.UnlockConditions{}
+	addr := uc.UnlockHash()
+	value := types.SiacoinPrecision.Mul64(10)
+	txns, err := tpt.wallet.SendSiacoins(value, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fundTxn := txns[len(txns)-1]
+	if byAddr := tpt.tpool.TransactionsByAddress(addr); len(byAddr) != 1 || byAddr[0].ID() != fundTxn.ID() {
+		t.Fatal("incoming transaction not found by address:", byAddr)
+	}
+	if byAddr := tpt.tpool.TransactionsByAddress(types.UnlockHash{1}); len(byAddr) != 0 {
+		t.Fatal("unrelated address has transactions:", byAddr)
+	}
+
+	// Spend the coins in a child transaction. Both the incoming and outgoing
+	// transactions should be found.
+	var id types.SiacoinOutputID
+	for i, sco := range fundTxn.SiacoinOutputs {
+		if sco.UnlockHash == addr {
+			id = fundTxn.SiacoinOutputID(uint64(i))
+		}
+	}
+	child := types.Transaction{
+		SiacoinInputs:  []types.SiacoinInput{{ParentID: id, UnlockConditions: uc}},
+		SiacoinOutputs: []types.SiacoinOutput{{Value: value, UnlockHash: types.UnlockHash{2}}},
+	}
+	err = tpt.tpool.AcceptTransactionSet(append(txns, child))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byAddr := tpt.tpool.TransactionsByAddress(addr); len(byAddr) != 2 {
+		t.Fatal("expected 2 transactions, got", len(byAddr))
+	}
+	if byAddr := tpt.tpool.TransactionsByAddress(types.UnlockHash{2}); len(byAddr) != 1 || byAddr[0].ID() != child.ID() {
+		t.Fatal("outgoing transaction not found by address:", byAddr)
+	}
+
+	// Mining the transactions should empty the index.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byAddr := tpt.tpool.TransactionsByAddress(addr); len(byAddr) != 0 {
+		t.Fatal("confirmed transactions are still indexed:", byAddr)
+	}
+	tpt.tpool.mu.Lock()
+	numAddrs := len(tpt.tpool.addressSets)
+	tpt.tpool.mu.Unlock()
+	if numAddrs != 0 {
+		t.Fatal("address index was not emptied:", numAddrs)
+	}
+}
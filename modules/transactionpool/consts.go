@@ -37,6 +37,36 @@ const (
 	// limit is to help the network grow and provide some wiggle room for
 	// wallets that are not yet able to operate via a fee market.
 	TransactionPoolSizeForFee = 500e3
+
+	// TransactionPoolSizeLimit is the maximum encoded size of all of the
+	// transaction sets in the pool. Once the pool is full, a new set is only
+	// accepted if it pays a higher fee rate than the sets it evicts.
+	TransactionPoolSizeLimit = 2 * TransactionPoolSizeTarget
+)
+
+// Constants related to the file contracts that the transaction pool accepts.
+const (
+	// MaxContractStartDelay is the furthest in the future, relative to the
+	// current height, that the proof window of a file contract can start.
+	MaxContractStartDelay = types.BlockHeight(144 * 365 * 2)
+
+	// MaxContractDuration is the furthest in the future, relative to the
+	// current height, that the proof window of a file contract can end.
+	MaxContractDuration = MaxContractStartDelay + types.BlockHeight(144*30)
+)
+
+// Constants related to the unlock conditions that the transaction pool accepts.
+const (
+	// MaxSignaturesPerInput is the default for the largest number of
+	// signatures that the unlock conditions of an input may require.
+	MaxSignaturesPerInput = 16
+)
+
+// Constants related to the arbitrary data that the transaction pool accepts.
+const (
+	// MaxArbitraryDataSize is the default for the largest total number of
+	// bytes of arbitrary data that a single transaction may carry.
+	MaxArbitraryDataSize = 16e3
 )
 
 // Constants related to fee estimation.
@@ -54,6 +84,13 @@ const (
 	// amount required to extend the fee pool when coming up with a min fee
 	// recommendation.
 	minExtendMultiplier = 1.2
+
+	// lowPriorityBlocks, mediumPriorityBlocks and highPriorityBlocks are the
+	// number of blocks that EstimateFee assumes are available to a
+	// transaction with the corresponding fee priority.
+	lowPriorityBlocks    = 10
+	mediumPriorityBlocks = 3
+	highPriorityBlocks   = 1
 )
 
 // Variables related to the persisting structures of the transaction pool.
@@ -69,6 +106,62 @@ var (
 	// minEstimation defines a sane minimum fee per byte for transactions.  This
 	// will typically be only suggested as a fee in the absense of congestion.
 	minEstimation = types.SiacoinPrecision.Div64(100).Div64(1e3)
+
+	// MinRBFBump is the amount by which the total miner fees of a replacement
+	// transaction set must exceed the fees of the set it replaces.
+	MinRBFBump = types.SiacoinPrecision.Div64(1e3)
+
+	// MinRelayFee is the minimum fee per byte that a transaction set must pay
+	// to be accepted by the transaction pool, regardless of how full the pool
+	// is. It keeps free transactions from being used to spam the network. It
+	// is well below minEstimation so that wallets following the fee
+	// estimates are never affected. Blocks are not subject to this policy.
+	MinRelayFee = build.Select(build.Var{
+		Standard: minEstimation.Div64(10),
+		Dev:      types.ZeroCurrency,
+		Testing:  types.ZeroCurrency,
+	}).(types.Currency)
+)
+
+// Variables related to orphan transaction sets.
+var (
+	// maxOrphanSets is the maximum number of transaction sets with missing
+	// parents that the transaction pool will hold on to. Once the limit is
+	// reached, the oldest orphan is dropped to make room for a new one.
+	maxOrphanSets = build.Select(build.Var{
+		Standard: 100,
+		Dev:      50,
+		Testing:  3,
+	}).(int)
+
+	// maxOrphanSetsPerPeer is the maximum number of orphans that the
+	// transaction pool will hold for a single peer. Once the limit is
+	// reached, the peer's oldest orphan is dropped to make room for a new
+	// one, so that one peer cannot crowd out the orphans of the others.
+	maxOrphanSetsPerPeer = build.Select(build.Var{
+		Standard: 10,
+		Dev:      5,
+		Testing:  2,
+	}).(int)
+
+	// maxOrphanSize is the maximum combined encoded size of the orphans that
+	// the transaction pool will hold, in bytes.
+	maxOrphanSize = build.Select(build.Var{
+		Standard: int(2e6),
+		Dev:      int(500e3),
+		Testing:  int(2e3),
+	}).(int)
+)
+
+// Variables related to double spend reporting.
+var (
+	// maxDoubleSpends is the number of double spend attempts that the
+	// transaction pool remembers.
+	maxDoubleSpends = build.Select(build.Var{
+		Standard: 1000,
+		Dev:      100,
+		Testing:  5,
+	}).(int)
 )
 
 // Variables related to propagating transactions through the network.
@@ -80,4 +173,13 @@ var (
 		Dev:      20 * time.Second,
 		Testing:  3 * time.Second,
 	}).(time.Duration)
+
+	// rebroadcastInterval is how often the transaction pool rebroadcasts the
+	// transaction sets that have gone at least one block without being
+	// confirmed, in case they were missed by the peers they were relayed to.
+	rebroadcastInterval = build.Select(build.Var{
+		Standard: 30 * time.Minute,
+		Dev:      2 * time.Minute,
+		Testing:  2 * time.Second,
+	}).(time.Duration)
 )
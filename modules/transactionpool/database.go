@@ -32,6 +32,10 @@ var (
 	// bucketRecentConsensusChange holds the most recent consensus change seen
 	// by the transaction pool.
 	bucketRecentConsensusChange = []byte("RecentConsensusChange")
+
+	// bucketTransactionSets holds the unconfirmed transaction sets that were
+	// in the pool when it was last shut down.
+	bucketTransactionSets = []byte("TransactionSets")
 )
 
 // Explicitly named fields in the database.
@@ -47,6 +51,15 @@ var (
 	// fieldFeeMedian is the fee median persist data stored in a fee median
 	// field.
 	fieldFeeMedian = []byte("FeeMedian")
+
+	// fieldTransactionSets is the field in bucketTransactionSets that holds
+	// the unconfirmed transaction sets.
+	fieldTransactionSets = []byte("TransactionSets")
+
+	// fieldLocalTransactions is the field in bucketTransactionSets that holds
+	// the ids of the unconfirmed transactions that were submitted by this
+	// node's wallet.
+	fieldLocalTransactions = []byte("LocalTransactions")
 )
 
 // Complex objects that get stored in database fields.
@@ -97,6 +110,28 @@ func (tp *TransactionPool) getRecentConsensusChange(tx *bolt.Tx) (cc modules.Con
 	return cc, nil
 }
 
+// getLocalTransactions returns the ids of the local transactions stored in
+// the database.
+func (tp *TransactionPool) getLocalTransactions(tx *bolt.Tx) (ids []types.TransactionID, err error) {
+	idBytes := tx.Bucket(bucketTransactionSets).Get(fieldLocalTransactions)
+	if idBytes == nil {
+		return nil, nil
+	}
+	err = encoding.Unmarshal(idBytes, &ids)
+	return
+}
+
+// getTransactionSets returns the unconfirmed transaction sets stored in the
+// database.
+func (tp *TransactionPool) getTransactionSets(tx *bolt.Tx) (sets [][]types.Transaction, err error) {
+	setBytes := tx.Bucket(bucketTransactionSets).Get(fieldTransactionSets)
+	if setBytes == nil {
+		return nil, nil
+	}
+	err = encoding.Unmarshal(setBytes, &sets)
+	return
+}
+
 // putBlockHeight updates the transaction pool's block height.
 func (tp *TransactionPool) putBlockHeight(tx *bolt.Tx, height types.BlockHeight) error {
 	tp.blockHeight = height
@@ -118,6 +153,18 @@ func (tp *TransactionPool) putRecentConsensusChange(tx *bolt.Tx, cc modules.Cons
 	return tx.Bucket(bucketRecentConsensusChange).Put(fieldRecentConsensusChange, cc[:])
 }
 
+// putTransactionSets replaces the unconfirmed transaction sets stored in the
+// database.
+func (tp *TransactionPool) putTransactionSets(tx *bolt.Tx, sets [][]types.Transaction) error {
+	return tx.Bucket(bucketTransactionSets).Put(fieldTransactionSets, encoding.Marshal(sets))
+}
+
+// putLocalTransactions stores the ids of the local transactions in the
+// database.
+func (tp *TransactionPool) putLocalTransactions(tx *bolt.Tx, ids []types.TransactionID) error {
+	return tx.Bucket(bucketTransactionSets).Put(fieldLocalTransactions, encoding.Marshal(ids))
+}
+
 // putTransaction adds a transaction to the list of confirmed transactions.
 func (tp *TransactionPool) putTransaction(tx *bolt.Tx, id types.TransactionID) error {
 	return tx.Bucket(bucketConfirmedTransactions).Put(id[:], []byte{})
@@ -0,0 +1,51 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// findDoubleSpends returns a DoubleSpend for every object consumed by a
+// transaction in ts that is also consumed by a transaction in pool.
+func findDoubleSpends(pool, ts []types.Transaction) []modules.DoubleSpend {
+	spent := make(map[types.OutputID]types.TransactionID)
+	for _, t := range pool {
+		for _, oid := range t.SpentOutputIDs() {
+			spent[oid] = t.ID()
+		}
+	}
+	var doubleSpends []modules.DoubleSpend
+	for _, t := range ts {
+		for _, oid := range t.SpentOutputIDs() {
+			if poolTxn, exists := spent[oid]; exists && poolTxn != t.ID() {
+				doubleSpends = append(doubleSpends, modules.DoubleSpend{
+					OutputID:        oid,
+					PoolTransaction: poolTxn,
+					Transaction:     t,
+				})
+			}
+		}
+	}
+	return doubleSpends
+}
+
+// addDoubleSpends records double spend attempts, forgetting the oldest ones
+// once maxDoubleSpends is reached.
+func (tp *TransactionPool) addDoubleSpends(doubleSpends []modules.DoubleSpend) {
+	for _, ds := range doubleSpends {
+		tp.log.Debugf("transaction %v double spends %v, which is spent by %v in the pool\n", ds.Transaction.ID(), ds.OutputID, ds.PoolTransaction)
+	}
+	tp.doubleSpends = append(tp.doubleSpends, doubleSpends...)
+	if len(tp.doubleSpends) > maxDoubleSpends {
+		tp.doubleSpends = tp.doubleSpends[len(tp.doubleSpends)-maxDoubleSpends:]
+	}
+}
+
+// DoubleSpends returns the most recent transactions that were rejected for
+// spending an object that is already spent by a transaction in the pool,
+// oldest first.
+func (tp *TransactionPool) DoubleSpends() []modules.DoubleSpend {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return append([]modules.DoubleSpend(nil), tp.doubleSpends...)
+}
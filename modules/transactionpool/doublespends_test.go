@@ -0,0 +1,79 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestDoubleSpends checks that transaction sets spending outputs already
+// spent in the pool are rejected and reported.
+func TestDoubleSpends(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create two outputs that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend := func(fee types.Currency, outputIDs ...types.SiacoinOutputID) []types.Transaction {
+		return []types.Transaction{spendUnlockedOutputs(value, fee, outputIDs...)}
+	}
+
+	original := spend(types.NewCurrency64(1000), ids[0])
+	err = tpt.tpool.AcceptTransactionSet(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A relayed set that spends the output along with another one is
+	// rejected, reported, and not mistaken for an orphan.
+	competing := spend(types.NewCurrency64(1000), ids[0], ids[1])
+	err = tpt.tpool.managedAcceptTransactionSet(competing, originPeer, "")
+	if err != errDoubleSpend {
+		t.Fatalf("expected %v, got %v", errDoubleSpend, err)
+	}
+	doubleSpends := tpt.tpool.DoubleSpends()
+	if len(doubleSpends) != 1 {
+		t.Fatal("expected 1 double spend, got", len(doubleSpends))
+	}
+	ds := doubleSpends[0]
+	if ds.OutputID != types.OutputID(ids[0]) || ds.PoolTransaction != original[0].ID() || ds.Transaction.ID() != competing[0].ID() {
+		t.Error("double spend was not reported correctly:", ds)
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans := len(tpt.tpool.orphans)
+	tpt.tpool.mu.Unlock()
+	if numOrphans != 0 {
+		t.Error("double spend was held as an orphan")
+	}
+
+	// A replacement that does not pay enough additional fees is reported as
+	// well.
+	err = tpt.tpool.AcceptTransactionSet(spend(types.NewCurrency64(2000), ids[0]))
+	if err != errLowFeeBump {
+		t.Fatalf("expected %v, got %v", errLowFeeBump, err)
+	}
+	if len(tpt.tpool.DoubleSpends()) != 2 {
+		t.Fatal("expected 2 double spends, got", len(tpt.tpool.DoubleSpends()))
+	}
+
+	// Only the most recent attempts are remembered.
+	tpt.tpool.mu.Lock()
+	for i := 0; i < maxDoubleSpends; i++ {
+		tpt.tpool.addDoubleSpends([]modules.DoubleSpend{ds})
+	}
+	tpt.tpool.mu.Unlock()
+	if len(tpt.tpool.DoubleSpends()) != maxDoubleSpends {
+		t.Fatal("double spends were not capped:", len(tpt.tpool.DoubleSpends()))
+	}
+}
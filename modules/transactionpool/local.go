@@ -0,0 +1,116 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// local.go tracks the transactions that were submitted by this node's wallet.
+// Local transactions are never evicted to make room for sets paying a higher
+// fee, and are flagged to subscribers so that the miner always includes them
+// in its block templates. They are tracked by transaction id rather than by
+// set id, as sets are merged and split as the pool changes, and the ids are
+// persisted along with the pool's transaction sets.
+
+type (
+	// setOrigin identifies where a transaction set submitted to the pool came
+	// from.
+	setOrigin int
+)
+
+const (
+	// originModule is a set submitted through AcceptTransactionSet, by
+	// another module or the API.
+	originModule setOrigin = iota
+
+	// originLocal is a set submitted by this node's wallet through
+	// AcceptLocalTransactionSet.
+	originLocal
+
+	// originPeer is a set relayed by a peer.
+	originPeer
+)
+
+// markLocal records the transactions of a set as local.
+func (tp *TransactionPool) markLocal(ts []types.Transaction) {
+	for _, txn := range ts {
+		tp.localTransactions[txn.ID()] = struct{}{}
+	}
+}
+
+// isLocalSet returns true if the set contains a local transaction and every
+// other transaction in the set is an ancestor of a local transaction. Such a
+// set cannot be evicted without evicting a local transaction, and must be
+// mined for the local transactions to be mined. Sets are merged when a
+// transaction spends an output of another set, so a set that also holds
+// transactions from elsewhere that do not lead to a local transaction is not
+// local, as the local status would otherwise extend to those transactions.
+func (tp *TransactionPool) isLocalSet(ts []types.Transaction) bool {
+	// Find the transaction that created each object spent within the set.
+	creators := make(map[ObjectID]int)
+	for i, txn := range ts {
+		for j := range txn.SiacoinOutputs {
+			creators[ObjectID(txn.SiacoinOutputID(uint64(j)))] = i
+		}
+		for j := range txn.FileContracts {
+			creators[ObjectID(txn.FileContractID(uint64(j)))] = i
+		}
+		for j := range txn.SiafundOutputs {
+			creators[ObjectID(txn.SiafundOutputID(uint64(j)))] = i
+		}
+	}
+
+	// Walk back from the local transactions to their ancestors.
+	var stack []int
+	needed := make(map[int]struct{})
+	for i, txn := range ts {
+		if _, exists := tp.localTransactions[txn.ID()]; exists {
+			stack = append(stack, i)
+			needed[i] = struct{}{}
+		}
+	}
+	if len(stack) == 0 {
+		return false
+	}
+	for len(stack) > 0 {
+		txn := ts[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		var parents []ObjectID
+		for _, oid := range txn.SpentOutputIDs() {
+			parents = append(parents, ObjectID(oid))
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			parents = append(parents, ObjectID(fcr.ParentID))
+		}
+		for _, oid := range parents {
+			i, exists := creators[oid]
+			if !exists {
+				continue
+			}
+			if _, exists := needed[i]; !exists {
+				needed[i] = struct{}{}
+				stack = append(stack, i)
+			}
+		}
+	}
+	return len(needed) == len(ts)
+}
+
+// pruneLocalTransactions forgets the local transactions that are no longer in
+// the pool, either because they were confirmed or because they became
+// invalid.
+func (tp *TransactionPool) pruneLocalTransactions() {
+	if len(tp.localTransactions) == 0 {
+		return
+	}
+	inPool := make(map[types.TransactionID]struct{})
+	for _, set := range tp.transactionSets {
+		for _, txn := range set {
+			inPool[txn.ID()] = struct{}{}
+		}
+	}
+	for id := range tp.localTransactions {
+		if _, exists := inPool[id]; !exists {
+			delete(tp.localTransactions, id)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package transactionpool
+
+import (
+	"testing"
+
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestLocalTransactionEviction checks that local transaction sets are not
+// evicted to make room for sets paying higher fees, and that they are
+// reported to subscribers as local.
+func TestLocalTransactionEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create several outputs that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend creates a transaction set spending output 'i' with the given
+	// miner fee. All of the sets have the same size.
+	spend := func(i int, fee uint64) []types.Transaction {
+		return []types.Transaction{spendUnlockedOutputs(value, types.NewCurrency64(fee), ids[i])}
+	}
+	inPool := func(ts []types.Transaction) bool {
+		_, _, exists := tpt.tpool.Transaction(ts[0].ID())
+		return exists
+	}
+
+	// Limit the pool to two sets and fill it with a cheap local set and a
+	// more expensive set from another module.
+	tpt.tpool.mu.Lock()
+	tpt.tpool.sizeLimit = 2 * len(encoding.Marshal(spend(0, 2000)))
+	tpt.tpool.mu.Unlock()
+	err = tpt.tpool.AcceptLocalTransactionSet(spend(0, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(spend(1, 2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The local set should be reported to subscribers as local.
+	var local, remote int
+	tpt.tpool.mu.Lock()
+	for _, set := range tpt.tpool.subscriberSets {
+		if set.Local {
+			local++
+		} else {
+			remote++
+		}
+	}
+	tpt.tpool.mu.Unlock()
+	if local != 1 || remote != 1 {
+		t.Fatalf("expected 1 local and 1 remote set, got %v and %v", local, remote)
+	}
+
+	// A more expensive set should evict the remote set rather than the
+	// cheaper local set.
+	err = tpt.tpool.AcceptTransactionSet(spend(2, 3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inPool(spend(0, 1000)) {
+		t.Error("local set was evicted")
+	}
+	if inPool(spend(1, 2000)) {
+		t.Error("remote set was not evicted")
+	}
+
+	// A set paying more than the local set but less than the remote set
+	// cannot make room for itself.
+	err = tpt.tpool.AcceptTransactionSet(spend(3, 2500))
+	if err != errFullTransactionPool {
+		t.Fatalf("expected %v, got %v", errFullTransactionPool, err)
+	}
+
+	// The local transaction should be forgotten once it is confirmed.
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool.mu.Lock()
+	numLocal := len(tpt.tpool.localTransactions)
+	tpt.tpool.mu.Unlock()
+	if numLocal != 0 {
+		t.Errorf("expected no local transactions after mining, got %v", numLocal)
+	}
+}
+
+// TestLocalStatusNotInherited checks that a set is only local if every
+// transaction in it leads to a local transaction, so that transactions from
+// elsewhere cannot gain priority by spending the outputs of a local
+// transaction.
+func TestLocalStatusNotInherited(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create two outputs that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// spend creates a transaction spending 'id'.
+	spend := func(id types.SiacoinOutputID) types.Transaction {
+		return spendUnlockedOutputs(value, types.ZeroCurrency, id)
+	}
+	// isLocal returns whether the set holding 'txn' is local.
+	isLocal := func(txn types.Transaction) bool {
+		tpt.tpool.mu.Lock()
+		defer tpt.tpool.mu.Unlock()
+		for _, set := range tpt.tpool.transactionSets {
+			for _, setTxn := range set {
+				if setTxn.ID() == txn.ID() {
+					return tpt.tpool.isLocalSet(set)
+				}
+			}
+		}
+		t.Fatal("transaction is not in the pool")
+		return false
+	}
+
+	// A transaction from elsewhere that spends a local transaction does not
+	// make its set local.
+	localParent := spend(ids[0])
+	err = tpt.tpool.AcceptLocalTransactionSet([]types.Transaction{localParent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteChild := spend(localParent.SiacoinOutputID(0))
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{remoteChild})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isLocal(remoteChild) {
+		t.Error("set holding a child of a local transaction is local")
+	}
+
+	// A local transaction spending a transaction from elsewhere makes its
+	// set local, as the parent must be mined first.
+	remoteParent := spend(ids[1])
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{remoteParent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	localChild := spend(remoteParent.SiacoinOutputID(0))
+	err = tpt.tpool.AcceptLocalTransactionSet([]types.Transaction{localChild})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isLocal(localChild) {
+		t.Error("set holding a local transaction and its parent is not local")
+	}
+}
@@ -0,0 +1,232 @@
+package transactionpool
+
+import (
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// orphans.go holds transaction sets that spend outputs the transaction pool
+// has not seen yet, typically because their parents are still propagating
+// through the network. Each orphan is indexed by the outputs it is waiting
+// for, and is only retried when one of those outputs is created by a set
+// added to the pool or by a block. Orphans are dropped after maxTxnAge
+// blocks.
+//
+// The value of an orphan's missing inputs is unknown, so the fees that it
+// claims cannot be trusted. Orphans are therefore never compared by fee;
+// they are bounded by count, by total size and per peer, and the oldest
+// orphans are evicted first.
+
+type (
+	// orphanSet is a transaction set that is waiting for its parents.
+	orphanSet struct {
+		set     []types.Transaction
+		height  types.BlockHeight
+		peer    modules.NetAddress
+		size    int
+		missing []ObjectID
+	}
+)
+
+// isMissingParentErr returns true if the error returned when checking a set
+// against the consensus set indicates that the set spends an output that
+// does not exist.
+func isMissingParentErr(err error) bool {
+	return err == modules.ErrMissingSiacoinOutput || err == modules.ErrMissingSiafundOutput
+}
+
+// createdObjectIDs returns the ids of the outputs created by a transaction
+// set that can be spent by an orphan.
+func createdObjectIDs(ts []types.Transaction) []ObjectID {
+	var oids []ObjectID
+	for _, txn := range ts {
+		for i := range txn.SiacoinOutputs {
+			oids = append(oids, ObjectID(txn.SiacoinOutputID(uint64(i))))
+		}
+		for i := range txn.SiafundOutputs {
+			oids = append(oids, ObjectID(txn.SiafundOutputID(uint64(i))))
+		}
+	}
+	return oids
+}
+
+// missingParents returns the ids of the outputs spent by a transaction set
+// that are neither created within the set nor in the pool. Some of them may
+// already exist in the consensus set, but those are never created again, so
+// an orphan is only retried when a parent that it is actually waiting for
+// appears.
+func (tp *TransactionPool) missingParents(ts []types.Transaction) []ObjectID {
+	created := make(map[ObjectID]struct{})
+	for _, oid := range createdObjectIDs(ts) {
+		created[oid] = struct{}{}
+	}
+	var missing []ObjectID
+	add := func(oid ObjectID) {
+		if _, exists := created[oid]; exists {
+			return
+		}
+		if _, exists := tp.knownObjects[oid]; exists {
+			return
+		}
+		created[oid] = struct{}{}
+		missing = append(missing, oid)
+	}
+	for _, txn := range ts {
+		for _, sci := range txn.SiacoinInputs {
+			add(ObjectID(sci.ParentID))
+		}
+		for _, sfi := range txn.SiafundInputs {
+			add(ObjectID(sfi.ParentID))
+		}
+	}
+	return missing
+}
+
+// addOrphan records a transaction set relayed by 'peer' as an orphan. If the
+// peer already has maxOrphanSetsPerPeer orphans, its oldest orphan is
+// evicted, and if the orphans would exceed maxOrphanSets or maxOrphanSize,
+// the oldest orphans of any peer are evicted.
+func (tp *TransactionPool) addOrphan(ts []types.Transaction, peer modules.NetAddress) {
+	id := TransactionSetID(crypto.HashObject(ts))
+	if _, exists := tp.orphans[id]; exists {
+		return
+	}
+	size := len(encoding.Marshal(ts))
+	if size > maxOrphanSize {
+		return
+	}
+	missing := tp.missingParents(ts)
+	if len(missing) == 0 {
+		return
+	}
+	for tp.peerOrphans[peer] >= maxOrphanSetsPerPeer {
+		tp.removeOldestOrphan(peer)
+	}
+	for len(tp.orphans) >= maxOrphanSets || tp.orphansSize+size > maxOrphanSize {
+		tp.removeOrphan(tp.orphanOrder[0])
+	}
+	tp.insertOrphan(id, orphanSet{
+		set:     ts,
+		height:  tp.blockHeight,
+		peer:    peer,
+		size:    size,
+		missing: missing,
+	})
+}
+
+// insertOrphan adds an orphan to the orphan pool and indexes it by its
+// missing parents.
+func (tp *TransactionPool) insertOrphan(id TransactionSetID, orphan orphanSet) {
+	tp.orphans[id] = orphan
+	tp.orphanOrder = append(tp.orphanOrder, id)
+	for _, oid := range orphan.missing {
+		tp.orphanParents[oid] = append(tp.orphanParents[oid], id)
+	}
+	tp.orphansSize += orphan.size
+	tp.peerOrphans[orphan.peer]++
+}
+
+// removeOldestOrphan forgets the oldest orphan relayed by 'peer'.
+func (tp *TransactionPool) removeOldestOrphan(peer modules.NetAddress) {
+	for _, id := range tp.orphanOrder {
+		if tp.orphans[id].peer == peer {
+			tp.removeOrphan(id)
+			return
+		}
+	}
+}
+
+// removeOrphan forgets an orphan.
+func (tp *TransactionPool) removeOrphan(id TransactionSetID) {
+	orphan, exists := tp.orphans[id]
+	if !exists {
+		return
+	}
+	delete(tp.orphans, id)
+	for i := range tp.orphanOrder {
+		if tp.orphanOrder[i] == id {
+			tp.orphanOrder = append(tp.orphanOrder[:i], tp.orphanOrder[i+1:]...)
+			break
+		}
+	}
+	for _, oid := range orphan.missing {
+		waiting := tp.orphanParents[oid]
+		for i := range waiting {
+			if waiting[i] == id {
+				waiting = append(waiting[:i], waiting[i+1:]...)
+				break
+			}
+		}
+		if len(waiting) == 0 {
+			delete(tp.orphanParents, oid)
+		} else {
+			tp.orphanParents[oid] = waiting
+		}
+	}
+	tp.orphansSize -= orphan.size
+	tp.peerOrphans[orphan.peer]--
+	if tp.peerOrphans[orphan.peer] == 0 {
+		delete(tp.peerOrphans, orphan.peer)
+	}
+}
+
+// pruneOrphans drops the orphans that have waited more than maxTxnAge blocks
+// for their parents.
+func (tp *TransactionPool) pruneOrphans() {
+	for _, id := range append([]TransactionSetID(nil), tp.orphanOrder...) {
+		if tp.blockHeight-tp.orphans[id].height > maxTxnAge {
+			tp.removeOrphan(id)
+		}
+	}
+}
+
+// retryOrphans tries to add the orphans waiting for any of the provided
+// outputs to the pool. The outputs created by an accepted orphan are checked
+// in turn, so that chains of orphans are resolved. The accepted sets are
+// returned so that they can be relayed.
+func (tp *TransactionPool) retryOrphans(created []ObjectID, txnFn func([]types.Transaction) (modules.ConsensusChange, error)) [][]types.Transaction {
+	var accepted [][]types.Transaction
+	for len(created) > 0 && len(tp.orphans) > 0 {
+		oid := created[0]
+		created = created[1:]
+		for _, id := range append([]TransactionSetID(nil), tp.orphanParents[oid]...) {
+			orphan, exists := tp.orphans[id]
+			if !exists {
+				continue
+			}
+			tp.removeOrphan(id)
+			err := tp.acceptTransactionSet(orphan.set, txnFn)
+			if err == nil {
+				accepted = append(accepted, orphan.set)
+				created = append(created, createdObjectIDs(orphan.set)...)
+			} else if err == errMissingParents {
+				// The set is still missing a parent. It keeps its original
+				// height so that it still expires.
+				orphan.missing = tp.missingParents(orphan.set)
+				if len(orphan.missing) > 0 {
+					tp.insertOrphan(id, orphan)
+				}
+			}
+		}
+	}
+	return accepted
+}
+
+// appliedObjectIDs returns the ids of the outputs created by the blocks
+// applied in a consensus change.
+func appliedObjectIDs(cc modules.ConsensusChange) []ObjectID {
+	var oids []ObjectID
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			oids = append(oids, ObjectID(diff.ID))
+		}
+	}
+	for _, diff := range cc.SiafundOutputDiffs {
+		if diff.Direction == modules.DiffApply {
+			oids = append(oids, ObjectID(diff.ID))
+		}
+	}
+	return oids
+}
@@ -0,0 +1,149 @@
+package transactionpool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/modules"
+	"github.com/NebulousLabs/Sia/types"
+)
+
+// TestOrphans checks that a relayed transaction set that arrives before its
+// parent is held as an orphan and added to the pool once the parent arrives.
+func TestOrphans(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create an output that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(10)
+	ids, err := tpt.fundUnlockedOutputs(value, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := spendUnlockedOutputs(value, types.ZeroCurrency, ids[0])
+	child := spendUnlockedOutputs(value, types.ZeroCurrency, parent.SiacoinOutputID(0))
+
+	// A child submitted locally is rejected outright.
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{child})
+	if err != errMissingParents {
+		t.Fatalf("expected %v, got %v", errMissingParents, err)
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans := len(tpt.tpool.orphans)
+	tpt.tpool.mu.Unlock()
+	if numOrphans != 0 {
+		t.Fatal("expected 0 orphans, got", numOrphans)
+	}
+
+	// A child relayed by a peer is rejected, but held as an orphan.
+	err = tpt.tpool.managedAcceptTransactionSet([]types.Transaction{child}, originPeer, "")
+	if err != errMissingParents {
+		t.Fatalf("expected %v, got %v", errMissingParents, err)
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans = len(tpt.tpool.orphans)
+	tpt.tpool.mu.Unlock()
+	if numOrphans != 1 {
+		t.Fatal("expected 1 orphan, got", numOrphans)
+	}
+
+	// Once the parent arrives, the child is added to the pool.
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, exists := tpt.tpool.Transaction(child.ID()); !exists {
+		t.Fatal("orphan was not added to the pool")
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans = len(tpt.tpool.orphans)
+	tpt.tpool.mu.Unlock()
+	if numOrphans != 0 {
+		t.Fatal("expected 0 orphans, got", numOrphans)
+	}
+}
+
+// TestOrphansBounded checks that the number of orphans is capped, both in
+// total and per peer, that the oldest orphans are evicted first, and that
+// orphans expire.
+func TestOrphansBounded(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// orphan returns a set spending an output that does not exist.
+	orphan := func(i int) []types.Transaction {
+		return []types.Transaction{{
+			SiacoinInputs: []types.SiacoinInput{{
+				ParentID: types.SiacoinOutputID(crypto.HashObject(i)),
+			}},
+			SiacoinOutputs: []types.SiacoinOutput{{
+				Value: types.SiacoinPrecision,
+			}},
+		}}
+	}
+
+	// A single peer cannot hold more than maxOrphanSetsPerPeer orphans.
+	for i := 0; i < maxOrphanSetsPerPeer+1; i++ {
+		err = tpt.tpool.managedAcceptTransactionSet(orphan(-i-1), originPeer, "flood:1")
+		if err != errMissingParents {
+			t.Fatalf("expected %v, got %v", errMissingParents, err)
+		}
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans := len(tpt.tpool.orphans)
+	_, exists := tpt.tpool.orphans[TransactionSetID(crypto.HashObject(orphan(-1)))]
+	tpt.tpool.mu.Unlock()
+	if numOrphans != maxOrphanSetsPerPeer || exists {
+		t.Fatal("orphans of a single peer were not capped:", numOrphans, exists)
+	}
+
+	// Submit sets from different peers.
+	var sets [][]types.Transaction
+	for i := 0; i < maxOrphanSets+2; i++ {
+		set := orphan(i)
+		sets = append(sets, set)
+		err = tpt.tpool.managedAcceptTransactionSet(set, originPeer, modules.NetAddress(fmt.Sprintf("peer%v:1", i)))
+		if err != errMissingParents {
+			t.Fatalf("expected %v, got %v", errMissingParents, err)
+		}
+	}
+	tpt.tpool.mu.Lock()
+	if len(tpt.tpool.orphans) != maxOrphanSets || len(tpt.tpool.orphanOrder) != maxOrphanSets {
+		t.Error("orphans were not capped:", len(tpt.tpool.orphans), len(tpt.tpool.orphanOrder))
+	}
+	for i, set := range sets {
+		_, exists := tpt.tpool.orphans[TransactionSetID(crypto.HashObject(set))]
+		if exists != (i >= 2) {
+			t.Error("wrong orphan evicted:", i, exists)
+		}
+	}
+	tpt.tpool.mu.Unlock()
+
+	// The orphans expire after maxTxnAge blocks.
+	for i := types.BlockHeight(0); i <= maxTxnAge; i++ {
+		_, err = tpt.miner.AddBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tpt.tpool.mu.Lock()
+	numOrphans = len(tpt.tpool.orphans)
+	numParents := len(tpt.tpool.orphanParents)
+	tpt.tpool.mu.Unlock()
+	if numOrphans != 0 || numParents != 0 {
+		t.Fatal("orphans did not expire:", numOrphans, numParents)
+	}
+}
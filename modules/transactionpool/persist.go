@@ -122,6 +122,16 @@ func (tp *TransactionPool) initPersist() error {
 			tp.log.Println("Unable to close transaction properly during shutdown:", err)
 		}
 	})
+	// Save the unconfirmed transaction sets before the global tx is
+	// committed, so that they can be restored on startup.
+	tp.tg.AfterStop(func() {
+		tp.mu.Lock()
+		defer tp.mu.Unlock()
+		err := tp.saveTransactionSets()
+		if err != nil {
+			tp.log.Println("Unable to save the unconfirmed transaction sets:", err)
+		}
+	})
 	// Spin up the thread that occasionally syncrhonizes the database.
 	go tp.threadedRegularSync()
 
@@ -133,6 +143,7 @@ func (tp *TransactionPool) initPersist() error {
 		bucketRecentConsensusChange,
 		bucketConfirmedTransactions,
 		bucketFeeMedian,
+		bucketTransactionSets,
 	}
 	for _, bucket := range buckets {
 		_, err := tp.dbTx.CreateBucketIfNotExists(bucket)
@@ -207,6 +218,69 @@ func (tp *TransactionPool) initPersist() error {
 	return nil
 }
 
+// saveTransactionSets writes the unconfirmed transaction sets in the pool to
+// the database, along with the ids of the local transactions. If persistence
+// has been turned off through SetSettings, no sets are saved.
+func (tp *TransactionPool) saveTransactionSets() error {
+	var sets [][]types.Transaction
+	var ids []types.TransactionID
+	if tp.persistSets {
+		for _, set := range tp.transactionSets {
+			sets = append(sets, set)
+		}
+		for id := range tp.localTransactions {
+			ids = append(ids, id)
+		}
+	}
+	err := tp.putTransactionSets(tp.dbTx, sets)
+	if err != nil {
+		return err
+	}
+	return tp.putLocalTransactions(tp.dbTx, ids)
+}
+
+// managedLoadTransactionSets resubmits the transaction sets that were saved
+// when the pool was last shut down. The sets are validated again against the
+// current consensus set, and sets that are no longer valid are dropped. The
+// saved local transactions are marked as local before the sets are
+// resubmitted, so that they keep their priority. The saved sets are cleared
+// whether or not they can be decoded, so that a corrupt entry is only
+// reported once and never stops the pool from starting.
+func (tp *TransactionPool) managedLoadTransactionSets() {
+	tp.mu.Lock()
+	sets, err := tp.getTransactionSets(tp.dbTx)
+	if err != nil {
+		tp.log.Println("Dropping the saved transaction sets, which could not be loaded:", err)
+		sets = nil
+	}
+	localIDs, err := tp.getLocalTransactions(tp.dbTx)
+	if err != nil {
+		tp.log.Println("Dropping the saved local transactions, which could not be loaded:", err)
+		localIDs = nil
+	}
+	err = tp.putTransactionSets(tp.dbTx, nil)
+	if err == nil {
+		err = tp.putLocalTransactions(tp.dbTx, nil)
+	}
+	if err != nil {
+		tp.log.Println("Unable to clear the saved transaction sets:", err)
+	}
+	for _, id := range localIDs {
+		tp.localTransactions[id] = struct{}{}
+	}
+	tp.mu.Unlock()
+
+	for _, set := range sets {
+		err := tp.AcceptTransactionSet(set)
+		if err != nil {
+			tp.log.Debugln("Dropping saved transaction set:", err)
+		}
+	}
+	tp.mu.Lock()
+	tp.pruneLocalTransactions()
+	tp.mu.Unlock()
+}
+
 // transactionConfirmed returns true if the transaction has been confirmed on
 // the blockchain and false if the transaction has not been confirmed on the
 // blockchain.
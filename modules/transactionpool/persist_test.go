@@ -93,3 +93,145 @@ func TestRescan(t *testing.T) {
 		t.Fatal("expecting modules.ErrDuplicateTransactionSet, got:", err)
 	}
 }
+
+// TestTransactionSetPersistence checks that unconfirmed transaction sets
+// survive a restart of the transaction pool, and that sets which became
+// invalid while the pool was offline are dropped.
+func TestTransactionSetPersistence(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	txns, err := tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the tpool. The set should be back in the pool.
+	persistDir := tpt.tpool.persistDir
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range txns {
+		if _, _, exists := tpt.tpool.Transaction(txn.ID()); !exists {
+			t.Fatal("transaction was not restored after a restart")
+		}
+	}
+	// The wallet's transactions keep their local status.
+	tpt.tpool.mu.Lock()
+	_, local := tpt.tpool.localTransactions[txns[len(txns)-1].ID()]
+	tpt.tpool.mu.Unlock()
+	if !local {
+		t.Fatal("local transaction lost its local status after a restart")
+	}
+
+	// Close the tpool and mine the set into a block while it is offline. The
+	// set should not be restored, as it is now confirmed.
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tpt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mined := false
+	for _, bt := range b.Transactions {
+		if bt.ID() == txns[len(txns)-1].ID() {
+			mined = true
+		}
+	}
+	if !mined {
+		t.Fatal("transaction set was not mined")
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("confirmed transactions were restored to the pool")
+	}
+}
+
+// TestTransactionSetPersistenceOptional checks that the transaction sets are
+// not restored after a restart if persistence is turned off, and that saved
+// sets which cannot be decoded are dropped instead of stopping the pool from
+// starting.
+func TestTransactionSetPersistenceOptional(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	txns, err := tpt.wallet.SendSiacoins(types.NewCurrency64(100), types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Turn off persistence and restart the tpool. The set should be gone.
+	settings := tpt.tpool.Settings()
+	if !settings.PersistTransactionSets {
+		t.Fatal("persistence should be on by default")
+	}
+	settings.PersistTransactionSets = false
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	persistDir := tpt.tpool.persistDir
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txn := range txns {
+		if _, _, exists := tpt.tpool.Transaction(txn.ID()); exists {
+			t.Fatal("transaction was restored with persistence turned off")
+		}
+	}
+
+	// Corrupt the saved sets while the tpool is offline. The tpool should
+	// still start, with an empty pool.
+	err = tpt.tpool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := persist.OpenDatabase(dbMetadata, filepath.Join(persistDir, dbFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketTransactionSets).Put(fieldTransactionSets, []byte{1, 2, 3})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tpt.tpool, err = New(tpt.cs, tpt.gateway, persistDir)
+	if err != nil {
+		t.Fatal("corrupt saved sets stopped the tpool from starting:", err)
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Fatal("pool should be empty after dropping corrupt saved sets")
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 // standard.go adds extra rules to transactions which help preserve network
 // health and provides flexibility for future soft forks and tweaks to the
-// network.
+// network. The limits used by the rules can be changed through SetSettings.
 //
 // Rule: Transaction size is limited
 //		There is a DoS vector where large transactions can both contain many
@@ -27,38 +27,171 @@ import (
 //		signatures might actually be invalid. This rule protects legacy miners
 //		from including potentially invalid transactions in their blocks.
 //
+// Rule: Unlock conditions must be satisfiable and free of duplicate keys
+//		Consensus accepts unlock conditions that list the same public key more
+//		than once, letting a single key holder provide several of the required
+//		signatures, and conditions that require more signatures than there are
+//		keys. Neither is useful, and both are easy to create by mistake, so
+//		the transaction pool refuses to relay transactions spending them.
+//		Unlock conditions requiring zero signatures are still allowed, as they
+//		are the standard way to create outputs that anyone can spend.
+//
+// Rule: Inputs cannot require too many signatures
+//		Every signature must be verified by every node. Unlock conditions
+//		requiring more than MaxSignaturesPerInput signatures, by default, are
+//		rejected.
+//
+// Rule: Dust outputs are rejected
+//		Siacoin outputs worth less than modules.DustThreshold cost more in
+//		fees to spend than they are worth, so they are unlikely to ever be
+//		spent and stay in the consensus set forever. Wallets give change
+//		below the threshold to the miners instead.
+//
+// Rule: File contracts cannot lock up funds indefinitely
+//		Consensus only requires that a file contract's proof window starts
+//		after the current height. A contract whose window starts or ends
+//		absurdly far in the future keeps its funds locked and must be tracked
+//		by every node for that long. The transaction pool rejects contracts and
+//		revisions whose window starts more than MaxContractStartDelay blocks
+//		or ends more than MaxContractDuration blocks after the current height.
+//
 // Rule: The types of allowed arbitrary data are limited
 //		The arbitrary data field can be used to orchestrate soft-forks to Sia
 //		that add features. Legacy miners are at risk of creating invalid blocks
 //		if they include arbitrary data which has meanings that the legacy miner
 //		doesn't understand.
 //
+// Rule: The amount of arbitrary data is limited
+//		Arbitrary data is stored by every node forever, but is only needed for
+//		small pieces of metadata such as host announcements. The transaction
+//		pool rejects transactions carrying more than MaxArbitraryDataSize bytes
+//		of arbitrary data in total, by default.
+//
 // Rule: The transaction set size is limited.
-//		A group of dependent transactions cannot exceed 100kb to limit how
-//		quickly the transaction pool can be filled with new transactions.
+//		A group of dependent transactions cannot exceed
+//		modules.TransactionSetSizeLimit bytes by default, to limit how quickly
+//		the transaction pool can be filled with new transactions.
+
+// standardLimits are the limits applied by the standardness rules.
+type standardLimits struct {
+	maxTransactionSize    int
+	maxTransactionSetSize int
+	maxSignaturesPerInput uint64
+	maxArbitraryDataSize  int
+}
+
+// defaultStandardLimits are the limits that the transaction pool starts with.
+var defaultStandardLimits = standardLimits{
+	maxTransactionSize:    modules.TransactionSizeLimit,
+	maxTransactionSetSize: modules.TransactionSetSizeLimit,
+	maxSignaturesPerInput: MaxSignaturesPerInput,
+	maxArbitraryDataSize:  MaxArbitraryDataSize,
+}
+
+var (
+	errContractStartTooLate    = errors.New("file contract proof window starts too far in the future")
+	errContractTooLong         = errors.New("file contract proof window ends too far in the future")
+	errDuplicatePublicKey      = errors.New("unlock conditions contain the same public key more than once")
+	errDustOutput              = errors.New("transaction creates a siacoin output below the dust threshold")
+	errLargeArbitraryData      = errors.New("transaction contains too much arbitrary data")
+	errTooManySignatures       = errors.New("unlock conditions require too many signatures")
+	errUnrecognizedKey         = errors.New("unrecognized key type in transaction")
+	errUnsatisfiableConditions = errors.New("unlock conditions require more signatures than there are public keys")
+)
+
+// isNonStandardErr returns true if the error is returned for a transaction
+// that breaks one of the standardness rules.
+func isNonStandardErr(err error) bool {
+	switch err {
+	case errContractStartTooLate, errContractTooLong, errDuplicatePublicKey,
+		errDustOutput, errLargeArbitraryData, errTooManySignatures,
+		errUnrecognizedKey, errUnsatisfiableConditions,
+		modules.ErrLargeTransaction, modules.ErrLargeTransactionSet,
+		modules.ErrInvalidArbPrefix:
+		return true
+	}
+	return false
+}
 
 // checkUnlockConditions looks at the UnlockConditions and verifies that all
 // public keys are recognized. Unrecognized public keys are automatically
 // accepted as valid by the consnensus set, but rejected by the transaction
 // pool. This allows new types of keys to be added via a softfork without
-// alienating all of the older nodes.
-func checkUnlockConditions(uc types.UnlockConditions) error {
+// alienating all of the older nodes. The public keys must also be unique, and
+// there must be at least as many keys as required signatures, and no more than
+// 'maxSignatures' signatures may be required.
+func checkUnlockConditions(uc types.UnlockConditions, maxSignatures uint64) error {
 	for _, pk := range uc.PublicKeys {
 		if pk.Algorithm != types.SignatureEntropy &&
 			pk.Algorithm != types.SignatureEd25519 {
-			return errors.New("unrecognized key type in transaction")
+			return errUnrecognizedKey
+		}
+	}
+	if uc.SignaturesRequired > uint64(len(uc.PublicKeys)) {
+		return errUnsatisfiableConditions
+	}
+	if uc.SignaturesRequired > maxSignatures {
+		return errTooManySignatures
+	}
+	seen := make(map[string]struct{}, len(uc.PublicKeys))
+	for _, pk := range uc.PublicKeys {
+		key := pk.String()
+		if _, exists := seen[key]; exists {
+			return errDuplicatePublicKey
 		}
+		seen[key] = struct{}{}
 	}
 
 	return nil
 }
 
+// checkDustOutputs checks that none of the siacoin outputs of a transaction
+// are worth less than the dust threshold.
+func checkDustOutputs(t types.Transaction, threshold types.Currency) error {
+	for _, sco := range t.SiacoinOutputs {
+		if sco.Value.Cmp(threshold) < 0 {
+			return errDustOutput
+		}
+	}
+	return nil
+}
+
+// checkContractWindow checks that a file contract proof window does not start
+// or end too far after the current height.
+func checkContractWindow(windowStart, windowEnd, height types.BlockHeight) error {
+	if windowStart > height+MaxContractStartDelay {
+		return errContractStartTooLate
+	}
+	if windowEnd > height+MaxContractDuration {
+		return errContractTooLong
+	}
+	return nil
+}
+
+// checkContractWindows checks the proof windows of all of the file contracts
+// and file contract revisions in a transaction against the current height.
+func checkContractWindows(t types.Transaction, height types.BlockHeight) error {
+	for _, fc := range t.FileContracts {
+		err := checkContractWindow(fc.WindowStart, fc.WindowEnd, height)
+		if err != nil {
+			return err
+		}
+	}
+	for _, fcr := range t.FileContractRevisions {
+		err := checkContractWindow(fcr.NewWindowStart, fcr.NewWindowEnd, height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // isStandardTransaction enforces extra rules such as a transaction size limit.
 // These rules can be altered without disrupting consensus.
 //
 // The size of the transaction is returned so that the transaction does not need
 // to be encoded multiple times.
-func isStandardTransaction(t types.Transaction) (uint64, error) {
+func isStandardTransaction(t types.Transaction, limits standardLimits) (uint64, error) {
 	// Check that the size of the transaction does not exceed the standard
 	// established in Standard.md. Larger transactions are a DOS vector,
 	// because someone can fill a large transaction with a bunch of signatures
@@ -67,7 +200,7 @@ func isStandardTransaction(t types.Transaction) (uint64, error) {
 	// more difficult for attackers to exploid this DOS vector, though a miner
 	// with sufficient power could still create unfriendly blocks.
 	tlen := len(encoding.Marshal(t))
-	if tlen > modules.TransactionSizeLimit {
+	if tlen > limits.maxTransactionSize {
 		return 0, modules.ErrLargeTransaction
 	}
 
@@ -77,19 +210,19 @@ func isStandardTransaction(t types.Transaction) (uint64, error) {
 	// may make certain unrecognized signatures invalid, and this node cannot
 	// tell which signatures are the invalid ones.
 	for _, sci := range t.SiacoinInputs {
-		err := checkUnlockConditions(sci.UnlockConditions)
+		err := checkUnlockConditions(sci.UnlockConditions, limits.maxSignaturesPerInput)
 		if err != nil {
 			return 0, err
 		}
 	}
 	for _, fcr := range t.FileContractRevisions {
-		err := checkUnlockConditions(fcr.UnlockConditions)
+		err := checkUnlockConditions(fcr.UnlockConditions, limits.maxSignaturesPerInput)
 		if err != nil {
 			return 0, err
 		}
 	}
 	for _, sfi := range t.SiafundInputs {
-		err := checkUnlockConditions(sfi.UnlockConditions)
+		err := checkUnlockConditions(sfi.UnlockConditions, limits.maxSignaturesPerInput)
 		if err != nil {
 			return 0, err
 		}
@@ -99,9 +232,16 @@ func isStandardTransaction(t types.Transaction) (uint64, error) {
 	// prefixes. The allowed prefixes include a 'NonSia' prefix for truly
 	// arbitrary data. Blocking all other prefixes allows arbitrary data to be
 	// used to orchestrate more complicated soft forks in the future without
-	// putting older nodes at risk of violating the new rules.
+	// putting older nodes at risk of violating the new rules. The total
+	// amount of arbitrary data is also capped.
 	var prefix types.Specifier
+	var arbSize int
 	for _, arb := range t.ArbitraryData {
+		arbSize += len(arb)
+		if arbSize > limits.maxArbitraryDataSize {
+			return 0, errLargeArbitraryData
+		}
+
 		// Check for a whilelisted prefix.
 		copy(prefix[:], arb)
 		if prefix == modules.PrefixHostAnnouncement ||
@@ -120,17 +260,17 @@ func isStandardTransaction(t types.Transaction) (uint64, error) {
 //
 // The size of the transaction set is returned so that the encoding only needs
 // to happen once.
-func isStandardTransactionSet(ts []types.Transaction) (uint64, error) {
+func isStandardTransactionSet(ts []types.Transaction, limits standardLimits) (uint64, error) {
 	// Check that each transaction is acceptable, while also making sure that
 	// the size of the whole set is legal.
 	var totalSize uint64
 	for i := range ts {
-		tSize, err := isStandardTransaction(ts[i])
+		tSize, err := isStandardTransaction(ts[i], limits)
 		if err != nil {
 			return 0, err
 		}
 		totalSize += tSize
-		if totalSize > modules.TransactionSetSizeLimit {
+		if totalSize > uint64(limits.maxTransactionSetSize) {
 			return 0, modules.ErrLargeTransactionSet
 		}
 
@@ -45,3 +45,223 @@ func TestIntegrationLargeTransactions(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestCheckUnlockConditions checks that unlock conditions with duplicate public
+// keys or more required signatures than keys are rejected as non-standard.
+func TestCheckUnlockConditions(t *testing.T) {
+	pk1 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(32)}
+	pk2 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(32)}
+	tests := []struct {
+		uc  types.UnlockConditions
+		err error
+	}{
+		{types.UnlockConditions{}, nil},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1}}, nil},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1}, SignaturesRequired: 1}, nil},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, pk2}, SignaturesRequired: 2}, nil},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, pk1}, SignaturesRequired: 2}, errDuplicatePublicKey},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, pk2, pk1}, SignaturesRequired: 1}, errDuplicatePublicKey},
+		{types.UnlockConditions{SignaturesRequired: 1}, errUnsatisfiableConditions},
+		{types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, pk2}, SignaturesRequired: 3}, errUnsatisfiableConditions},
+	}
+	for i, test := range tests {
+		if err := checkUnlockConditions(test.uc, MaxSignaturesPerInput); err != test.err {
+			t.Errorf("test %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+
+	// Conditions may not require more than MaxSignaturesPerInput signatures.
+	var keys []types.SiaPublicKey
+	for i := 0; i <= MaxSignaturesPerInput; i++ {
+		keys = append(keys, types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(32)})
+	}
+	uc := types.UnlockConditions{PublicKeys: keys, SignaturesRequired: MaxSignaturesPerInput}
+	if err := checkUnlockConditions(uc, MaxSignaturesPerInput); err != nil {
+		t.Error(err)
+	}
+	uc.SignaturesRequired++
+	if err := checkUnlockConditions(uc, MaxSignaturesPerInput); err != errTooManySignatures {
+		t.Errorf("expected %v, got %v", errTooManySignatures, err)
+	}
+
+	// Keys with the same bytes but different algorithms are not duplicates.
+	entropy := types.SiaPublicKey{Algorithm: types.SignatureEntropy, Key: pk1.Key}
+	uc = types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, entropy}, SignaturesRequired: 1}
+	if err := checkUnlockConditions(uc, MaxSignaturesPerInput); err != nil {
+		t.Error(err)
+	}
+
+	// The rules apply to every kind of input.
+	dup := types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk1, pk1}, SignaturesRequired: 2}
+	txns := []types.Transaction{
+		{SiacoinInputs: []types.SiacoinInput{{UnlockConditions: dup}}},
+		{FileContractRevisions: []types.FileContractRevision{{UnlockConditions: dup}}},
+		{SiafundInputs: []types.SiafundInput{{UnlockConditions: dup}}},
+	}
+	for i, txn := range txns {
+		if _, err := isStandardTransaction(txn, defaultStandardLimits); err != errDuplicatePublicKey {
+			t.Errorf("transaction %v: expected %v, got %v", i, errDuplicatePublicKey, err)
+		}
+	}
+}
+
+// TestCheckContractWindows checks that file contracts and revisions whose
+// proof windows start or end too far in the future are rejected.
+func TestCheckContractWindows(t *testing.T) {
+	height := types.BlockHeight(1000)
+	tests := []struct {
+		start, end types.BlockHeight
+		err        error
+	}{
+		{height + 1, height + 2, nil},
+		{height + MaxContractStartDelay, height + MaxContractDuration, nil},
+		{height + MaxContractStartDelay + 1, height + MaxContractDuration, errContractStartTooLate},
+		{height + 10, height + MaxContractDuration + 1, errContractTooLong},
+	}
+	for i, test := range tests {
+		txn := types.Transaction{
+			FileContracts: []types.FileContract{{WindowStart: test.start, WindowEnd: test.end}},
+		}
+		if err := checkContractWindows(txn, height); err != test.err {
+			t.Errorf("contract %v: expected %v, got %v", i, test.err, err)
+		}
+		txn = types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{NewWindowStart: test.start, NewWindowEnd: test.end}},
+		}
+		if err := checkContractWindows(txn, height); err != test.err {
+			t.Errorf("revision %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestCheckDustOutputs checks that siacoin outputs below the dust threshold
+// are rejected.
+func TestCheckDustOutputs(t *testing.T) {
+	threshold := types.NewCurrency64(1000)
+	tests := []struct {
+		value types.Currency
+		err   error
+	}{
+		{types.NewCurrency64(999), errDustOutput},
+		{threshold, nil},
+		{types.SiacoinPrecision, nil},
+	}
+	for i, test := range tests {
+		txn := types.Transaction{
+			SiacoinOutputs: []types.SiacoinOutput{{Value: types.SiacoinPrecision}, {Value: test.value}},
+		}
+		if err := checkDustOutputs(txn, threshold); err != test.err {
+			t.Errorf("test %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestLargeArbitraryData checks that transactions carrying more than
+// MaxArbitraryDataSize bytes of arbitrary data are rejected.
+func TestLargeArbitraryData(t *testing.T) {
+	arb := func(n int) []byte {
+		return append(modules.PrefixNonSia[:], make([]byte, n-len(modules.PrefixNonSia))...)
+	}
+	tests := []struct {
+		data [][]byte
+		err  error
+	}{
+		{[][]byte{arb(MaxArbitraryDataSize)}, nil},
+		{[][]byte{arb(MaxArbitraryDataSize / 2), arb(MaxArbitraryDataSize / 2)}, nil},
+		{[][]byte{arb(MaxArbitraryDataSize + 1)}, errLargeArbitraryData},
+		{[][]byte{arb(MaxArbitraryDataSize / 2), arb(MaxArbitraryDataSize/2 + 1)}, errLargeArbitraryData},
+	}
+	for i, test := range tests {
+		txn := types.Transaction{ArbitraryData: test.data}
+		if _, err := isStandardTransaction(txn, defaultStandardLimits); err != test.err {
+			t.Errorf("test %v: expected %v, got %v", i, test.err, err)
+		}
+	}
+}
+
+// TestStandardSettings checks that the limits of the standardness rules can
+// be changed through SetSettings.
+func TestStandardSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// dataSet returns a set of 'n' transactions that each carry 'size' bytes
+	// of arbitrary data.
+	dataSet := func(n, size int) []types.Transaction {
+		var ts []types.Transaction
+		for i := 0; i < n; i++ {
+			arbData := make([]byte, size)
+			copy(arbData, modules.PrefixNonSia[:])
+			fastrand.Read(arbData[len(modules.PrefixNonSia):])
+			ts = append(ts, types.Transaction{ArbitraryData: [][]byte{arbData}})
+		}
+		return ts
+	}
+
+	settings := tpt.tpool.Settings()
+	if settings.MaxSignaturesPerInput != MaxSignaturesPerInput || settings.MaxArbitraryDataSize != MaxArbitraryDataSize ||
+		settings.MaxTransactionSize != modules.TransactionSizeLimit || settings.MaxTransactionSetSize != modules.TransactionSetSizeLimit {
+		t.Fatal("pool did not start with the default limits:", settings)
+	}
+
+	// Lower the arbitrary data limit.
+	settings.MaxArbitraryDataSize = 100
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(dataSet(1, 200))
+	if err != errLargeArbitraryData {
+		t.Fatalf("expected %v, got %v", errLargeArbitraryData, err)
+	}
+
+	// Lower the transaction and set size limits.
+	settings.MaxArbitraryDataSize = MaxArbitraryDataSize
+	settings.MaxTransactionSize = 500
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(dataSet(1, 1000))
+	if err != modules.ErrLargeTransaction {
+		t.Fatalf("expected %v, got %v", modules.ErrLargeTransaction, err)
+	}
+	settings.MaxTransactionSetSize = 1000
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(dataSet(3, 400))
+	if err != modules.ErrLargeTransactionSet {
+		t.Fatalf("expected %v, got %v", modules.ErrLargeTransactionSet, err)
+	}
+
+	// Lower the signature limit.
+	settings.MaxSignaturesPerInput = 1
+	err = tpt.tpool.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := func() types.SiaPublicKey {
+		return types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(32)}
+	}
+	uc := types.UnlockConditions{PublicKeys: []types.SiaPublicKey{pk(), pk()}, SignaturesRequired: 2}
+	txn := types.Transaction{SiacoinInputs: []types.SiacoinInput{{UnlockConditions: uc}}}
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txn})
+	if err != errTooManySignatures {
+		t.Fatalf("expected %v, got %v", errTooManySignatures, err)
+	}
+
+	// Sizes must be positive.
+	settings.MaxTransactionSize = 0
+	err = tpt.tpool.SetSettings(settings)
+	if err != errInvalidTransactionSize {
+		t.Fatalf("expected %v, got %v", errInvalidTransactionSize, err)
+	}
+}
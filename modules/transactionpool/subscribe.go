@@ -53,6 +53,8 @@ func (tp *TransactionPool) updateSubscribersTransactions() {
 			IDs:          ids,
 			Sizes:        sizes,
 			Transactions: set,
+
+			Local: tp.isLocalSet(set),
 		}
 		// Add this diff to our set of subscriber diffs.
 		tp.subscriberSets[id] = ut
@@ -2,6 +2,8 @@ package transactionpool
 
 import (
 	"errors"
+	"sort"
+	"time"
 
 	"github.com/NebulousLabs/bolt"
 	"github.com/NebulousLabs/demotemutex"
@@ -16,6 +18,9 @@ import (
 var (
 	errNilCS      = errors.New("transaction pool cannot initialize with a nil consensus set")
 	errNilGateway = errors.New("transaction pool cannot initialize with a nil gateway")
+
+	errInvalidPoolSize        = errors.New("maximum transaction pool size must be positive")
+	errInvalidTransactionSize = errors.New("maximum transaction and transaction set sizes must be positive")
 )
 
 type (
@@ -52,6 +57,59 @@ type (
 		transactionSetDiffs map[TransactionSetID]*modules.ConsensusChange
 		transactionListSize int
 
+		// transactionSetSizes holds the encoded size of each transaction set
+		// in the pool, so that sets do not need to be encoded again when
+		// making room for a new set.
+		transactionSetSizes map[TransactionSetID]int
+
+		// addressSets indexes the transaction sets in the pool by the
+		// addresses that they send to or spend from.
+		addressSets map[types.UnlockHash]map[TransactionSetID]struct{}
+
+		// localTransactions holds the ids of the transactions in the pool
+		// that were submitted by this node's wallet.
+		localTransactions map[types.TransactionID]struct{}
+
+		// sizeLimit is the maximum value of transactionListSize. It is set to
+		// TransactionPoolSizeLimit, and can be changed through SetSettings.
+		sizeLimit int
+
+		// orphans holds the transaction sets that spend outputs which are
+		// not yet known to the pool, in the order that they were received.
+		// orphanParents maps each missing output to the orphans waiting for
+		// it, and peerOrphans counts the orphans relayed by each peer.
+		orphans       map[TransactionSetID]orphanSet
+		orphanOrder   []TransactionSetID
+		orphanParents map[ObjectID][]TransactionSetID
+		orphansSize   int
+		peerOrphans   map[modules.NetAddress]int
+
+		// doubleSpends holds the most recent transactions that were rejected
+		// for spending an object already spent in the pool, oldest first.
+		doubleSpends []modules.DoubleSpend
+
+		// metrics counts the transaction sets submitted to the pool.
+		metrics modules.TransactionPoolMetrics
+
+		// minRelayFee is the minimum fee per byte required to enter the pool.
+		// It is set to MinRelayFee, and can be changed through SetSettings.
+		minRelayFee types.Currency
+
+		// dustThreshold is the smallest siacoin output allowed in the pool.
+		// It is set to modules.DustThreshold, and can be changed through
+		// SetSettings.
+		dustThreshold types.Currency
+
+		// limits are the limits applied by the standardness rules. They are
+		// set to defaultStandardLimits, and can be changed through
+		// SetSettings.
+		limits standardLimits
+
+		// persistSets is set if the unconfirmed transaction sets are saved
+		// at shutdown and restored on startup. It is set by default, and can
+		// be changed through SetSettings.
+		persistSets bool
+
 		// Variables related to the blockchain.
 		blockHeight     types.BlockHeight
 		recentMedians   []types.Currency
@@ -93,6 +151,20 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 		transactionHeights:  make(map[types.TransactionID]types.BlockHeight),
 		transactionSets:     make(map[TransactionSetID][]types.Transaction),
 		transactionSetDiffs: make(map[TransactionSetID]*modules.ConsensusChange),
+		transactionSetSizes: make(map[TransactionSetID]int),
+		addressSets:         make(map[types.UnlockHash]map[TransactionSetID]struct{}),
+		localTransactions:   make(map[types.TransactionID]struct{}),
+		orphans:             make(map[TransactionSetID]orphanSet),
+		orphanParents:       make(map[ObjectID][]TransactionSetID),
+		peerOrphans:         make(map[modules.NetAddress]int),
+		sizeLimit:           TransactionPoolSizeLimit,
+		minRelayFee:         MinRelayFee,
+		dustThreshold:       modules.DustThreshold,
+		persistSets:         true,
+		limits:              defaultStandardLimits,
+		metrics: modules.TransactionPoolMetrics{
+			Rejected: make(map[modules.RejectionReason]uint64),
+		},
 
 		persistDir: persistDir,
 	}
@@ -103,11 +175,16 @@ func New(cs modules.ConsensusSet, g modules.Gateway, persistDir string) (*Transa
 		return nil, err
 	}
 
+	// Restore the transaction sets that were in the pool at shutdown.
+	tp.managedLoadTransactionSets()
+
 	// Register RPCs
 	g.RegisterRPC("RelayTransactionSet", tp.relayTransactionSet)
 	tp.tg.OnStop(func() {
 		tp.gateway.UnregisterRPC("RelayTransactionSet")
 	})
+
+	go tp.threadedRebroadcast()
 	return tp, nil
 }
 
@@ -168,6 +245,146 @@ func (tp *TransactionPool) FeeEstimation() (min, max types.Currency) {
 	return
 }
 
+// poolFeeRate returns the fee per byte needed to outbid every transaction set
+// in the pool that would not fit into 'capacity' bytes, if the sets were
+// mined in order of decreasing fee per byte. If the whole pool fits, zero is
+// returned.
+func (tp *TransactionPool) poolFeeRate(capacity uint64) types.Currency {
+	type setRate struct {
+		rate types.Currency
+		size uint64
+	}
+	rates := make([]setRate, 0, len(tp.transactionSets))
+	for id, set := range tp.transactionSets {
+		size := uint64(tp.transactionSetSizes[id])
+		rates = append(rates, setRate{
+			rate: transactionSetFees(set).Div64(size),
+			size: size,
+		})
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].rate.Cmp(rates[j].rate) > 0
+	})
+	var total uint64
+	for _, r := range rates {
+		total += r.size
+		if total > capacity {
+			return r.rate.Add(types.NewCurrency64(1))
+		}
+	}
+	return types.ZeroCurrency
+}
+
+// EstimateFee returns a fee per byte that is likely to get a transaction
+// confirmed within the number of blocks targeted by 'priority'. The estimate
+// is the fee needed to outbid every set in the pool that would not fit into
+// those blocks. It is never lower than the median fee of recent blocks, the
+// fee needed to enter the pool, or minEstimation, which is what gets returned
+// when both the pool and the recent blocks are empty.
+func (tp *TransactionPool) EstimateFee(priority modules.FeePriority) types.Currency {
+	err := tp.tg.Add()
+	if err != nil {
+		return types.ZeroCurrency
+	}
+	defer tp.tg.Done()
+	tp.mu.RLock()
+	defer tp.mu.RUnlock()
+
+	var blocks uint64
+	switch priority {
+	case modules.FeePriorityHigh:
+		blocks = highPriorityBlocks
+	case modules.FeePriorityMedium:
+		blocks = mediumPriorityBlocks
+	default:
+		blocks = lowPriorityBlocks
+	}
+	estimate := tp.poolFeeRate(blocks * types.BlockSizeLimit)
+	for _, floor := range []types.Currency{tp.recentMedianFee, tp.requiredFeesToExtendTpool(), minEstimation} {
+		if estimate.Cmp(floor) < 0 {
+			estimate = floor
+		}
+	}
+	return estimate
+}
+
+// Metrics returns a snapshot of the transaction pool's counters.
+func (tp *TransactionPool) Metrics() modules.TransactionPoolMetrics {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	m := modules.TransactionPoolMetrics{
+		Accepted: tp.metrics.Accepted,
+		Rejected: make(map[modules.RejectionReason]uint64, len(tp.metrics.Rejected)),
+	}
+	for reason, n := range tp.metrics.Rejected {
+		m.Rejected[reason] = n
+	}
+	return m
+}
+
+// MinAcceptableFee returns the minimum total fee that a transaction set of
+// the provided size (in bytes) must pay to be accepted by the transaction pool
+// in its current state. Unlike FeeEstimation, no margin is added: paying one
+// hasting less than the returned value will result in errLowMinerFees.
+func (tp *TransactionPool) MinAcceptableFee(setSize uint64) types.Currency {
+	err := tp.tg.Add()
+	if err != nil {
+		return types.ZeroCurrency
+	}
+	defer tp.tg.Done()
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return tp.requiredFeesToExtendTpool().Mul64(setSize)
+}
+
+// SetSettings changes the policy that the pool applies to transaction sets,
+// described by modules.TransactionPoolSettings. The new settings apply to sets
+// submitted afterwards; sets already in the pool are not removed. The settings
+// are not persisted.
+func (tp *TransactionPool) SetSettings(s modules.TransactionPoolSettings) error {
+	if s.MaxPoolSize <= 0 {
+		return errInvalidPoolSize
+	}
+	if s.MaxTransactionSize <= 0 || s.MaxTransactionSetSize <= 0 {
+		return errInvalidTransactionSize
+	}
+	err := tp.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer tp.tg.Done()
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.sizeLimit = s.MaxPoolSize
+	tp.minRelayFee = s.MinRelayFee
+	tp.dustThreshold = s.DustThreshold
+	tp.persistSets = s.PersistTransactionSets
+	tp.limits = standardLimits{
+		maxTransactionSize:    s.MaxTransactionSize,
+		maxTransactionSetSize: s.MaxTransactionSetSize,
+		maxSignaturesPerInput: s.MaxSignaturesPerInput,
+		maxArbitraryDataSize:  s.MaxArbitraryDataSize,
+	}
+	return nil
+}
+
+// Settings returns the policy that the pool applies to transaction sets.
+func (tp *TransactionPool) Settings() modules.TransactionPoolSettings {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return modules.TransactionPoolSettings{
+		MaxPoolSize:            tp.sizeLimit,
+		MinRelayFee:            tp.minRelayFee,
+		DustThreshold:          tp.dustThreshold,
+		PersistTransactionSets: tp.persistSets,
+
+		MaxTransactionSize:    tp.limits.maxTransactionSize,
+		MaxTransactionSetSize: tp.limits.maxTransactionSetSize,
+		MaxSignaturesPerInput: tp.limits.maxSignaturesPerInput,
+		MaxArbitraryDataSize:  tp.limits.maxArbitraryDataSize,
+	}
+}
+
 // TransactionList returns a list of all transactions in the transaction pool.
 // The transactions are provided in an order that can acceptably be put into a
 // block.
@@ -182,6 +399,18 @@ func (tp *TransactionPool) TransactionList() []types.Transaction {
 	return txns
 }
 
+// Size returns the number of transactions in the transaction pool and the
+// total encoded size of the transaction sets that hold them.
+func (tp *TransactionPool) Size() (transactions int, size int) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	for _, tSet := range tp.transactionSets {
+		transactions += len(tSet)
+	}
+	return transactions, tp.transactionListSize
+}
+
 // Transaction returns the transaction with the provided txid, its parents, and
 // a bool indicating if it exists in the transaction pool.
 func (tp *TransactionPool) Transaction(id types.TransactionID) (types.Transaction, []types.Transaction, bool) {
@@ -260,3 +489,45 @@ func (tp *TransactionPool) Transaction(id types.TransactionID) (types.Transactio
 func (tp *TransactionPool) Broadcast(ts []types.Transaction) {
 	go tp.gateway.Broadcast("RelayTransactionSet", ts, tp.gateway.Peers())
 }
+
+// setsToRebroadcast returns the transaction sets in the pool that contain a
+// transaction which was first seen before the current height. These sets have
+// survived at least one block without being confirmed, so they are
+// rebroadcast in case the peers that they were relayed to dropped them. Sets
+// that go maxTxnAge blocks without being confirmed are pruned from the pool
+// instead.
+func (tp *TransactionPool) setsToRebroadcast() [][]types.Transaction {
+	var sets [][]types.Transaction
+	for _, set := range tp.transactionSets {
+		for _, txn := range set {
+			seenHeight, seen := tp.transactionHeights[txn.ID()]
+			if seen && seenHeight < tp.blockHeight {
+				sets = append(sets, set)
+				break
+			}
+		}
+	}
+	return sets
+}
+
+// threadedRebroadcast periodically rebroadcasts the transaction sets returned
+// by setsToRebroadcast until the transaction pool is stopped.
+func (tp *TransactionPool) threadedRebroadcast() {
+	if err := tp.tg.Add(); err != nil {
+		return
+	}
+	defer tp.tg.Done()
+	for {
+		select {
+		case <-tp.tg.StopChan():
+			return
+		case <-time.After(rebroadcastInterval):
+		}
+		tp.mu.Lock()
+		sets := tp.setsToRebroadcast()
+		tp.mu.Unlock()
+		for _, set := range sets {
+			tp.Broadcast(set)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package transactionpool
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
+	"github.com/NebulousLabs/Sia/encoding"
 	"github.com/NebulousLabs/Sia/modules"
 	"github.com/NebulousLabs/Sia/modules/consensus"
 	"github.com/NebulousLabs/Sia/modules/gateway"
@@ -113,6 +115,59 @@ func (tpt *tpoolTester) Close() error {
 	return nil
 }
 
+// fundUnlockedOutputs sends 'n' outputs worth 'value' to the empty unlock
+// conditions, so that they can be spent without signatures, and mines a block
+// to confirm them. The ids of the outputs are returned.
+func (tpt *tpoolTester) fundUnlockedOutputs(value types.Currency, n int) ([]types.SiacoinOutputID, error) {
+	uc := types.UnlockConditions{}
+	outputs := make([]types.SiacoinOutput, n)
+	for i := range outputs {
+		outputs[i] = types.SiacoinOutput{Value: value, UnlockHash: uc.UnlockHash()}
+	}
+	txns, err := tpt.wallet.SendSiacoinsMulti(outputs)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tpt.miner.AddBlock()
+	if err != nil {
+		return nil, err
+	}
+	var ids []types.SiacoinOutputID
+	fundTxn := txns[len(txns)-1]
+	for i, sco := range fundTxn.SiacoinOutputs {
+		if sco.UnlockHash == uc.UnlockHash() {
+			ids = append(ids, fundTxn.SiacoinOutputID(uint64(i)))
+		}
+	}
+	if len(ids) != n {
+		return nil, errors.New("could not find the funded outputs")
+	}
+	return ids, nil
+}
+
+// spendUnlockedOutputs returns a transaction spending the outputs 'ids', each
+// worth 'value', to a single output that can also be spent without
+// signatures. The transaction pays 'fee' as a miner fee, unless 'fee' is zero.
+func spendUnlockedOutputs(value, fee types.Currency, ids ...types.SiacoinOutputID) types.Transaction {
+	uc := types.UnlockConditions{}
+	txn := types.Transaction{
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      value.Mul64(uint64(len(ids))).Sub(fee),
+			UnlockHash: uc.UnlockHash(),
+		}},
+	}
+	for _, id := range ids {
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         id,
+			UnlockConditions: uc,
+		})
+	}
+	if !fee.IsZero() {
+		txn.MinerFees = []types.Currency{fee}
+	}
+	return txn
+}
+
 // TestIntegrationNewNilInputs tries to trigger a panic with nil inputs.
 func TestIntegrationNewNilInputs(t *testing.T) {
 	// Create a gateway and consensus set.
@@ -192,6 +247,14 @@ func TestGetTransaction(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	numTxns, size := tpt.tpool.Size()
+	if numTxns != len(superSet) {
+		t.Errorf("pool reports %v transactions, expected %v", numTxns, len(superSet))
+	}
+	if size != len(encoding.Marshal(superSet)) {
+		t.Errorf("pool reports a size of %v, expected %v", size, len(encoding.Marshal(superSet)))
+	}
+
 	targetTxn := childrenSet[0]
 	txn, parents, exists := tpt.tpool.Transaction(targetTxn.ID())
 	if !exists {
@@ -210,6 +273,77 @@ func TestGetTransaction(t *testing.T) {
 	}
 }
 
+// TestMetrics checks that the transaction pool counts accepted and rejected
+// transaction sets.
+func TestMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	before := tpt.tpool.Metrics()
+	txns, err := tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(txns)
+	if err != modules.ErrDuplicateTransactionSet {
+		t.Fatalf("expected %v, got %v", modules.ErrDuplicateTransactionSet, err)
+	}
+	err = tpt.tpool.AcceptTransactionSet(nil)
+	if err != errEmptySet {
+		t.Fatalf("expected %v, got %v", errEmptySet, err)
+	}
+
+	m := tpt.tpool.Metrics()
+	if m.Accepted != before.Accepted+1 {
+		t.Error("wrong number of accepted sets:", m.Accepted)
+	}
+	if m.Rejected[modules.RejectionDuplicate] != before.Rejected[modules.RejectionDuplicate]+1 {
+		t.Error("duplicate set was not counted:", m.Rejected)
+	}
+	if m.Rejected[modules.RejectionNonStandard] != before.Rejected[modules.RejectionNonStandard]+1 {
+		t.Error("empty set was not counted:", m.Rejected)
+	}
+
+	// The snapshot should not share state with the pool.
+	m.Rejected[modules.RejectionNonStandard] = 0
+	if tpt.tpool.Metrics().Rejected[modules.RejectionNonStandard] == 0 {
+		t.Error("snapshot shares its map with the pool")
+	}
+}
+
+// TestRejectionReason checks that rejected sets are grouped by reason rather
+// than by error message.
+func TestRejectionReason(t *testing.T) {
+	tests := []struct {
+		err    error
+		reason modules.RejectionReason
+	}{
+		{modules.ErrDuplicateTransactionSet, modules.RejectionDuplicate},
+		{errEmptySet, modules.RejectionNonStandard},
+		{modules.ErrLargeTransaction, modules.RejectionNonStandard},
+		{errDustOutput, modules.RejectionNonStandard},
+		{errLowMinerFees, modules.RejectionLowFees},
+		{errLowFeeBump, modules.RejectionLowFees},
+		{errFullTransactionPool, modules.RejectionFullPool},
+		{errDoubleSpend, modules.RejectionDoubleSpend},
+		{errMissingParents, modules.RejectionMissingParents},
+		{modules.NewConsensusConflict("provided transaction set is standalone and invalid: a"), modules.RejectionInvalid},
+		{modules.NewConsensusConflict("provided transaction set has prereqs, but is still invalid: b"), modules.RejectionInvalid},
+		{errors.New("something else"), modules.RejectionOther},
+	}
+	for _, test := range tests {
+		if reason := rejectionReason(test.err); reason != test.reason {
+			t.Errorf("%v: expected %v, got %v", test.err, test.reason, reason)
+		}
+	}
+}
+
 // TestBlockFeeEstimation checks that the fee estimation algorithm is reasonably
 // on target when the tpool is relying on blockchain based fee estimation.
 func TestFeeEstimation(t *testing.T) {
@@ -337,6 +471,63 @@ func TestFeeEstimation(t *testing.T) {
 	}
 }
 
+// TestEstimateFee checks that EstimateFee returns the minimum estimate for an
+// empty pool, and picks the fee rate at the edge of the targeted number of
+// blocks when the pool is congested.
+func TestEstimateFee(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// An empty pool on a chain without fees should return the minimum.
+	priorities := []modules.FeePriority{modules.FeePriorityLow, modules.FeePriorityMedium, modules.FeePriorityHigh}
+	for _, p := range priorities {
+		if fee := tpt.tpool.EstimateFee(p); !fee.Equals(minEstimation) {
+			t.Fatalf("expected %v for priority %v, got %v", minEstimation, p, fee)
+		}
+	}
+
+	// Seed the pool with 40 sets of about a tenth of a block each, paying
+	// between 1 and 40 SC per byte. The estimator only looks at the sets, so
+	// they are added directly.
+	unit := types.SiacoinPrecision
+	data := make([]byte, types.BlockSizeLimit/10)
+	tpt.tpool.mu.Lock()
+	for i := uint64(1); i <= 40; i++ {
+		set := []types.Transaction{{ArbitraryData: [][]byte{data}}}
+		size := uint64(len(encoding.Marshal(set)))
+		set[0].MinerFees = []types.Currency{unit.Mul64(i * size)}
+		id := TransactionSetID(crypto.HashObject(set))
+		tpt.tpool.transactionSets[id] = set
+		tpt.tpool.transactionSetSizes[id] = len(encoding.Marshal(set))
+		tpt.tpool.transactionListSize += len(encoding.Marshal(set))
+	}
+	floor := tpt.tpool.requiredFeesToExtendTpool()
+	tpt.tpool.mu.Unlock()
+
+	// Nine sets fit into one block, so a high priority transaction needs to
+	// outbid the set paying about 31 SC per byte. Twenty-nine sets fit into
+	// three blocks, leaving the set paying about 11 SC per byte. The whole
+	// pool fits into ten blocks.
+	high := tpt.tpool.EstimateFee(modules.FeePriorityHigh)
+	if high.Cmp(unit.Mul64(30)) <= 0 || high.Cmp(unit.Mul64(32)) >= 0 {
+		t.Errorf("high priority estimate of %v is not about 31 SC per byte", high)
+	}
+	medium := tpt.tpool.EstimateFee(modules.FeePriorityMedium)
+	if medium.Cmp(unit.Mul64(10)) <= 0 || medium.Cmp(unit.Mul64(12)) >= 0 {
+		t.Errorf("medium priority estimate of %v is not about 11 SC per byte", medium)
+	}
+	low := tpt.tpool.EstimateFee(modules.FeePriorityLow)
+	if !low.Equals(floor) {
+		t.Errorf("expected low priority estimate of %v, got %v", floor, low)
+	}
+}
+
 // TestTpoolScalability fills the whole transaction pool with complex
 // transactions, then mines enough blocks to empty it out. Running sequentially,
 // the test should take less than 250ms per mb that the transaction pool fills
@@ -655,3 +846,40 @@ func TestHeapFees(t *testing.T) {
 		t.Error("Expected highest fee from second block to be greater than lowest fee from second block.")
 	}
 }
+
+// TestSetsToRebroadcast checks that only transaction sets which have gone at
+// least one block without being confirmed are rebroadcast.
+func TestSetsToRebroadcast(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	txns, err := tpt.wallet.SendSiacoins(types.SiacoinPrecision, types.UnlockHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The set was just seen, so it should not be rebroadcast.
+	tpt.tpool.mu.Lock()
+	defer tpt.tpool.mu.Unlock()
+	if len(tpt.tpool.setsToRebroadcast()) != 0 {
+		t.Fatal("a new transaction set would be rebroadcast")
+	}
+
+	// Pretend that the set was seen a block ago.
+	for _, txn := range txns {
+		tpt.tpool.transactionHeights[txn.ID()] = tpt.tpool.blockHeight - 1
+	}
+	sets := tpt.tpool.setsToRebroadcast()
+	if len(sets) != 1 {
+		t.Fatalf("expected 1 set to be rebroadcast, got %v", len(sets))
+	}
+	if sets[0][len(sets[0])-1].ID() != txns[len(txns)-1].ID() {
+		t.Error("the wrong set would be rebroadcast")
+	}
+}
@@ -162,6 +162,8 @@ func findSets(ts []types.Transaction) [][]types.Transaction {
 func (tp *TransactionPool) purge() {
 	tp.knownObjects = make(map[ObjectID]TransactionSetID)
 	tp.transactionSets = make(map[TransactionSetID][]types.Transaction)
+	tp.transactionSetSizes = make(map[TransactionSetID]int)
+	tp.addressSets = make(map[types.UnlockHash]map[TransactionSetID]struct{})
 	tp.transactionSetDiffs = make(map[TransactionSetID]*modules.ConsensusChange)
 	tp.transactionListSize = 0
 }
@@ -375,6 +377,13 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 		}
 	}
 
+	// The applied blocks may have created the parents of some orphans.
+	tp.pruneOrphans()
+	for _, set := range tp.retryOrphans(appliedObjectIDs(cc), cc.TryTransactionSet) {
+		go tp.gateway.Broadcast("RelayTransactionSet", set, tp.gateway.Peers())
+	}
+	tp.pruneLocalTransactions()
+
 	// Inform subscribers that an update has executed.
 	tp.mu.Demote()
 	tp.updateSubscribersTransactions()
@@ -385,5 +394,6 @@ func (tp *TransactionPool) ProcessConsensusChange(cc modules.ConsensusChange) {
 func (tp *TransactionPool) PurgeTransactionPool() {
 	tp.mu.Lock()
 	tp.purge()
+	tp.localTransactions = make(map[types.TransactionID]struct{})
 	tp.mu.Unlock()
 }
@@ -377,3 +377,71 @@ func TestUpdateBlockHeight(t *testing.T) {
 		t.Fatalf("transaction pool had the wrong block height, got %v wanted %v\n", tpt.tpool.blockHeight, targetHeight)
 	}
 }
+
+// TestConflictingTransactionEvicted checks that a transaction in the pool is
+// removed, along with its dependents, when a block confirms a different
+// transaction spending the same output.
+func TestConflictingTransactionEvicted(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	tpt, err := createTpoolTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tpt.Close()
+
+	// Create an output that can be spent without signatures.
+	value := types.SiacoinPrecision.Mul64(100)
+	ids, err := tpt.fundUnlockedOutputs(value, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputID := ids[0]
+
+	// spend returns a transaction spending 'id' to a new address that can
+	// also be spent without signatures.
+	spend := func(id types.SiacoinOutputID, arb string) types.Transaction {
+		txn := spendUnlockedOutputs(value, types.ZeroCurrency, id)
+		txn.ArbitraryData = [][]byte{append(modules.PrefixNonSia[:], arb...)}
+		return txn
+	}
+
+	// Put a transaction and a dependent transaction into the pool.
+	txnA := spend(outputID, "a")
+	txnChild := spend(txnA.SiacoinOutputID(0), "child")
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{txnA, txnChild})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a block containing a conflicting transaction that was never seen
+	// by the pool.
+	txnB := spend(outputID, "b")
+	b, target, err := tpt.miner.BlockForWork()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Transactions = []types.Transaction{txnB}
+	solved, _ := tpt.miner.SolveBlock(b, target)
+	err = tpt.cs.AcceptBlock(solved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, txn := range []types.Transaction{txnA, txnChild} {
+		if _, _, exists := tpt.tpool.Transaction(txn.ID()); exists {
+			t.Error("conflicting transaction was not removed from the pool:", txn.ID())
+		}
+	}
+	if len(tpt.tpool.TransactionList()) != 0 {
+		t.Error("transaction pool is not empty after a conflicting block")
+	}
+
+	// The output spent by the block's transaction should be spendable by the
+	// pool.
+	err = tpt.tpool.AcceptTransactionSet([]types.Transaction{spend(txnB.SiacoinOutputID(0), "c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
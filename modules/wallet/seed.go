@@ -484,7 +484,7 @@ func (w *Wallet) SweepSeed(seed modules.Seed) (coins, funds types.Currency, err
 		txnSet := append(parents, txn)
 
 		// submit the transactions
-		err = w.tpool.AcceptTransactionSet(txnSet)
+		err = w.tpool.AcceptLocalTransactionSet(txnSet)
 		if err != nil {
 			return
 		}
@@ -204,8 +204,11 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	}
 	parentTxn.SiacoinOutputs = append(parentTxn.SiacoinOutputs, exactOutput)
 
-	// Create a refund output if needed.
-	if !amount.Equals(fund) {
+	// Create a refund output if needed. A refund too small to be relayed is
+	// given to the miners instead.
+	if refund := fund.Sub(amount); !refund.IsZero() && refund.Cmp(modules.DustThreshold) < 0 {
+		parentTxn.MinerFees = append(parentTxn.MinerFees, refund)
+	} else if !amount.Equals(fund) {
 		refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress(tb.wallet.dbTx)
 		if err != nil {
 			return err
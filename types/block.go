@@ -5,6 +5,7 @@ package types
 
 import (
 	"bytes"
+	"errors"
 
 	"github.com/NebulousLabs/Sia/build"
 	"github.com/NebulousLabs/Sia/crypto"
@@ -16,6 +17,12 @@ const (
 	BlockHeaderSize = 80
 )
 
+var (
+	// ErrTransactionIndexOutOfRange is returned when a transaction proof is
+	// requested for a transaction that is not in the block.
+	ErrTransactionIndexOutOfRange = errors.New("block does not have a transaction at the requested index")
+)
+
 type (
 	// A Block is a summary of changes to the state that have occurred since the
 	// previous block. Blocks reference the ID of the previous block (their
@@ -142,6 +149,54 @@ func (b Block) MerkleRoot() crypto.Hash {
 	return tree.Root()
 }
 
+// TransactionProof returns a Merkle proof that the transaction at the given
+// index is one of the leaves of the block's Merkle root. The proof contains
+// the hashes needed to rebuild the root from the transaction, and can be
+// checked against a block header with VerifyTransactionProof.
+func (b Block) TransactionProof(index int) ([]crypto.Hash, error) {
+	if index < 0 || index >= len(b.Transactions) {
+		return nil, ErrTransactionIndexOutOfRange
+	}
+
+	// The transactions come after the miner payouts in the tree.
+	tree := crypto.NewTree()
+	tree.SetIndex(uint64(len(b.MinerPayouts) + index))
+	var buf bytes.Buffer
+	for _, payout := range b.MinerPayouts {
+		payout.MarshalSia(&buf)
+		tree.Push(buf.Bytes())
+		buf.Reset()
+	}
+	for _, txn := range b.Transactions {
+		txn.MarshalSia(&buf)
+		tree.Push(buf.Bytes())
+		buf.Reset()
+	}
+
+	// The first element of the proof set is the transaction itself, which the
+	// verifier already has.
+	_, proofSet, _, _ := tree.Prove()
+	proof := make([]crypto.Hash, len(proofSet)-1)
+	for i, p := range proofSet[1:] {
+		copy(proof[i][:], p)
+	}
+	return proof, nil
+}
+
+// VerifyTransactionProof checks a proof produced by Block.TransactionProof,
+// returning true if 'txn' is the transaction at 'index' in a block with the
+// given Merkle root, number of miner payouts, and number of transactions.
+func VerifyTransactionProof(root crypto.Hash, txn Transaction, index, numPayouts, numTransactions int, proof []crypto.Hash) bool {
+	if index < 0 || index >= numTransactions || numPayouts < 0 {
+		return false
+	}
+	var buf bytes.Buffer
+	txn.MarshalSia(&buf)
+	leafIndex := uint64(numPayouts + index)
+	numLeaves := uint64(numPayouts + numTransactions)
+	return crypto.VerifySegment(buf.Bytes(), proof, numLeaves, leafIndex, root)
+}
+
 // MinerPayoutID returns the ID of the miner payout at the given index, which
 // is calculated by hashing the concatenation of the BlockID and the payout
 // index.
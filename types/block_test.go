@@ -1,6 +1,7 @@
 package types
 
 import (
+	"math"
 	"testing"
 
 	"github.com/NebulousLabs/Sia/crypto"
@@ -35,6 +36,21 @@ func TestCalculateCoinbase(t *testing.T) {
 	}
 }
 
+// TestCalculateCoinbaseDecayEnd checks the coinbase on either side of the
+// height at which the decay reaches the minimum coinbase.
+func TestCalculateCoinbaseDecayEnd(t *testing.T) {
+	end := BlockHeight(InitialCoinbase - MinimumCoinbase)
+	if c := CalculateCoinbase(end - 1); c.Cmp(NewCurrency64(MinimumCoinbase+1).Mul(SiacoinPrecision)) != 0 {
+		t.Error("wrong coinbase just before the end of the decay:", c)
+	}
+	if c := CalculateCoinbase(end); c.Cmp(NewCurrency64(MinimumCoinbase).Mul(SiacoinPrecision)) != 0 {
+		t.Error("wrong coinbase at the end of the decay:", c)
+	}
+	if c := CalculateCoinbase(end + 1); c.Cmp(NewCurrency64(MinimumCoinbase).Mul(SiacoinPrecision)) != 0 {
+		t.Error("wrong coinbase just after the end of the decay:", c)
+	}
+}
+
 // TestCalculateNumSiacoins checks that the siacoin calculator is correctly
 // determining the number of siacoins in circulation. The check is performed by
 // doing a naive computation, instead of by doing the optimized computation.
@@ -231,3 +247,83 @@ func TestBlockMinerPayoutID(t *testing.T) {
 		knownIDs[id] = struct{}{}
 	}
 }
+
+// TestCalculateSubsidyLargeFees checks that miner fees which would overflow a
+// uint64 are summed exactly into the subsidy.
+func TestCalculateSubsidyLargeFees(t *testing.T) {
+	max64 := NewCurrency64(math.MaxUint64)
+	b := Block{
+		Transactions: []Transaction{
+			{MinerFees: []Currency{max64}},
+			{MinerFees: []Currency{max64, NewCurrency64(2)}},
+		},
+	}
+	expected := CalculateCoinbase(0).Add(max64.Mul64(2)).Add(NewCurrency64(2))
+	if subsidy := b.CalculateSubsidy(0); subsidy.Cmp(expected) != 0 {
+		t.Fatalf("expected subsidy %v, got %v", expected, subsidy)
+	}
+}
+
+// TestTransactionProof generates a proof for every transaction in blocks of
+// several sizes, checking that each proof verifies against the block's Merkle
+// root and that tampered proofs do not.
+func TestTransactionProof(t *testing.T) {
+	for _, numPayouts := range []int{0, 1, 3} {
+		for _, numTxns := range []int{1, 2, 7, 8} {
+			var b Block
+			for i := 0; i < numPayouts; i++ {
+				b.MinerPayouts = append(b.MinerPayouts, SiacoinOutput{Value: NewCurrency64(uint64(i))})
+			}
+			for i := 0; i < numTxns; i++ {
+				b.Transactions = append(b.Transactions, Transaction{
+					ArbitraryData: [][]byte{encoding.Marshal(i)},
+				})
+			}
+			root := b.MerkleRoot()
+
+			for i, txn := range b.Transactions {
+				proof, err := b.TransactionProof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !VerifyTransactionProof(root, txn, i, numPayouts, numTxns, proof) {
+					t.Fatalf("%v payouts, %v txns: proof for transaction %v did not verify", numPayouts, numTxns, i)
+				}
+
+				// The proof should not verify for a different transaction,
+				// index, or root.
+				other := b.Transactions[(i+1)%numTxns]
+				if numTxns > 1 && VerifyTransactionProof(root, other, i, numPayouts, numTxns, proof) {
+					t.Error("proof verified for the wrong transaction")
+				}
+				if numTxns > 1 && VerifyTransactionProof(root, txn, (i+1)%numTxns, numPayouts, numTxns, proof) {
+					t.Error("proof verified at the wrong index")
+				}
+				if VerifyTransactionProof(crypto.Hash{}, txn, i, numPayouts, numTxns, proof) {
+					t.Error("proof verified against the wrong root")
+				}
+
+				// Tampering with any hash in the proof should invalidate it.
+				for j := range proof {
+					tampered := append([]crypto.Hash(nil), proof...)
+					tampered[j][0]++
+					if VerifyTransactionProof(root, txn, i, numPayouts, numTxns, tampered) {
+						t.Error("tampered proof verified")
+					}
+				}
+			}
+		}
+	}
+
+	// Proofs cannot be requested for transactions outside the block.
+	b := Block{Transactions: []Transaction{{}}}
+	if _, err := b.TransactionProof(1); err != ErrTransactionIndexOutOfRange {
+		t.Error("expected out of range error, got", err)
+	}
+	if _, err := b.TransactionProof(-1); err != ErrTransactionIndexOutOfRange {
+		t.Error("expected out of range error, got", err)
+	}
+	if VerifyTransactionProof(b.MerkleRoot(), Transaction{}, 1, 0, 1, nil) {
+		t.Error("proof verified for an index outside the block")
+	}
+}
@@ -26,6 +26,37 @@ func TestCurrencyAdd(t *testing.T) {
 	}
 }
 
+// TestCurrencyAddOverflow checks that adding values beyond the range of a
+// uint64 does not wrap around. Currency is backed by a big.Int, so sums are
+// exact even when they exceed the maximum size that can be sent over the wire.
+func TestCurrencyAddOverflow(t *testing.T) {
+	max64 := NewCurrency64(math.MaxUint64)
+	sum := max64.Add(NewCurrency64(1))
+	if sum.Cmp(max64) <= 0 {
+		t.Fatal("adding to MaxUint64 wrapped around:", sum)
+	}
+	if _, err := sum.Uint64(); err != ErrUint64Overflow {
+		t.Fatal("expected overflow when converting sum to uint64, got", err)
+	}
+	if sum.Sub(max64).Cmp64(1) != 0 {
+		t.Fatal("sum is not exact:", sum)
+	}
+
+	// Add two values that are each at the 255 byte encoding limit. The sum
+	// cannot be encoded, but it must not wrap.
+	capValue := NewCurrency(new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255*8), big.NewInt(1)))
+	if len(capValue.Big().Bytes()) != 255 {
+		t.Fatal("wrong size for cap value:", len(capValue.Big().Bytes()))
+	}
+	capSum := capValue.Add(capValue)
+	if capSum.Cmp(capValue) <= 0 {
+		t.Fatal("adding at the encoding cap wrapped around")
+	}
+	if len(capSum.Big().Bytes()) != 256 {
+		t.Fatal("wrong size for sum beyond cap:", len(capSum.Big().Bytes()))
+	}
+}
+
 // TestCurrencyToBig tests the Big method for the currency type
 func TestCurrencyToBig(t *testing.T) {
 	c := NewCurrency64(125)
@@ -39,6 +39,101 @@ func TestBlockEncoding(t *testing.T) {
 	}
 }
 
+// TestBlockEncodingRoundTrip encodes and decodes a block containing every
+// type of transaction element, checking that the decoded block has the same
+// id and merkle root, and that malformed encodings are rejected.
+func TestBlockEncodingRoundTrip(t *testing.T) {
+	randCurrency := func() Currency {
+		return NewCurrency64(fastrand.Uint64n(1e18)).Mul(SiacoinPrecision)
+	}
+	var uh UnlockHash
+	fastrand.Read(uh[:])
+	var pk SiaPublicKey
+	pk.Algorithm = SignatureEd25519
+	pk.Key = fastrand.Bytes(32)
+	uc := UnlockConditions{
+		Timelock:           5,
+		PublicKeys:         []SiaPublicKey{pk},
+		SignaturesRequired: 1,
+	}
+	fc := FileContract{
+		FileSize:           fastrand.Uint64n(1 << 40),
+		WindowStart:        10,
+		WindowEnd:          20,
+		Payout:             randCurrency(),
+		ValidProofOutputs:  []SiacoinOutput{{Value: randCurrency(), UnlockHash: uh}},
+		MissedProofOutputs: []SiacoinOutput{{Value: randCurrency()}},
+		UnlockHash:         uc.UnlockHash(),
+		RevisionNumber:     3,
+	}
+	fastrand.Read(fc.FileMerkleRoot[:])
+	sp := StorageProof{
+		HashSet: make([]crypto.Hash, 4),
+	}
+	fastrand.Read(sp.ParentID[:])
+	fastrand.Read(sp.Segment[:])
+	for i := range sp.HashSet {
+		fastrand.Read(sp.HashSet[i][:])
+	}
+	txn := Transaction{
+		SiacoinInputs:  []SiacoinInput{{UnlockConditions: uc}},
+		SiacoinOutputs: []SiacoinOutput{{Value: randCurrency(), UnlockHash: uh}},
+		FileContracts:  []FileContract{fc},
+		FileContractRevisions: []FileContractRevision{{
+			UnlockConditions:      uc,
+			NewRevisionNumber:     4,
+			NewValidProofOutputs:  fc.ValidProofOutputs,
+			NewMissedProofOutputs: fc.MissedProofOutputs,
+		}},
+		StorageProofs:  []StorageProof{sp},
+		SiafundInputs:  []SiafundInput{{UnlockConditions: uc, ClaimUnlockHash: uh}},
+		SiafundOutputs: []SiafundOutput{{Value: NewCurrency64(7), UnlockHash: uh}},
+		MinerFees:      []Currency{randCurrency()},
+		ArbitraryData:  [][]byte{fastrand.Bytes(40)},
+		TransactionSignatures: []TransactionSignature{{
+			PublicKeyIndex: 0,
+			CoveredFields:  CoveredFields{WholeTransaction: true},
+			Signature:      fastrand.Bytes(64),
+		}},
+	}
+	fastrand.Read(txn.SiacoinInputs[0].ParentID[:])
+	fastrand.Read(txn.SiafundInputs[0].ParentID[:])
+	fastrand.Read(txn.TransactionSignatures[0].ParentID[:])
+	b := Block{
+		Timestamp:    CurrentTimestamp(),
+		MinerPayouts: []SiacoinOutput{{Value: CalculateCoinbase(0), UnlockHash: uh}},
+		Transactions: []Transaction{txn, {ArbitraryData: [][]byte{[]byte("foo")}}},
+	}
+	fastrand.Read(b.ParentID[:])
+	fastrand.Read(b.Nonce[:])
+
+	encB := encoding.Marshal(b)
+	var decB Block
+	if err := encoding.Unmarshal(encB, &decB); err != nil {
+		t.Fatal(err)
+	}
+	if decB.ID() != b.ID() {
+		t.Error("block id changed after encode/decode")
+	}
+	if decB.MerkleRoot() != b.MerkleRoot() {
+		t.Error("merkle root changed after encode/decode")
+	}
+	if !bytes.Equal(encoding.Marshal(decB), encB) {
+		t.Error("block encoding changed after encode/decode")
+	}
+
+	// Truncated encodings should be rejected, as should encodings with extra
+	// data when decoding strictly.
+	for i := 0; i < len(encB); i++ {
+		if err := encoding.Unmarshal(encB[:i], &decB); err == nil {
+			t.Fatalf("decoded block truncated to %v of %v bytes", i, len(encB))
+		}
+	}
+	if err := encoding.UnmarshalStrict(append(encB, 0), &decB); err == nil {
+		t.Error("decoded block followed by extra data")
+	}
+}
+
 // TestCurrencyMarshalJSON probes the MarshalJSON and UnmarshalJSON functions
 // of the currency type.
 func TestCurrencyMarshalJSON(t *testing.T) {
@@ -321,6 +321,25 @@ func (t *Transaction) validSignatures(currentHeight BlockHeight) error {
 		}
 	}
 
+	// Check that the transaction does not carry more signatures than its
+	// inputs require. Such a transaction would eventually fail with a
+	// frivolous signature, but only after every signature before the extra
+	// one had been hashed and verified. Counting first means that a
+	// transaction stuffed with signatures is rejected before any
+	// cryptography is done, and bounds the number of verifications to the
+	// number of signatures that the inputs actually require.
+	extraSignatures := uint64(len(t.TransactionSignatures))
+	for _, inSig := range sigMap {
+		if inSig.remainingSignatures >= extraSignatures {
+			extraSignatures = 0
+			break
+		}
+		extraSignatures -= inSig.remainingSignatures
+	}
+	if extraSignatures > 0 {
+		return ErrFrivolousSignature
+	}
+
 	// Check all of the signatures for validity.
 	for i, sig := range t.TransactionSignatures {
 		// Check that sig corresponds to an entry in sigMap.
@@ -354,3 +354,115 @@ func TestTransactionValidSignatures(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+// TestTransactionValidSignaturesMultisig checks that a 2-of-3 multisig input
+// is only valid when signed by exactly two of its keys.
+func TestTransactionValidSignaturesMultisig(t *testing.T) {
+	var sks []crypto.SecretKey
+	var pks []SiaPublicKey
+	for i := 0; i < 3; i++ {
+		sk, pk := crypto.GenerateKeyPair()
+		sks = append(sks, sk)
+		pks = append(pks, Ed25519PublicKey(pk))
+	}
+	uc := UnlockConditions{
+		PublicKeys:         pks,
+		SignaturesRequired: 2,
+	}
+
+	// signedTxn returns a transaction spending the multisig input, signed
+	// by the keys at the provided indices.
+	signedTxn := func(keys ...uint64) Transaction {
+		txn := Transaction{
+			SiacoinInputs: []SiacoinInput{{UnlockConditions: uc}},
+		}
+		for _, key := range keys {
+			txn.TransactionSignatures = append(txn.TransactionSignatures, TransactionSignature{
+				PublicKeyIndex: key,
+				CoveredFields:  FullCoveredFields,
+			})
+		}
+		for i, key := range keys {
+			sig := crypto.SignHash(txn.SigHash(i), sks[key])
+			txn.TransactionSignatures[i].Signature = sig[:]
+		}
+		return txn
+	}
+
+	tests := []struct {
+		name string
+		keys []uint64
+		err  error
+	}{
+		{"unsigned", nil, ErrMissingSignatures},
+		{"under-signed", []uint64{0}, ErrMissingSignatures},
+		{"exactly signed", []uint64{0, 2}, nil},
+		{"exactly signed, other keys", []uint64{2, 1}, nil},
+		{"same key twice", []uint64{1, 1}, ErrPublicKeyOveruse},
+		{"over-signed", []uint64{0, 1, 2}, ErrFrivolousSignature},
+	}
+	for _, test := range tests {
+		txn := signedTxn(test.keys...)
+		if err := txn.validSignatures(0); err != test.err {
+			t.Errorf("%v: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+// TestTransactionValidSignaturesOverSigned checks that a transaction carrying
+// more signatures than its inputs require is rejected before any of its
+// signatures are verified.
+func TestTransactionValidSignaturesOverSigned(t *testing.T) {
+	_, pk := crypto.GenerateKeyPair()
+	txn := Transaction{
+		SiacoinInputs: []SiacoinInput{{
+			UnlockConditions: UnlockConditions{
+				PublicKeys:         []SiaPublicKey{Ed25519PublicKey(pk)},
+				SignaturesRequired: 1,
+			},
+		}},
+	}
+
+	// Attach many signatures, none of which would pass verification. If
+	// any were verified, the error would come from the crypto package
+	// rather than being reported as frivolous.
+	for i := 0; i < 1000; i++ {
+		txn.TransactionSignatures = append(txn.TransactionSignatures, TransactionSignature{
+			PublicKeyIndex: uint64(i),
+			CoveredFields:  FullCoveredFields,
+			Signature:      make([]byte, crypto.SignatureSize),
+		})
+	}
+	if err := txn.validSignatures(0); err != ErrFrivolousSignature {
+		t.Fatalf("expected %v, got %v", ErrFrivolousSignature, err)
+	}
+
+	// With exactly as many signatures as required, the signature is verified
+	// and the invalid bytes are caught.
+	txn.TransactionSignatures = txn.TransactionSignatures[:1]
+	if err := txn.validSignatures(0); err == nil || err == ErrFrivolousSignature {
+		t.Fatal("expected the invalid signature to fail verification, got", err)
+	}
+}
+
+// TestTransactionValidSignaturesTrailingData checks that ed25519 public keys
+// and signatures followed by extra bytes are still accepted. Blocks containing
+// such keys and signatures are valid, so rejecting them would fork the chain.
+func TestTransactionValidSignaturesTrailingData(t *testing.T) {
+	sk, pk := crypto.GenerateKeyPair()
+	uc := UnlockConditions{
+		PublicKeys:         []SiaPublicKey{{Algorithm: SignatureEd25519, Key: append(pk[:], 0)}},
+		SignaturesRequired: 1,
+	}
+	txn := Transaction{
+		SiacoinInputs: []SiacoinInput{{UnlockConditions: uc}},
+		TransactionSignatures: []TransactionSignature{{
+			CoveredFields: CoveredFields{WholeTransaction: true},
+		}},
+	}
+	sig := crypto.SignHash(txn.SigHash(0), sk)
+	txn.TransactionSignatures[0].Signature = append(sig[:], 0)
+	if err := txn.validSignatures(0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -282,6 +282,24 @@ func (t Transaction) SiafundOutputID(i uint64) SiafundOutputID {
 	return id
 }
 
+// SpentOutputIDs returns the ids of the objects that the transaction
+// consumes: the siacoin and siafund outputs that it spends, and the file
+// contracts that it submits storage proofs for. File contract revisions are
+// not included, as a contract may be revised more than once.
+func (t Transaction) SpentOutputIDs() []OutputID {
+	oids := make([]OutputID, 0, len(t.SiacoinInputs)+len(t.StorageProofs)+len(t.SiafundInputs))
+	for _, sci := range t.SiacoinInputs {
+		oids = append(oids, OutputID(sci.ParentID))
+	}
+	for _, sp := range t.StorageProofs {
+		oids = append(oids, OutputID(sp.ParentID))
+	}
+	for _, sfi := range t.SiafundInputs {
+		oids = append(oids, OutputID(sfi.ParentID))
+	}
+	return oids
+}
+
 // SiacoinOutputSum returns the sum of all the siacoin outputs in the
 // transaction, which must match the sum of all the siacoin inputs. Siacoin
 // outputs created by storage proofs and siafund outputs are not considered, as
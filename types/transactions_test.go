@@ -67,3 +67,18 @@ func TestTransactionSiacoinOutputSum(t *testing.T) {
 		t.Error("wrong siacoin output sum was calculated, got:", txn.SiacoinOutputSum())
 	}
 }
+
+// TestTransactionSpentOutputIDs probes the SpentOutputIDs method of the
+// Transaction type.
+func TestTransactionSpentOutputIDs(t *testing.T) {
+	txn := Transaction{
+		SiacoinInputs:         []SiacoinInput{{ParentID: SiacoinOutputID{1}}},
+		FileContractRevisions: []FileContractRevision{{ParentID: FileContractID{2}}},
+		StorageProofs:         []StorageProof{{ParentID: FileContractID{3}}},
+		SiafundInputs:         []SiafundInput{{ParentID: SiafundOutputID{4}}},
+	}
+	oids := txn.SpentOutputIDs()
+	if len(oids) != 3 || oids[0] != (OutputID{1}) || oids[1] != (OutputID{3}) || oids[2] != (OutputID{4}) {
+		t.Error("wrong spent output ids:", oids)
+	}
+}
@@ -97,6 +97,78 @@ func TestTransactionCorrectFileContracts(t *testing.T) {
 	}
 }
 
+// TestTransactionFileContractPayouts checks that StandaloneValid rejects file
+// contracts whose payouts are malformed, and that proof outputs may be sent to
+// any address, including the zero address.
+func TestTransactionFileContractPayouts(t *testing.T) {
+	payout := NewCurrency64(1e6)
+	outputPortion := PostTax(30, payout)
+	validContract := func() FileContract {
+		return FileContract{
+			WindowStart:        35,
+			WindowEnd:          40,
+			Payout:             payout,
+			ValidProofOutputs:  []SiacoinOutput{{Value: outputPortion}},
+			MissedProofOutputs: []SiacoinOutput{{Value: outputPortion}},
+		}
+	}
+
+	tests := []struct {
+		modify  func(*FileContract)
+		errWant error
+		msg     string
+	}{
+		{
+			modify:  func(fc *FileContract) {},
+			errWant: nil,
+			msg:     "contract paying the zero address should be valid",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.Payout = ZeroCurrency },
+			errWant: ErrZeroOutput,
+			msg:     "contract with a zero payout should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.WindowStart = 30 },
+			errWant: ErrFileContractWindowStartViolation,
+			msg:     "contract with a window in the past should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.WindowEnd = fc.WindowStart },
+			errWant: ErrFileContractWindowEndViolation,
+			msg:     "contract with an empty window should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.ValidProofOutputs[0].Value = payout.Add(NewCurrency64(1)) },
+			errWant: ErrFileContractOutputSumViolation,
+			msg:     "contract with a valid proof output larger than the payout should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.MissedProofOutputs[0].Value = payout.Add(NewCurrency64(1)) },
+			errWant: ErrFileContractOutputSumViolation,
+			msg:     "contract with a missed proof output larger than the payout should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.ValidProofOutputs = nil },
+			errWant: ErrFileContractOutputSumViolation,
+			msg:     "contract without valid proof outputs should be rejected",
+		},
+		{
+			modify:  func(fc *FileContract) { fc.MissedProofOutputs = nil },
+			errWant: ErrFileContractOutputSumViolation,
+			msg:     "contract without missed proof outputs should be rejected",
+		},
+	}
+	for _, tt := range tests {
+		fc := validContract()
+		tt.modify(&fc)
+		txn := Transaction{FileContracts: []FileContract{fc}}
+		if err := txn.StandaloneValid(30); err != tt.errWant {
+			t.Errorf("%s: expected %v, got %v", tt.msg, tt.errWant, err)
+		}
+	}
+}
+
 // TestCorrectFileContractRevisions probes the correctFileContractRevisions
 // method of the Transaction type.
 func TestCorrectFileContractRevisions(t *testing.T) {